@@ -0,0 +1,299 @@
+package iland
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheConfig configures the inventory cache of a Client, see WithCache.
+type CacheConfig struct {
+	// DefaultTTL is how long a response is reused, unless TTLs has an entry
+	// for its resource type.
+	DefaultTTL time.Duration
+	// TTLs by resource type, the first segment of the API path: "user" for
+	// the locations, "location", "org", "vdc", "vapp", "vm", "edge",
+	// "catalog", "vapp-template", "media", "company". A negative TTL
+	// disables caching for the type.
+	TTLs map[string]time.Duration
+	// MaxEntries bounds the number of cached responses, 1000 by default.
+	MaxEntries int
+}
+
+// DefaultCacheConfig keeps the locations and orgs, which rarely change, for
+// longer than the rest of the inventory.
+var DefaultCacheConfig = CacheConfig{
+	DefaultTTL: time.Minute,
+	TTLs: map[string]time.Duration{
+		"user":     10 * time.Minute,
+		"location": 5 * time.Minute,
+		"org":      5 * time.Minute,
+	},
+	MaxEntries: 1000,
+}
+
+// WithCache makes the client reuse the responses of GET requests until
+// their TTL expires. Every cached response is indexed by the UUIDs it
+// mentions, so that the responses about an entity, including the lists it
+// appears in, are dropped as soon as a request that modifies the entity
+// succeeds, or when a task on the entity is seen completing. Tasks are never
+// cached. See also Client.Invalidate and Client.Refresh.
+func WithCache(config CacheConfig) ClientOption {
+	return func(c *Client) {
+		c.cache = newResponseCache(config)
+	}
+}
+
+var uuidPattern = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+
+// uncachedTypes are always fetched from the API.
+var uncachedTypes = map[string]bool{
+	"task": true,
+}
+
+type cacheEntry struct {
+	data    []byte
+	expires time.Time
+	uuids   []string
+}
+
+type responseCache struct {
+	mu      sync.Mutex
+	config  CacheConfig
+	entries map[string]cacheEntry
+	// index maps a UUID to the paths of the entries that mention it.
+	index map[string]map[string]struct{}
+	// generation counts the invalidations. A response fetched while one
+	// happens may predate it, and is not cached.
+	generation uint64
+}
+
+func newResponseCache(config CacheConfig) *responseCache {
+	if config.MaxEntries <= 0 {
+		config.MaxEntries = DefaultCacheConfig.MaxEntries
+	}
+	return &responseCache{
+		config:  config,
+		entries: map[string]cacheEntry{},
+		index:   map[string]map[string]struct{}{},
+	}
+}
+
+// resourceType returns the first segment of relPath.
+func resourceType(relPath string) string {
+	relPath = strings.TrimPrefix(relPath, "/")
+	resource, _, _ := strings.Cut(relPath, "/")
+	resource, _, _ = strings.Cut(resource, "?")
+	return resource
+}
+
+func (r *responseCache) ttl(relPath string) time.Duration {
+	resource := resourceType(relPath)
+	if uncachedTypes[resource] {
+		return 0
+	}
+	if ttl, ok := r.config.TTLs[resource]; ok {
+		return ttl
+	}
+	return r.config.DefaultTTL
+}
+
+func (r *responseCache) get(relPath string) ([]byte, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, ok := r.entries[relPath]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expires) {
+		r.remove(relPath)
+		return nil, false
+	}
+	return entry.data, true
+}
+
+// currentGeneration returns the generation to pass to put for a response
+// about to be fetched.
+func (r *responseCache) currentGeneration() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.generation
+}
+
+// put caches the response of relPath, unless the cache was invalidated
+// since generation.
+func (r *responseCache) put(relPath string, data []byte, generation uint64) {
+	ttl := r.ttl(relPath)
+	if ttl <= 0 {
+		return
+	}
+	uuids := uniqueUUIDs(relPath + string(data))
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.generation != generation {
+		return
+	}
+	r.remove(relPath)
+	if len(r.entries) >= r.config.MaxEntries {
+		r.evict()
+	}
+	r.entries[relPath] = cacheEntry{data: data, expires: time.Now().Add(ttl), uuids: uuids}
+	for _, uuid := range uuids {
+		paths, ok := r.index[uuid]
+		if !ok {
+			paths = map[string]struct{}{}
+			r.index[uuid] = paths
+		}
+		paths[relPath] = struct{}{}
+	}
+}
+
+// paths returns the paths of the entries that mention uuid.
+func (r *responseCache) paths(uuid string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	paths := []string{}
+	for path := range r.index[strings.ToLower(uuid)] {
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func (r *responseCache) invalidate(uuids ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.generation++
+	for _, uuid := range uuids {
+		for path := range r.index[strings.ToLower(uuid)] {
+			r.remove(path)
+		}
+	}
+}
+
+func (r *responseCache) invalidateAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.generation++
+	r.entries = map[string]cacheEntry{}
+	r.index = map[string]map[string]struct{}{}
+}
+
+// remove drops the entry of relPath. r.mu must be held.
+func (r *responseCache) remove(relPath string) {
+	entry, ok := r.entries[relPath]
+	if !ok {
+		return
+	}
+	delete(r.entries, relPath)
+	for _, uuid := range entry.uuids {
+		delete(r.index[uuid], relPath)
+		if len(r.index[uuid]) == 0 {
+			delete(r.index, uuid)
+		}
+	}
+}
+
+// evict makes room for one entry by dropping the expired entries, or else
+// the one closest to expiring. r.mu must be held.
+func (r *responseCache) evict() {
+	now := time.Now()
+	oldest := ""
+	var oldestExpiry time.Time
+	for path, entry := range r.entries {
+		if now.After(entry.expires) {
+			r.remove(path)
+			continue
+		}
+		if oldest == "" || entry.expires.Before(oldestExpiry) {
+			oldest, oldestExpiry = path, entry.expires
+		}
+	}
+	if len(r.entries) >= r.config.MaxEntries && oldest != "" {
+		r.remove(oldest)
+	}
+}
+
+func uniqueUUIDs(s string) []string {
+	seen := map[string]bool{}
+	uuids := []string{}
+	for _, uuid := range uuidPattern.FindAllString(s, -1) {
+		uuid = strings.ToLower(uuid)
+		if !seen[uuid] {
+			seen[uuid] = true
+			uuids = append(uuids, uuid)
+		}
+	}
+	return uuids
+}
+
+// cachedRequest serves GET requests from the cache and invalidates the
+// entities that other requests modify. The cache keeps its own copy of the
+// responses, callers may modify the data they get. A GET response is not
+// cached if an invalidation happened while it was fetched.
+func (c *Client) cachedRequest(ctx context.Context, relPath, verb string, send func() ([]byte, error)) ([]byte, error) {
+	if verb == "GET" {
+		if data, ok := c.cache.get(relPath); ok {
+			return bytes.Clone(data), nil
+		}
+		generation := c.cache.currentGeneration()
+		data, err := send()
+		if err == nil {
+			c.cache.put(relPath, bytes.Clone(data), generation)
+		}
+		return data, err
+	}
+	data, err := send()
+	if err == nil {
+		// the task returned by the request names the entity it modifies,
+		// which is usually in the path as well.
+		c.cache.invalidate(uniqueUUIDs(relPath + string(data))...)
+	}
+	return data, err
+}
+
+// entityChanged drops the cached responses about an entity that a task has
+// completed on.
+func (c *Client) entityChanged(entityUUID string) {
+	if c.cache != nil && entityUUID != "" {
+		c.cache.invalidate(entityUUID)
+	}
+}
+
+// Invalidate drops the cached responses that mention any of the UUIDs, so
+// that the next request about them reaches the API. It does nothing if the
+// client has no cache, see WithCache.
+func (c *Client) Invalidate(uuids ...string) {
+	if c.cache != nil {
+		c.cache.invalidate(uuids...)
+	}
+}
+
+// InvalidateAll empties the cache.
+func (c *Client) InvalidateAll() {
+	if c.cache != nil {
+		c.cache.invalidateAll()
+	}
+}
+
+// Refresh fetches again every cached response that mentions the UUID.
+func (c *Client) Refresh(uuid string) error {
+	return c.RefreshContext(context.Background(), uuid)
+}
+
+func (c *Client) RefreshContext(ctx context.Context, uuid string) error {
+	if c.cache == nil {
+		return nil
+	}
+	paths := c.cache.paths(uuid)
+	c.cache.invalidate(uuid)
+	for _, path := range paths {
+		_, err := c.GetContext(ctx, path)
+		if err != nil && !IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
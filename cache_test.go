@@ -0,0 +1,234 @@
+package iland
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	cachedVM   = "0a0b0c0d-1111-2222-3333-444455556666"
+	cachedVApp = "1a1b1c1d-1111-2222-3333-444455556666"
+)
+
+func TestResponseCacheTTL(t *testing.T) {
+	cache := newResponseCache(CacheConfig{
+		DefaultTTL: time.Minute,
+		TTLs: map[string]time.Duration{
+			"org":  time.Hour,
+			"edge": -1,
+			"task": time.Hour,
+		},
+	})
+	tests := []struct {
+		path string
+		want time.Duration
+	}{
+		{"/vm/" + cachedVM, time.Minute},
+		{"vm/" + cachedVM, time.Minute},
+		{"/org/o/vms", time.Hour},
+		{"/org?expand=true", time.Hour},
+		{"/edge/e", -1},
+		{"/task/l/t", 0},
+		{"/task/l/org/o/active", 0},
+	}
+	for _, test := range tests {
+		if got := cache.ttl(test.path); got != test.want {
+			t.Errorf("ttl(%q) = %v, want %v", test.path, got, test.want)
+		}
+	}
+}
+
+func TestResponseCacheExpiry(t *testing.T) {
+	cache := newResponseCache(CacheConfig{DefaultTTL: time.Minute})
+	cache.put("/vm/a", []byte("a"), cache.currentGeneration())
+	if data, ok := cache.get("/vm/a"); !ok || string(data) != "a" {
+		t.Fatalf("get = %q, %v, want a hit", data, ok)
+	}
+	entry := cache.entries["/vm/a"]
+	entry.expires = time.Now().Add(-time.Second)
+	cache.entries["/vm/a"] = entry
+	if _, ok := cache.get("/vm/a"); ok {
+		t.Error("get returned an expired response")
+	}
+	if len(cache.entries) != 0 {
+		t.Errorf("%d entries left, want the expired one dropped", len(cache.entries))
+	}
+}
+
+func TestResponseCacheMaxEntries(t *testing.T) {
+	cache := newResponseCache(CacheConfig{DefaultTTL: time.Minute, MaxEntries: 2})
+	for _, path := range []string{"/vm/a", "/vm/b", "/vm/c"} {
+		cache.put(path, []byte(path), cache.currentGeneration())
+	}
+	if len(cache.entries) != 2 {
+		t.Errorf("%d entries, want 2", len(cache.entries))
+	}
+	if _, ok := cache.get("/vm/a"); ok {
+		t.Error("the entry closest to expiring was not evicted")
+	}
+}
+
+func TestResponseCacheStaleGeneration(t *testing.T) {
+	cache := newResponseCache(CacheConfig{DefaultTTL: time.Minute})
+	generation := cache.currentGeneration()
+	cache.invalidate(cachedVM)
+	cache.put("/vm/"+cachedVM, []byte("before"), generation)
+	if _, ok := cache.get("/vm/" + cachedVM); ok {
+		t.Error("a response fetched before an invalidation was cached")
+	}
+}
+
+// countingServer counts the GET requests of every path. The vApp's VMs and
+// the VM mention the VM's UUID. Modifying requests return a task on the
+// entity in their path, or on the VM if there is none.
+type countingServer struct {
+	mu   sync.Mutex
+	gets map[string]int
+}
+
+func (s *countingServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.Method != "GET" {
+		entity := uuidPattern.FindString(r.URL.Path)
+		if entity == "" {
+			entity = cachedVM
+		}
+		fmt.Fprintf(w, `{"uuid":"task","location_id":"l","entity_uuid":%q}`, entity)
+		return
+	}
+	s.gets[r.URL.Path]++
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/task/"):
+		fmt.Fprintf(w, `{"uuid":"task","location_id":"l","entity_uuid":%q,"status":"success","synchronized":true}`, cachedVM)
+	case strings.HasSuffix(r.URL.Path, "/vms"):
+		fmt.Fprintf(w, `[{"uuid":%q,"name":"vm"}]`, cachedVM)
+	default:
+		fmt.Fprintf(w, `{"uuid":%q,"name":"get %d"}`, cachedVM, s.gets[r.URL.Path])
+	}
+}
+
+func (s *countingServer) count(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.gets[path]
+}
+
+func TestClientCache(t *testing.T) {
+	vmPath := "/vm/" + cachedVM
+	vmsPath := "/vapp/" + cachedVApp + "/vms"
+	tests := []struct {
+		name string
+		// change is called between two GET requests of path.
+		change   func(c *Client)
+		path     string
+		wantGets int
+	}{
+		{"cached", func(c *Client) {}, vmPath, 1},
+		{"task not cached", func(c *Client) {}, "/task/l/task", 2},
+		{"modified by path", func(c *Client) { c.Post(vmPath+"/poweron", nil) }, vmPath, 2},
+		{"modified by response", func(c *Client) { c.Post("/org/o/sync", nil) }, vmsPath, 2},
+		{"other entity modified", func(c *Client) { c.Delete("/edge/2a2b2c2d-1111-2222-3333-444455556666") }, vmPath, 1},
+		{"task completed", func(c *Client) {
+			Task{client: c, UUID: "task", LocationID: "l"}.Wait(context.Background(), WaitOptions{})
+		}, vmsPath, 2},
+		{"invalidate", func(c *Client) { c.Invalidate(strings.ToUpper(cachedVM)) }, vmsPath, 2},
+		{"invalidate other", func(c *Client) { c.Invalidate(cachedVApp) }, vmPath, 1},
+		{"invalidate all", func(c *Client) { c.InvalidateAll() }, vmPath, 2},
+		{"refresh", func(c *Client) { c.Refresh(cachedVM) }, vmPath, 2},
+	}
+	for _, test := range tests {
+		server := &countingServer{gets: map[string]int{}}
+		client := newTestClient(t, server, WithCache(DefaultCacheConfig))
+		if _, err := client.Get(test.path); err != nil {
+			t.Fatal(err)
+		}
+		test.change(client)
+		if _, err := client.Get(test.path); err != nil {
+			t.Fatal(err)
+		}
+		if got := server.count(test.path); got != test.wantGets {
+			t.Errorf("%s: %d GET requests, want %d", test.name, got, test.wantGets)
+		}
+	}
+}
+
+func TestClientCacheRefresh(t *testing.T) {
+	server := &countingServer{gets: map[string]int{}}
+	client := newTestClient(t, server, WithCache(DefaultCacheConfig))
+	vmPath := "/vm/" + cachedVM
+	client.Get(vmPath)
+	if err := client.Refresh(cachedVM); err != nil {
+		t.Fatal(err)
+	}
+	data, err := client.Get(vmPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := server.count(vmPath); got != 2 {
+		t.Errorf("%d GET requests, want 2", got)
+	}
+	if !strings.Contains(string(data), "get 2") {
+		t.Errorf("response = %s, want the refreshed one", data)
+	}
+}
+
+func TestClientCacheCopies(t *testing.T) {
+	server := &countingServer{gets: map[string]int{}}
+	client := newTestClient(t, server, WithCache(DefaultCacheConfig))
+	vmPath := "/vm/" + cachedVM
+	first, _ := client.Get(vmPath)
+	want := string(first)
+	first[0] = 'X'
+	second, _ := client.Get(vmPath)
+	if string(second) != want {
+		t.Errorf("second response = %s, want %s", second, want)
+	}
+	second[0] = 'Y'
+	third, _ := client.Get(vmPath)
+	if string(third) != want {
+		t.Errorf("third response = %s, want %s", third, want)
+	}
+}
+
+func TestClientCacheInvalidatedInFlight(t *testing.T) {
+	vmPath := "/vm/" + cachedVM
+	server := &countingServer{gets: map[string]int{}}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	first := atomic.Bool{}
+	first.Store(true)
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" && first.CompareAndSwap(true, false) {
+			close(started)
+			<-release
+		}
+		server.ServeHTTP(w, r)
+	}), WithCache(DefaultCacheConfig))
+	done := make(chan error)
+	go func() {
+		_, err := client.Get(vmPath)
+		done <- err
+	}()
+	<-started
+	if _, err := client.Post(vmPath+"/poweron", nil); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	data, err := client.Get(vmPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := server.count(vmPath); got != 2 || !strings.Contains(string(data), "get 2") {
+		t.Errorf("%d GET requests and response %s, want the response fetched before the change not to be cached", got, data)
+	}
+}
@@ -2,6 +2,7 @@ package iland
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"math"
@@ -28,28 +29,47 @@ type Catalog struct {
 	UpdatedDate int    `json:"updated_date"`
 }
 
-func (c Catalog) GetVAppTemplates() []VAppTemplate {
+func (c Catalog) GetVAppTemplates() ([]VAppTemplate, error) {
+	return c.GetVAppTemplatesContext(context.Background())
+}
+
+func (c Catalog) GetVAppTemplatesContext(ctx context.Context) ([]VAppTemplate, error) {
 	vAppTemplates := []VAppTemplate{}
-	data, _ := c.client.Get(fmt.Sprintf("/catalog/%s/vapp-templates", c.UUID))
-	json.Unmarshal(data, &vAppTemplates)
+	data, err := c.client.GetContext(ctx, fmt.Sprintf("/catalog/%s/vapp-templates", c.UUID))
+	if err != nil {
+		return vAppTemplates, err
+	}
+	err = json.Unmarshal(data, &vAppTemplates)
+	if err != nil {
+		return vAppTemplates, err
+	}
 	for i, vAppTemplate := range vAppTemplates {
 		vAppTemplate.client = c.client
 		vAppTemplates[i] = vAppTemplate
 	}
-	return vAppTemplates
+	return vAppTemplates, nil
 }
 
 func (c Catalog) AddVAppTemplate(sourceVAppUUID, newVAppTemplateName string) (Task, error) {
-	c.client.waitUntilObjectIsReady(c.LocationID, c.UUID)
+	return c.AddVAppTemplateContext(context.Background(), sourceVAppUUID, newVAppTemplateName)
+}
+
+func (c Catalog) AddVAppTemplateContext(ctx context.Context, sourceVAppUUID, newVAppTemplateName string) (Task, error) {
+	if err := c.client.waitUntilObjectIsReady(ctx, c.LocationID, c.UUID); err != nil {
+		return Task{}, err
+	}
 	task := Task{}
-	vApp, err := c.client.GetVApp(sourceVAppUUID)
+	vApp, err := c.client.GetVAppContext(ctx, sourceVAppUUID)
 	if err != nil {
 		return task, err
 	}
 	if newVAppTemplateName == "" {
 		newVAppTemplateName = vApp.Name
 	}
-	existingVAppTemplates := c.GetVAppTemplates()
+	existingVAppTemplates, err := c.GetVAppTemplatesContext(ctx)
+	if err != nil {
+		return task, err
+	}
 	for _, vAppTemplate := range existingVAppTemplates {
 		if vAppTemplate.Name == newVAppTemplateName {
 			return task, fmt.Errorf("vApp template with name, %s, already exists in this catalog", newVAppTemplateName)
@@ -61,7 +81,7 @@ func (c Catalog) AddVAppTemplate(sourceVAppUUID, newVAppTemplateName string) (Ta
 		Name: newVAppTemplateName,
 	}
 	output, _ := json.Marshal(&params)
-	data, err := c.client.Post(fmt.Sprintf("/catalog/%s/add-vapp-template/%s", c.UUID, sourceVAppUUID), output)
+	data, err := c.client.PostContext(ctx, fmt.Sprintf("/catalog/%s/add-vapp-template/%s", c.UUID, sourceVAppUUID), output)
 	if err != nil {
 		return task, err
 	}
@@ -74,14 +94,26 @@ func (c Catalog) AddVAppTemplate(sourceVAppUUID, newVAppTemplateName string) (Ta
 }
 
 func (c Catalog) UploadVAppTemplate(ovaFilePath, vAppTemplateName, storageProfileUUID string) error {
-	c.client.waitUntilObjectIsReady(c.LocationID, c.UUID)
+	return c.UploadVAppTemplateContext(context.Background(), ovaFilePath, vAppTemplateName, storageProfileUUID)
+}
+
+func (c Catalog) UploadVAppTemplateContext(ctx context.Context, ovaFilePath, vAppTemplateName, storageProfileUUID string) error {
+	if err := c.client.waitUntilObjectIsReady(ctx, c.LocationID, c.UUID); err != nil {
+		return err
+	}
 	var storageProfile StorageProfile
 	if storageProfileUUID == "" {
-		org, _ := c.client.GetOrg(c.OrgUUID)
-		storageProfile = org.GetDefaultStorageProfile()
+		org, err := c.client.GetOrgContext(ctx, c.OrgUUID)
+		if err != nil {
+			return err
+		}
+		storageProfile, err = org.GetDefaultStorageProfileContext(ctx)
+		if err != nil {
+			return err
+		}
 	} else {
 		var err error
-		storageProfile, err = c.client.GetStorageProfile(storageProfileUUID)
+		storageProfile, err = c.client.GetStorageProfileContext(ctx, storageProfileUUID)
 		if err != nil {
 			return fmt.Errorf("storage profile with UUID, %s, does not exist", storageProfileUUID)
 		}
@@ -127,7 +159,7 @@ func (c Catalog) UploadVAppTemplate(ovaFilePath, vAppTemplateName, storageProfil
 		w.Close()
 
 		contentType := "multipart/form-data; boundary=" + w.Boundary()
-		_, err := c.client.postForm(fmt.Sprintf("/catalog/%s/vapp-template/upload", c.UUID), contentType, b.Bytes())
+		_, err := c.client.postForm(ctx, fmt.Sprintf("/catalog/%s/vapp-template/upload", c.UUID), contentType, b.Bytes())
 		if err != nil {
 			return err
 		}
@@ -137,22 +169,28 @@ func (c Catalog) UploadVAppTemplate(ovaFilePath, vAppTemplateName, storageProfil
 }
 
 func (c Catalog) ChunkUploaded(uploadID string, chunkNumber, totalChunks int) (bool, error) {
-	err := c.client.RefreshTokenIfNecessary()
-	if err != nil {
-		return false, err
-	}
-	client := &http.Client{}
-	path := fmt.Sprintf("%s/catalog/%s/vapp-template/upload?resumableIdentifier=%s&resumableChunkNumber=%d&resumableTotalChunks=%d", apiBaseURL, c.UUID, uploadID, chunkNumber, totalChunks)
-	req, _ := http.NewRequest("GET", path, nil)
-	req.Header.Add("Authorization", "Bearer "+c.client.Token.AccessToken)
-	req.Header.Add("Accept", "application/vnd.ilandcloud.api.v0.8+json")
-	req.Header.Add("Content-Type", "application/vnd.ilandcloud.api.v0.8+json")
-	resp, err := client.Do(req)
+	return c.ChunkUploadedContext(context.Background(), uploadID, chunkNumber, totalChunks)
+}
+
+func (c Catalog) ChunkUploadedContext(ctx context.Context, uploadID string, chunkNumber, totalChunks int) (bool, error) {
+	relPath := fmt.Sprintf("/catalog/%s/vapp-template/upload?resumableIdentifier=%s&resumableChunkNumber=%d&resumableTotalChunks=%d", c.UUID, uploadID, chunkNumber, totalChunks)
+	resp, _, err := c.client.send(ctx, "GET", relPath, nil, apiMediaType, apiMediaType)
 	if err != nil {
 		return false, err
 	}
+	resp.Body.Close()
 	if resp.StatusCode == http.StatusOK {
 		return true, nil
 	}
 	return false, nil
 }
+
+// IsBusy reports whether the catalog has active tasks, in which case the
+// methods that modify it wait or fail, see ReadinessPolicy.
+func (c Catalog) IsBusy() (bool, error) {
+	return c.IsBusyContext(context.Background())
+}
+
+func (c Catalog) IsBusyContext(ctx context.Context) (bool, error) {
+	return c.client.isBusy(ctx, c.LocationID, c.UUID)
+}
@@ -1,50 +1,126 @@
 package iland
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
-	"time"
+	"net/http"
+	"net/url"
 )
 
 type Client struct {
-	username        string
-	password        string
-	clientID        string
-	clientSecret    string
-	Token           Token
-	tokenExpiration time.Time
+	username     string
+	password     string
+	clientID     string
+	clientSecret string
+	// Deprecated: Token is kept up to date for compatibility only and must
+	// not be read while the client is in use by other goroutines.
+	Token             Token
+	tokenSource       TokenSource
+	tokens            *reuseTokenSource
+	tokenCache        TokenCache
+	baseURL           string
+	accessURL         string
+	refreshURL        string
+	userAgent         string
+	httpClient        *http.Client
+	transport         http.RoundTripper
+	tlsConfig         *tls.Config
+	proxy             func(*http.Request) (*url.URL, error)
+	retryPolicy       RetryPolicy
+	readinessPolicy   ReadinessPolicy
+	cache             *responseCache
+	rateLimiter       *rateLimiter
+	inFlight          chan struct{}
+	fanOutConcurrency int
+	hooks             []Hooks
 }
 
 func NewClient(Username, Password, ClientID, ClientSecret string) (*Client, error) {
+	return NewClientWithOptions(Username, Password, ClientID, ClientSecret)
+}
+
+// NewClientWithOptions creates a Client configured by opts and retrieves
+// its first token. All requests made by the Client share one http.Client.
+func NewClientWithOptions(Username, Password, ClientID, ClientSecret string, opts ...ClientOption) (*Client, error) {
 	client := Client{
-		username:     Username,
-		password:     Password,
-		clientID:     ClientID,
-		clientSecret: ClientSecret,
-	}
-	err := client.getToken()
+		username:          Username,
+		password:          Password,
+		clientID:          ClientID,
+		clientSecret:      ClientSecret,
+		baseURL:           defaultBaseURL,
+		accessURL:         defaultAccessURL,
+		refreshURL:        defaultRefreshURL,
+		retryPolicy:       DefaultRetryPolicy,
+		readinessPolicy:   DefaultReadinessPolicy,
+		fanOutConcurrency: defaultFanOutConcurrency,
+	}
+	for _, opt := range opts {
+		opt(&client)
+	}
+	client.buildHTTPClient()
+	if client.tokenSource == nil {
+		client.tokenSource = &passwordTokenSource{client: &client}
+	}
+	client.tokens = newReuseTokenSource(client.tokenSource, func(ctx context.Context, token *Token, err error) {
+		if token != nil {
+			client.Token = *token
+		}
+		client.tokenRenewed(ctx, err)
+	})
+	err := client.RefreshTokenIfNecessary()
 	return &client, err
 }
 
 func (c *Client) Get(endpoint string) ([]byte, error) {
-	return c.request(endpoint, "GET", []byte{})
+	return c.GetContext(context.Background(), endpoint)
+}
+
+func (c *Client) GetContext(ctx context.Context, endpoint string) ([]byte, error) {
+	return c.request(ctx, endpoint, "GET", []byte{})
 }
 
 func (c *Client) Post(endpoint string, body []byte) ([]byte, error) {
-	return c.request(endpoint, "POST", body)
+	return c.PostContext(context.Background(), endpoint, body)
+}
+
+func (c *Client) PostContext(ctx context.Context, endpoint string, body []byte) ([]byte, error) {
+	return c.request(ctx, endpoint, "POST", body)
 }
 
 func (c *Client) Put(endpoint string, body []byte) ([]byte, error) {
-	return c.request(endpoint, "PUT", body)
+	return c.PutContext(context.Background(), endpoint, body)
+}
+
+func (c *Client) PutContext(ctx context.Context, endpoint string, body []byte) ([]byte, error) {
+	return c.request(ctx, endpoint, "PUT", body)
 }
 
 func (c *Client) Delete(endpoint string) ([]byte, error) {
-	return c.request(endpoint, "DELETE", []byte{})
+	return c.DeleteContext(context.Background(), endpoint)
+}
+
+func (c *Client) DeleteContext(ctx context.Context, endpoint string) ([]byte, error) {
+	return c.request(ctx, endpoint, "DELETE", []byte{})
+}
+
+// getJSON gets relPath and decodes the response into v.
+func (c *Client) getJSON(ctx context.Context, relPath string, v interface{}) error {
+	data, err := c.GetContext(ctx, relPath)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
 }
 
 func (c *Client) GetUser(username string) (User, error) {
+	return c.GetUserContext(context.Background(), username)
+}
+
+func (c *Client) GetUserContext(ctx context.Context, username string) (User, error) {
 	user := User{}
-	data, err := c.Get(fmt.Sprintf("/user/%s", username))
+	data, err := c.GetContext(ctx, fmt.Sprintf("/user/%s", username))
 	if err != nil {
 		return user, err
 	}
@@ -54,8 +130,12 @@ func (c *Client) GetUser(username string) (User, error) {
 }
 
 func (c *Client) GetCompany(crm string) (Company, error) {
+	return c.GetCompanyContext(context.Background(), crm)
+}
+
+func (c *Client) GetCompanyContext(ctx context.Context, crm string) (Company, error) {
 	company := Company{}
-	data, err := c.Get(fmt.Sprintf("/companies/%s", crm))
+	data, err := c.GetContext(ctx, fmt.Sprintf("/companies/%s", crm))
 	if err != nil {
 		return company, err
 	}
@@ -65,8 +145,12 @@ func (c *Client) GetCompany(crm string) (Company, error) {
 }
 
 func (c *Client) GetCloudTenant(tenantUUID string) (CloudTenant, error) {
+	return c.GetCloudTenantContext(context.Background(), tenantUUID)
+}
+
+func (c *Client) GetCloudTenantContext(ctx context.Context, tenantUUID string) (CloudTenant, error) {
 	cloudTenant := CloudTenant{}
-	data, err := c.Get(fmt.Sprintf("/cloud-tenant/%s", tenantUUID))
+	data, err := c.GetContext(ctx, fmt.Sprintf("/cloud-tenant/%s", tenantUUID))
 	if err != nil {
 		return cloudTenant, err
 	}
@@ -74,19 +158,37 @@ func (c *Client) GetCloudTenant(tenantUUID string) (CloudTenant, error) {
 	return cloudTenant, err
 }
 
-func (c *Client) GetLocations() []Location {
+func (c *Client) GetLocations() ([]Location, error) {
+	return c.GetLocationsContext(context.Background())
+}
+
+func (c *Client) GetLocationsContext(ctx context.Context) ([]Location, error) {
 	locations := []Location{}
-	data, _ := c.Get(fmt.Sprintf("/user/%s/inventory", c.username))
-	json.Unmarshal(data, &locations)
+	data, err := c.GetContext(ctx, fmt.Sprintf("/user/%s/inventory", c.username))
+	if err != nil {
+		return locations, err
+	}
+	err = json.Unmarshal(data, &locations)
+	if err != nil {
+		return locations, err
+	}
 	for i, location := range locations {
 		location.client = c
 		locations[i] = location
 	}
-	return locations
+	return locations, nil
 }
 
 func (c *Client) GetLocation(locationID string) (Location, error) {
-	for _, location := range c.GetLocations() {
+	return c.GetLocationContext(context.Background(), locationID)
+}
+
+func (c *Client) GetLocationContext(ctx context.Context, locationID string) (Location, error) {
+	locations, err := c.GetLocationsContext(ctx)
+	if err != nil {
+		return Location{}, err
+	}
+	for _, location := range locations {
 		if location.ID == locationID {
 			location.client = c
 			return location, nil
@@ -95,24 +197,34 @@ func (c *Client) GetLocation(locationID string) (Location, error) {
 	return Location{}, fmt.Errorf("location with ID, %s, not found", locationID)
 }
 
-func (c *Client) GetOrgs() []Org {
-	orgs := []Org{}
-	for _, location := range c.GetLocations() {
-		locationOrgs := []Org{}
-		data, _ := c.Get(fmt.Sprintf("/location/%s/orgs", location.ID))
-		json.Unmarshal(data, &locationOrgs)
-		orgs = append(orgs, locationOrgs...)
+func (c *Client) GetOrgs() ([]Org, error) {
+	return c.GetOrgsContext(context.Background())
+}
+
+func (c *Client) GetOrgsContext(ctx context.Context) ([]Org, error) {
+	locations, err := c.GetLocationsContext(ctx)
+	if err != nil {
+		return []Org{}, err
 	}
+	return c.getLocationOrgs(ctx, locations)
+}
+
+func (c *Client) getLocationOrgs(ctx context.Context, locations []Location) ([]Org, error) {
+	orgs, err := fanOutList[Org](ctx, c, "location", locationIDs(locations), "/location/%s/orgs")
 	for i, org := range orgs {
 		org.client = c
 		orgs[i] = org
 	}
-	return orgs
+	return orgs, err
 }
 
 func (c *Client) GetOrg(orgUUID string) (Org, error) {
+	return c.GetOrgContext(context.Background(), orgUUID)
+}
+
+func (c *Client) GetOrgContext(ctx context.Context, orgUUID string) (Org, error) {
 	org := Org{}
-	data, err := c.Get(fmt.Sprintf("/org/%s", orgUUID))
+	data, err := c.GetContext(ctx, fmt.Sprintf("/org/%s", orgUUID))
 	if err != nil {
 		return org, err
 	}
@@ -124,24 +236,37 @@ func (c *Client) GetOrg(orgUUID string) (Org, error) {
 	return org, nil
 }
 
-func (c *Client) GetCatalogs() []Catalog {
-	catalogs := []Catalog{}
-	for _, org := range c.GetOrgs() {
-		orgCatalogs := []Catalog{}
-		data, _ := c.Get(fmt.Sprintf("/org/%s/catalogs", org.UUID))
-		json.Unmarshal(data, &orgCatalogs)
-		catalogs = append(catalogs, orgCatalogs...)
+func (c *Client) GetCatalogs() ([]Catalog, error) {
+	return c.GetCatalogsContext(context.Background())
+}
+
+func (c *Client) GetCatalogsContext(ctx context.Context) ([]Catalog, error) {
+	orgs, err := c.GetOrgsContext(ctx)
+	listErr := ListError{}
+	if !listErr.merge(err) {
+		return []Catalog{}, err
 	}
+	catalogs, err := c.getOrgCatalogs(ctx, orgs)
+	listErr.merge(err)
+	return catalogs, listErr.errOrNil()
+}
+
+func (c *Client) getOrgCatalogs(ctx context.Context, orgs []Org) ([]Catalog, error) {
+	catalogs, err := fanOutList[Catalog](ctx, c, "org", orgUUIDs(orgs), "/org/%s/catalogs")
 	for i, catalog := range catalogs {
 		catalog.client = c
 		catalogs[i] = catalog
 	}
-	return catalogs
+	return catalogs, err
 }
 
 func (c *Client) GetCatalog(catalogUUID string) (Catalog, error) {
+	return c.GetCatalogContext(context.Background(), catalogUUID)
+}
+
+func (c *Client) GetCatalogContext(ctx context.Context, catalogUUID string) (Catalog, error) {
 	catalog := Catalog{}
-	data, err := c.Get(fmt.Sprintf("/catalog/%s", catalogUUID))
+	data, err := c.GetContext(ctx, fmt.Sprintf("/catalog/%s", catalogUUID))
 	if err != nil {
 		return catalog, err
 	}
@@ -153,24 +278,34 @@ func (c *Client) GetCatalog(catalogUUID string) (Catalog, error) {
 	return catalog, nil
 }
 
-func (c *Client) GetVdcs() []Vdc {
-	vdcs := []Vdc{}
-	for _, location := range c.GetLocations() {
-		locationVdcs := []Vdc{}
-		data, _ := c.Get(fmt.Sprintf("/location/%s/vdcs", location.ID))
-		json.Unmarshal(data, &locationVdcs)
-		vdcs = append(vdcs, locationVdcs...)
+func (c *Client) GetVdcs() ([]Vdc, error) {
+	return c.GetVdcsContext(context.Background())
+}
+
+func (c *Client) GetVdcsContext(ctx context.Context) ([]Vdc, error) {
+	locations, err := c.GetLocationsContext(ctx)
+	if err != nil {
+		return []Vdc{}, err
 	}
+	return c.getLocationVdcs(ctx, locations)
+}
+
+func (c *Client) getLocationVdcs(ctx context.Context, locations []Location) ([]Vdc, error) {
+	vdcs, err := fanOutList[Vdc](ctx, c, "location", locationIDs(locations), "/location/%s/vdcs")
 	for i, vdc := range vdcs {
 		vdc.client = c
 		vdcs[i] = vdc
 	}
-	return vdcs
+	return vdcs, err
 }
 
 func (c *Client) GetVdc(vdcUUID string) (Vdc, error) {
+	return c.GetVdcContext(context.Background(), vdcUUID)
+}
+
+func (c *Client) GetVdcContext(ctx context.Context, vdcUUID string) (Vdc, error) {
 	vdc := Vdc{}
-	data, err := c.Get(fmt.Sprintf("/vdc/%s", vdcUUID))
+	data, err := c.GetContext(ctx, fmt.Sprintf("/vdc/%s", vdcUUID))
 	if err != nil {
 		return vdc, err
 	}
@@ -182,44 +317,66 @@ func (c *Client) GetVdc(vdcUUID string) (Vdc, error) {
 	return vdc, nil
 }
 
-func (c *Client) GetStorageProfiles() []StorageProfile {
-	storageProfiles := []StorageProfile{}
-	for _, vdc := range c.GetVdcs() {
-		vdcStorageProfiles := []StorageProfile{}
-		data, _ := c.Get(fmt.Sprintf("/vdc/%s/storage-profiles", vdc.UUID))
-		json.Unmarshal(data, &vdcStorageProfiles)
-		storageProfiles = append(storageProfiles, vdcStorageProfiles...)
+func (c *Client) GetStorageProfiles() ([]StorageProfile, error) {
+	return c.GetStorageProfilesContext(context.Background())
+}
+
+func (c *Client) GetStorageProfilesContext(ctx context.Context) ([]StorageProfile, error) {
+	vdcs, err := c.GetVdcsContext(ctx)
+	listErr := ListError{}
+	if !listErr.merge(err) {
+		return []StorageProfile{}, err
 	}
-	return storageProfiles
+	storageProfiles, err := c.getVdcStorageProfiles(ctx, vdcs)
+	listErr.merge(err)
+	return storageProfiles, listErr.errOrNil()
+}
+
+func (c *Client) getVdcStorageProfiles(ctx context.Context, vdcs []Vdc) ([]StorageProfile, error) {
+	storageProfiles, err := fanOutList[StorageProfile](ctx, c, "vdc", vdcUUIDs(vdcs), "/vdc/%s/storage-profiles")
+	return storageProfiles, err
 }
 
 func (c *Client) GetStorageProfile(storageProfileUUID string) (StorageProfile, error) {
-	for _, storageProfile := range c.GetStorageProfiles() {
-		if storageProfile.UUID == storageProfileUUID {
-			return storageProfile, nil
-		}
-	}
-	return StorageProfile{}, fmt.Errorf("storage profile with UUID, %s, not found", storageProfileUUID)
+	return c.GetStorageProfileContext(context.Background(), storageProfileUUID)
+}
+
+func (c *Client) GetStorageProfileContext(ctx context.Context, storageProfileUUID string) (StorageProfile, error) {
+	query := c.StorageProfiles().Where(func(s StorageProfile) bool { return s.UUID == storageProfileUUID })
+	return first(query.Iter(ctx), fmt.Errorf("storage profile with UUID, %s, not found", storageProfileUUID))
+}
+
+func (c *Client) GetEdges() ([]Edge, error) {
+	return c.GetEdgesContext(context.Background())
 }
 
-func (c *Client) GetEdges() []Edge {
-	edges := []Edge{}
-	for _, org := range c.GetOrgs() {
-		orgEdges := []Edge{}
-		data, _ := c.Get(fmt.Sprintf("/org/%s/edges", org.UUID))
-		json.Unmarshal(data, &orgEdges)
-		edges = append(edges, orgEdges...)
+func (c *Client) GetEdgesContext(ctx context.Context) ([]Edge, error) {
+	orgs, err := c.GetOrgsContext(ctx)
+	listErr := ListError{}
+	if !listErr.merge(err) {
+		return []Edge{}, err
 	}
+	edges, err := c.getOrgEdges(ctx, orgs)
+	listErr.merge(err)
+	return edges, listErr.errOrNil()
+}
+
+func (c *Client) getOrgEdges(ctx context.Context, orgs []Org) ([]Edge, error) {
+	edges, err := fanOutList[Edge](ctx, c, "org", orgUUIDs(orgs), "/org/%s/edges")
 	for i, edge := range edges {
 		edge.client = c
 		edges[i] = edge
 	}
-	return edges
+	return edges, err
 }
 
 func (c *Client) GetEdge(edgeUUID string) (Edge, error) {
+	return c.GetEdgeContext(context.Background(), edgeUUID)
+}
+
+func (c *Client) GetEdgeContext(ctx context.Context, edgeUUID string) (Edge, error) {
 	edge := Edge{}
-	data, err := c.Get(fmt.Sprintf("/edge/%s", edgeUUID))
+	data, err := c.GetContext(ctx, fmt.Sprintf("/edge/%s", edgeUUID))
 	if err != nil {
 		return edge, err
 	}
@@ -231,54 +388,72 @@ func (c *Client) GetEdge(edgeUUID string) (Edge, error) {
 	return edge, nil
 }
 
-func (c *Client) GetVdcNetworks() []VdcNetwork {
-	vdcNetworks := []VdcNetwork{}
-	for _, org := range c.GetOrgs() {
-		networks := []VdcNetwork{}
-		data, _ := c.Get(fmt.Sprintf("/org/%s/vdc-networks", org.UUID))
-		json.Unmarshal(data, &networks)
-		vdcNetworks = append(vdcNetworks, networks...)
+func (c *Client) GetVdcNetworks() ([]VdcNetwork, error) {
+	return c.GetVdcNetworksContext(context.Background())
+}
+
+func (c *Client) GetVdcNetworksContext(ctx context.Context) ([]VdcNetwork, error) {
+	orgs, err := c.GetOrgsContext(ctx)
+	listErr := ListError{}
+	if !listErr.merge(err) {
+		return []VdcNetwork{}, err
 	}
+	vdcNetworks, err := c.getOrgVdcNetworks(ctx, orgs)
+	listErr.merge(err)
+	return vdcNetworks, listErr.errOrNil()
+}
+
+func (c *Client) getOrgVdcNetworks(ctx context.Context, orgs []Org) ([]VdcNetwork, error) {
+	vdcNetworks, err := fanOutList[VdcNetwork](ctx, c, "org", orgUUIDs(orgs), "/org/%s/vdc-networks")
 	for i, vdcNetwork := range vdcNetworks {
 		vdcNetwork.client = c
 		vdcNetworks[i] = vdcNetwork
 	}
-	return vdcNetworks
+	return vdcNetworks, err
 }
 
 func (c *Client) GetVdcNetwork(vdcNetworkUUID string) (VdcNetwork, error) {
-	for _, network := range c.GetVdcNetworks() {
-		if network.UUID == vdcNetworkUUID {
-			network.client = c
-			return network, nil
-		}
-	}
-	return VdcNetwork{}, fmt.Errorf("vdc network with uuid, %s, not found", vdcNetworkUUID)
+	return c.GetVdcNetworkContext(context.Background(), vdcNetworkUUID)
 }
 
-func (c *Client) GetVAppTemplates() []VAppTemplate {
-	vAppTemplates := []VAppTemplate{}
-	for _, catalog := range c.GetCatalogs() {
-		catalogTemplates := []VAppTemplate{}
-		data, _ := c.Get(fmt.Sprintf("/catalog/%s/vapp-templates", catalog.UUID))
-		json.Unmarshal(data, &catalogTemplates)
-		vAppTemplates = append(vAppTemplates, catalogTemplates...)
-	}
-	locations := c.GetLocations()
-	for _, location := range locations {
-		publicTemplates, _ := location.GetPublicVAppTemplates()
-		vAppTemplates = append(vAppTemplates, publicTemplates...)
-	}
+func (c *Client) GetVdcNetworkContext(ctx context.Context, vdcNetworkUUID string) (VdcNetwork, error) {
+	query := c.VdcNetworks().Where(func(n VdcNetwork) bool { return n.UUID == vdcNetworkUUID })
+	return first(query.Iter(ctx), fmt.Errorf("vdc network with uuid, %s, not found", vdcNetworkUUID))
+}
+
+func (c *Client) GetVAppTemplates() ([]VAppTemplate, error) {
+	return c.GetVAppTemplatesContext(context.Background())
+}
+
+func (c *Client) GetVAppTemplatesContext(ctx context.Context) ([]VAppTemplate, error) {
+	locations, err := c.GetLocationsContext(ctx)
+	if err != nil {
+		return []VAppTemplate{}, err
+	}
+	orgs, err := c.getLocationOrgs(ctx, locations)
+	listErr := ListError{}
+	listErr.merge(err)
+	catalogs, err := c.getOrgCatalogs(ctx, orgs)
+	listErr.merge(err)
+	vAppTemplates, err := fanOutList[VAppTemplate](ctx, c, "catalog", catalogUUIDs(catalogs), "/catalog/%s/vapp-templates")
+	listErr.merge(err)
+	publicTemplates, err := fanOutList[VAppTemplate](ctx, c, "location", locationIDs(locations), "/location/%s/public-vapp-templates")
+	listErr.merge(err)
+	vAppTemplates = append(vAppTemplates, publicTemplates...)
 	for i, vAppTemplate := range vAppTemplates {
 		vAppTemplate.client = c
 		vAppTemplates[i] = vAppTemplate
 	}
-	return vAppTemplates
+	return vAppTemplates, listErr.errOrNil()
 }
 
 func (c *Client) GetVAppTemplate(vAppTemplateUUID string) (VAppTemplate, error) {
+	return c.GetVAppTemplateContext(context.Background(), vAppTemplateUUID)
+}
+
+func (c *Client) GetVAppTemplateContext(ctx context.Context, vAppTemplateUUID string) (VAppTemplate, error) {
 	vAppTemplate := VAppTemplate{}
-	data, err := c.Get(fmt.Sprintf("/vapp-template/%s", vAppTemplateUUID))
+	data, err := c.GetContext(ctx, fmt.Sprintf("/vapp-template/%s", vAppTemplateUUID))
 	if err != nil {
 		return vAppTemplate, err
 	}
@@ -290,24 +465,34 @@ func (c *Client) GetVAppTemplate(vAppTemplateUUID string) (VAppTemplate, error)
 	return vAppTemplate, nil
 }
 
-func (c *Client) GetVApps() []VApp {
-	vApps := []VApp{}
-	for _, location := range c.GetLocations() {
-		locationVApps := []VApp{}
-		data, _ := c.Get(fmt.Sprintf("/location/%s/vapps", location.ID))
-		json.Unmarshal(data, &locationVApps)
-		vApps = append(vApps, locationVApps...)
+func (c *Client) GetVApps() ([]VApp, error) {
+	return c.GetVAppsContext(context.Background())
+}
+
+func (c *Client) GetVAppsContext(ctx context.Context) ([]VApp, error) {
+	locations, err := c.GetLocationsContext(ctx)
+	if err != nil {
+		return []VApp{}, err
 	}
+	return c.getLocationVApps(ctx, locations)
+}
+
+func (c *Client) getLocationVApps(ctx context.Context, locations []Location) ([]VApp, error) {
+	vApps, err := fanOutList[VApp](ctx, c, "location", locationIDs(locations), "/location/%s/vapps")
 	for i, vApp := range vApps {
 		vApp.client = c
 		vApps[i] = vApp
 	}
-	return vApps
+	return vApps, err
 }
 
 func (c *Client) GetVApp(vAppUUID string) (VApp, error) {
+	return c.GetVAppContext(context.Background(), vAppUUID)
+}
+
+func (c *Client) GetVAppContext(ctx context.Context, vAppUUID string) (VApp, error) {
 	vApp := VApp{}
-	data, err := c.Get(fmt.Sprintf("/vapp/%s", vAppUUID))
+	data, err := c.GetContext(ctx, fmt.Sprintf("/vapp/%s", vAppUUID))
 	if err != nil {
 		return vApp, err
 	}
@@ -319,24 +504,34 @@ func (c *Client) GetVApp(vAppUUID string) (VApp, error) {
 	return vApp, nil
 }
 
-func (c *Client) GetVirtualMachines() []VirtualMachine {
-	virtualMachines := []VirtualMachine{}
-	for _, location := range c.GetLocations() {
-		locationVirtualMachines := []VirtualMachine{}
-		data, _ := c.Get(fmt.Sprintf("/location/%s/vms", location.ID))
-		json.Unmarshal(data, &locationVirtualMachines)
-		virtualMachines = append(virtualMachines, locationVirtualMachines...)
+func (c *Client) GetVirtualMachines() ([]VirtualMachine, error) {
+	return c.GetVirtualMachinesContext(context.Background())
+}
+
+func (c *Client) GetVirtualMachinesContext(ctx context.Context) ([]VirtualMachine, error) {
+	locations, err := c.GetLocationsContext(ctx)
+	if err != nil {
+		return []VirtualMachine{}, err
 	}
+	return c.getLocationVirtualMachines(ctx, locations)
+}
+
+func (c *Client) getLocationVirtualMachines(ctx context.Context, locations []Location) ([]VirtualMachine, error) {
+	virtualMachines, err := fanOutList[VirtualMachine](ctx, c, "location", locationIDs(locations), "/location/%s/vms")
 	for i, virtualMachine := range virtualMachines {
 		virtualMachine.client = c
 		virtualMachines[i] = virtualMachine
 	}
-	return virtualMachines
+	return virtualMachines, err
 }
 
 func (c *Client) GetVirtualMachine(virtualMachineUUID string) (VirtualMachine, error) {
+	return c.GetVirtualMachineContext(context.Background(), virtualMachineUUID)
+}
+
+func (c *Client) GetVirtualMachineContext(ctx context.Context, virtualMachineUUID string) (VirtualMachine, error) {
 	virtualMachine := VirtualMachine{}
-	data, err := c.Get(fmt.Sprintf("/vm/%s", virtualMachineUUID))
+	data, err := c.GetContext(ctx, fmt.Sprintf("/vm/%s", virtualMachineUUID))
 	if err != nil {
 		return virtualMachine, err
 	}
@@ -348,24 +543,37 @@ func (c *Client) GetVirtualMachine(virtualMachineUUID string) (VirtualMachine, e
 	return virtualMachine, nil
 }
 
-func (c *Client) GetMedias() []Media {
-	medias := []Media{}
-	for _, catalog := range c.GetCatalogs() {
-		catalogMedia := []Media{}
-		data, _ := c.Get(fmt.Sprintf("/catalog/%s/medias", catalog.UUID))
-		json.Unmarshal(data, &catalogMedia)
-		medias = append(medias, catalogMedia...)
+func (c *Client) GetMedias() ([]Media, error) {
+	return c.GetMediasContext(context.Background())
+}
+
+func (c *Client) GetMediasContext(ctx context.Context) ([]Media, error) {
+	catalogs, err := c.GetCatalogsContext(ctx)
+	listErr := ListError{}
+	if !listErr.merge(err) {
+		return []Media{}, err
 	}
+	medias, err := c.getCatalogMedias(ctx, catalogs)
+	listErr.merge(err)
+	return medias, listErr.errOrNil()
+}
+
+func (c *Client) getCatalogMedias(ctx context.Context, catalogs []Catalog) ([]Media, error) {
+	medias, err := fanOutList[Media](ctx, c, "catalog", catalogUUIDs(catalogs), "/catalog/%s/medias")
 	for i, media := range medias {
 		media.client = c
 		medias[i] = media
 	}
-	return medias
+	return medias, err
 }
 
 func (c *Client) GetMedia(mediaUUID string) (Media, error) {
+	return c.GetMediaContext(context.Background(), mediaUUID)
+}
+
+func (c *Client) GetMediaContext(ctx context.Context, mediaUUID string) (Media, error) {
 	media := Media{}
-	data, err := c.Get(fmt.Sprintf("/media/%s", mediaUUID))
+	data, err := c.GetContext(ctx, fmt.Sprintf("/media/%s", mediaUUID))
 	if err != nil {
 		return media, err
 	}
@@ -378,8 +586,12 @@ func (c *Client) GetMedia(mediaUUID string) (Media, error) {
 }
 
 func (c *Client) GetTask(locationID, taskUUID string) (Task, error) {
+	return c.GetTaskContext(context.Background(), locationID, taskUUID)
+}
+
+func (c *Client) GetTaskContext(ctx context.Context, locationID, taskUUID string) (Task, error) {
 	task := Task{}
-	data, err := c.Get(fmt.Sprintf("/task/%s/%s", locationID, taskUUID))
+	data, err := c.GetContext(ctx, fmt.Sprintf("/task/%s/%s", locationID, taskUUID))
 	if err != nil {
 		return task, err
 	}
@@ -390,15 +602,3 @@ func (c *Client) GetTask(locationID, taskUUID string) (Task, error) {
 	task.client = c
 	return task, nil
 }
-
-func (c Client) waitUntilObjectIsReady(locationID, objectUUID string) {
-	tasks := []Task{}
-	for {
-		data, _ := c.Delete(fmt.Sprintf("/task/%s/entity/%s/active", locationID, objectUUID))
-		json.Unmarshal(data, &tasks)
-		if len(tasks) == 0 {
-			return
-		}
-		time.Sleep(time.Second * 5)
-	}
-}
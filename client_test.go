@@ -0,0 +1,24 @@
+package iland
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTestClient returns a client that sends its API requests to handler,
+// with a token that never needs to be renewed.
+func newTestClient(t *testing.T, handler http.Handler, opts ...ClientOption) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	opts = append([]ClientOption{
+		WithBaseURL(server.URL),
+		WithTokenSource(StaticTokenSource(Token{AccessToken: "token", ExpiresIn: 3600})),
+	}, opts...)
+	client, err := NewClientWithOptions("", "", "", "", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
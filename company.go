@@ -1,6 +1,7 @@
 package iland
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 )
@@ -16,37 +17,68 @@ type Company struct {
 	DeletedDate int    `json:"deleted_date"`
 }
 
-func (c *Company) GetUsers() []User {
+func (c *Company) GetUsers() ([]User, error) {
+	return c.GetUsersContext(context.Background())
+}
+
+func (c *Company) GetUsersContext(ctx context.Context) ([]User, error) {
 	users := []User{}
-	data, _ := c.client.Get(fmt.Sprintf("/companies/%s/users", c.CRM))
-	json.Unmarshal(data, &users)
-	return users
+	data, err := c.client.GetContext(ctx, fmt.Sprintf("/companies/%s/users", c.CRM))
+	if err != nil {
+		return users, err
+	}
+	err = json.Unmarshal(data, &users)
+	if err != nil {
+		return users, err
+	}
+	return users, nil
+}
+
+func (c *Company) GetCloudTenants() ([]CloudTenant, error) {
+	return c.GetCloudTenantsContext(context.Background())
 }
 
-func (c *Company) GetCloudTenants() []CloudTenant {
+func (c *Company) GetCloudTenantsContext(ctx context.Context) ([]CloudTenant, error) {
 	cloudTenants := []CloudTenant{}
-	data, _ := c.client.Get(fmt.Sprintf("/companies/%s/cloud-tenants", c.CRM))
-	json.Unmarshal(data, &cloudTenants)
-	return cloudTenants
+	data, err := c.client.GetContext(ctx, fmt.Sprintf("/companies/%s/cloud-tenants", c.CRM))
+	if err != nil {
+		return cloudTenants, err
+	}
+	err = json.Unmarshal(data, &cloudTenants)
+	if err != nil {
+		return cloudTenants, err
+	}
+	return cloudTenants, nil
+}
+
+func (c *Company) GetSupportTickets() ([]SupportTicket, error) {
+	return c.GetSupportTicketsContext(context.Background())
 }
 
-func (c *Company) GetSupportTickets() []SupportTicket {
+func (c *Company) GetSupportTicketsContext(ctx context.Context) ([]SupportTicket, error) {
 	tickets := []SupportTicket{}
-	data, _ := c.client.Get(fmt.Sprintf("/companies/%s/support-tickets", c.CRM))
-	err := json.Unmarshal(data, &tickets)
+	data, err := c.client.GetContext(ctx, fmt.Sprintf("/companies/%s/support-tickets", c.CRM))
 	if err != nil {
-		fmt.Println(err)
+		return tickets, err
+	}
+	err = json.Unmarshal(data, &tickets)
+	if err != nil {
+		return tickets, err
 	}
 	for i, ticket := range tickets {
 		ticket.client = c.client
 		tickets[i] = ticket
 	}
-	return tickets
+	return tickets, nil
 }
 
 func (c *Company) GetSupportTicket(ticketID int) (SupportTicket, error) {
+	return c.GetSupportTicketContext(context.Background(), ticketID)
+}
+
+func (c *Company) GetSupportTicketContext(ctx context.Context, ticketID int) (SupportTicket, error) {
 	ticket := SupportTicket{}
-	data, err := c.client.Get(fmt.Sprintf("/companies/%s/support-tickets/%d", c.CRM, ticketID))
+	data, err := c.client.GetContext(ctx, fmt.Sprintf("/companies/%s/support-tickets/%d", c.CRM, ticketID))
 	if err != nil {
 		return ticket, err
 	}
@@ -1,9 +1,11 @@
 package iland
 
 const (
-	apiBaseURL = "https://api.ilandcloud.com/ecs"
-	accessURL  = "https://console.ilandcloud.com/auth/realms/iland-core/protocol/openid-connect/token"
-	refreshURL = "https://console.ilandcloud.com/auth/realms/iland-core/protocol/openid-connect/token"
+	defaultBaseURL    = "https://api.ilandcloud.com/ecs"
+	defaultAccessURL  = "https://console.ilandcloud.com/auth/realms/iland-core/protocol/openid-connect/token"
+	defaultRefreshURL = "https://console.ilandcloud.com/auth/realms/iland-core/protocol/openid-connect/token"
+
+	apiMediaType = "application/vnd.ilandcloud.api.v0.8+json"
 
 	TaskStatusSuccess       = "success"
 	TaskStatusRunning       = "running"
@@ -13,6 +15,11 @@ const (
 	TaskStatusWaitingOnUser = "waiting-on-user-input"
 	TaskStatusUnknown       = "unknown"
 
+	IPAddressModeDHCP   = "DHCP"
+	IPAddressModePool   = "POOL"
+	IPAddressModeManual = "MANUAL"
+	IPAddressModeNone   = "NONE"
+
 	perfGroupCPU     = "cpu"
 	perfGroupMemory  = "mem"
 	perfGroupNetwork = "net"
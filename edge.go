@@ -1,6 +1,7 @@
 package iland
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 )
@@ -88,24 +89,31 @@ func (e Edge) GetUplinkInterface() EdgeInterface {
 	return EdgeInterface{}
 }
 
-func (e Edge) GetExternalInterface() EdgeInterface {
+func (e Edge) GetExternalInterface() (EdgeInterface, error) {
+	return e.GetExternalInterfaceContext(context.Background())
+}
+
+func (e Edge) GetExternalInterfaceContext(ctx context.Context) (EdgeInterface, error) {
 	edgeInterface := EdgeInterface{}
-	data, _ := e.client.Get(fmt.Sprintf("/edge/%s/edge-interface", e.UUID))
-	fmt.Println(string(data))
-	err := json.Unmarshal(data, &edgeInterface)
+	data, err := e.client.GetContext(ctx, fmt.Sprintf("/edge/%s/edge-interface", e.UUID))
 	if err != nil {
-		fmt.Println(err)
+		return edgeInterface, err
 	}
-	return edgeInterface
+	err = json.Unmarshal(data, &edgeInterface)
+	return edgeInterface, err
 }
 
 func (e Edge) UpdateExternalInterface(edgeInterface EdgeInterface) (Task, error) {
+	return e.UpdateExternalInterfaceContext(context.Background(), edgeInterface)
+}
+
+func (e Edge) UpdateExternalInterfaceContext(ctx context.Context, edgeInterface EdgeInterface) (Task, error) {
 	task := Task{}
 	output, err := json.Marshal(&edgeInterface)
 	if err != nil {
 		return task, err
 	}
-	data, err := e.client.Put(fmt.Sprintf("/edge/%s/edge-interface", e.UUID), output)
+	data, err := e.client.PutContext(ctx, fmt.Sprintf("/edge/%s/edge-interface", e.UUID), output)
 	if err != nil {
 		return task, err
 	}
@@ -114,8 +122,12 @@ func (e Edge) UpdateExternalInterface(edgeInterface EdgeInterface) (Task, error)
 }
 
 func (e Edge) GetFirewallConfig() (EdgeFirewallConfig, error) {
+	return e.GetFirewallConfigContext(context.Background())
+}
+
+func (e Edge) GetFirewallConfigContext(ctx context.Context) (EdgeFirewallConfig, error) {
 	config := EdgeFirewallConfig{}
-	data, err := e.client.Get(fmt.Sprintf("/edge/%s/firewall", e.UUID))
+	data, err := e.client.GetContext(ctx, fmt.Sprintf("/edge/%s/firewall", e.UUID))
 	if err != nil {
 		return config, err
 	}
@@ -124,13 +136,19 @@ func (e Edge) GetFirewallConfig() (EdgeFirewallConfig, error) {
 }
 
 func (e Edge) UpdateFirewallConfig(config EdgeFirewallConfig) (Task, error) {
-	e.client.waitUntilObjectIsReady(e.LocationID, e.UUID)
+	return e.UpdateFirewallConfigContext(context.Background(), config)
+}
+
+func (e Edge) UpdateFirewallConfigContext(ctx context.Context, config EdgeFirewallConfig) (Task, error) {
+	if err := e.client.waitUntilObjectIsReady(ctx, e.LocationID, e.UUID); err != nil {
+		return Task{}, err
+	}
 	task := Task{}
 	output, err := json.Marshal(&config)
 	if err != nil {
 		return task, err
 	}
-	data, err := e.client.Put(fmt.Sprintf("/edge/%s/firewall", e.UUID), output)
+	data, err := e.client.PutContext(ctx, fmt.Sprintf("/edge/%s/firewall", e.UUID), output)
 	if err != nil {
 		return task, err
 	}
@@ -140,8 +158,12 @@ func (e Edge) UpdateFirewallConfig(config EdgeFirewallConfig) (Task, error) {
 }
 
 func (e Edge) GetNATConfig() (EdgeNATConfig, error) {
+	return e.GetNATConfigContext(context.Background())
+}
+
+func (e Edge) GetNATConfigContext(ctx context.Context) (EdgeNATConfig, error) {
 	config := EdgeNATConfig{}
-	data, err := e.client.Get(fmt.Sprintf("/edge/%s/nat", e.UUID))
+	data, err := e.client.GetContext(ctx, fmt.Sprintf("/edge/%s/nat", e.UUID))
 	if err != nil {
 		return config, err
 	}
@@ -150,13 +172,19 @@ func (e Edge) GetNATConfig() (EdgeNATConfig, error) {
 }
 
 func (e Edge) UpdateNATConfig(config EdgeNATConfig) (Task, error) {
-	e.client.waitUntilObjectIsReady(e.LocationID, e.UUID)
+	return e.UpdateNATConfigContext(context.Background(), config)
+}
+
+func (e Edge) UpdateNATConfigContext(ctx context.Context, config EdgeNATConfig) (Task, error) {
+	if err := e.client.waitUntilObjectIsReady(ctx, e.LocationID, e.UUID); err != nil {
+		return Task{}, err
+	}
 	task := Task{}
 	output, err := json.Marshal(&config)
 	if err != nil {
 		return task, err
 	}
-	data, err := e.client.Put(fmt.Sprintf("/edge/%s/nat", e.UUID), output)
+	data, err := e.client.PutContext(ctx, fmt.Sprintf("/edge/%s/nat", e.UUID), output)
 	if err != nil {
 		return task, err
 	}
@@ -165,7 +193,21 @@ func (e Edge) UpdateNATConfig(config EdgeNATConfig) (Task, error) {
 	return task, err
 }
 
-func (e Edge) GetUsage() {
-	data, _ := e.client.Get(fmt.Sprintf("/edge/%s/usage", e.UUID))
-	fmt.Println(string(data))
+// GetUsage returns the raw JSON usage report of the edge.
+func (e Edge) GetUsage() ([]byte, error) {
+	return e.GetUsageContext(context.Background())
+}
+
+func (e Edge) GetUsageContext(ctx context.Context) ([]byte, error) {
+	return e.client.GetContext(ctx, fmt.Sprintf("/edge/%s/usage", e.UUID))
+}
+
+// IsBusy reports whether the edge has active tasks, in which case the
+// methods that modify it wait or fail, see ReadinessPolicy.
+func (e Edge) IsBusy() (bool, error) {
+	return e.IsBusyContext(context.Background())
+}
+
+func (e Edge) IsBusyContext(ctx context.Context) (bool, error) {
+	return e.client.isBusy(ctx, e.LocationID, e.UUID)
 }
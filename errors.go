@@ -0,0 +1,211 @@
+package iland
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// APIError is returned by every API call that receives a non-2xx response.
+// Use errors.As to inspect it, or one of the Is* helpers below.
+type APIError struct {
+	StatusCode    int    `json:"-"`
+	Method        string `json:"-"`
+	Path          string `json:"-"`
+	Code          string `json:"error"`
+	Message       string `json:"message"`
+	DetailMessage string `json:"detail_message"`
+	Body          []byte `json:"-"`
+	// Attempts is the number of times the request was sent, see RetryPolicy.
+	Attempts int `json:"-"`
+}
+
+func (e *APIError) Error() string {
+	if e.DetailMessage != "" {
+		return e.DetailMessage
+	}
+	if e.Message != "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s %s: %d %s", e.Method, e.Path, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+func newAPIError(verb, relPath string, statusCode int, body []byte) *APIError {
+	e := APIError{}
+	// the body is not always JSON, e.g. a gateway error page, in which case
+	// only the status and the raw body are reported.
+	json.Unmarshal(body, &e)
+	e.StatusCode = statusCode
+	e.Method = verb
+	e.Path = relPath
+	e.Body = body
+	return &e
+}
+
+func hasStatusCode(err error, statusCode int) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == statusCode
+	}
+	return false
+}
+
+// IsNotFound reports whether err is an APIError with status 404.
+func IsNotFound(err error) bool {
+	return hasStatusCode(err, http.StatusNotFound)
+}
+
+// IsConflict reports whether err is an APIError with status 409.
+func IsConflict(err error) bool {
+	return hasStatusCode(err, http.StatusConflict)
+}
+
+// IsUnauthorized reports whether err is an APIError with status 401.
+func IsUnauthorized(err error) bool {
+	return hasStatusCode(err, http.StatusUnauthorized)
+}
+
+// IsForbidden reports whether err is an APIError with status 403.
+func IsForbidden(err error) bool {
+	return hasStatusCode(err, http.StatusForbidden)
+}
+
+// IsRateLimited reports whether err is an APIError with status 429.
+func IsRateLimited(err error) bool {
+	return hasStatusCode(err, http.StatusTooManyRequests)
+}
+
+// ListError is returned by the account-wide listings of Client, which walk
+// every location, org or catalog, when some of them could not be listed.
+// The resources of the others are still returned alongside it.
+type ListError struct {
+	Failures []ScopeError
+}
+
+// ScopeError is the failure to list the resources of a single location,
+// org, vdc or catalog.
+type ScopeError struct {
+	Scope string
+	ID    string
+	Err   error
+}
+
+func (e ScopeError) Error() string {
+	return fmt.Sprintf("%s %s: %s", e.Scope, e.ID, e.Err)
+}
+
+func (e ScopeError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ListError) Error() string {
+	messages := make([]string, len(e.Failures))
+	for i, failure := range e.Failures {
+		messages[i] = failure.Error()
+	}
+	return fmt.Sprintf("listing failed for %d scope(s): %s", len(e.Failures), strings.Join(messages, "; "))
+}
+
+func (e *ListError) Unwrap() []error {
+	errs := make([]error, len(e.Failures))
+	for i, failure := range e.Failures {
+		errs[i] = failure
+	}
+	return errs
+}
+
+func (e *ListError) add(scope, id string, err error) {
+	e.Failures = append(e.Failures, ScopeError{Scope: scope, ID: id, Err: err})
+}
+
+// merge adds the failures of a partial listing that the current listing
+// builds on. It reports false if err is any other error, which the current
+// listing cannot recover from.
+func (e *ListError) merge(err error) bool {
+	if err == nil {
+		return true
+	}
+	var listErr *ListError
+	if errors.As(err, &listErr) {
+		e.Failures = append(e.Failures, listErr.Failures...)
+		return true
+	}
+	return false
+}
+
+func (e *ListError) errOrNil() error {
+	if len(e.Failures) == 0 {
+		return nil
+	}
+	return e
+}
+
+// TaskFailedError is returned by Task.Wait when a task completes with another
+// status than TaskStatusSuccess, e.g. TaskStatusError or TaskStatusCancelled.
+type TaskFailedError struct {
+	Task Task
+}
+
+func (e *TaskFailedError) Error() string {
+	msg := "task " + e.Task.UUID
+	if e.Task.Operation != "" {
+		msg += " (" + e.Task.Operation + ")"
+	}
+	msg += " " + e.Task.Status
+	if e.Task.Message != "" {
+		msg += ": " + e.Task.Message
+	}
+	return msg
+}
+
+// TaskGroupError is returned by TaskGroup.Wait when any of its tasks failed
+// or could not be polled.
+type TaskGroupError struct {
+	Total    int
+	Failures []TaskResult
+}
+
+func (e *TaskGroupError) Error() string {
+	messages := make([]string, len(e.Failures))
+	for i, failure := range e.Failures {
+		messages[i] = failure.Err.Error()
+	}
+	return fmt.Sprintf("%d of %d tasks failed: %s", len(e.Failures), e.Total, strings.Join(messages, "; "))
+}
+
+func (e *TaskGroupError) Unwrap() []error {
+	errs := make([]error, len(e.Failures))
+	for i, failure := range e.Failures {
+		errs[i] = failure.Err
+	}
+	return errs
+}
+
+// ResourceBusyError is returned by the methods that modify a resource when
+// the resource still has active tasks, either right away or after waiting
+// for them, see ReadinessPolicy.
+type ResourceBusyError struct {
+	LocationID string
+	UUID       string
+	// Tasks are the active tasks of the resource when the wait ended.
+	Tasks []Task
+	// Waited is zero if the client did not wait, see ReadinessPolicy.FailFast.
+	Waited time.Duration
+}
+
+func (e *ResourceBusyError) Error() string {
+	msg := fmt.Sprintf("resource %s is busy with %d active task(s)", e.UUID, len(e.Tasks))
+	if e.Waited > 0 {
+		msg += fmt.Sprintf(" after waiting %s", e.Waited)
+	}
+	return msg
+}
+
+// IsResourceBusy reports whether err is or wraps a *ResourceBusyError.
+func IsResourceBusy(err error) bool {
+	var busyErr *ResourceBusyError
+	return errors.As(err, &busyErr)
+}
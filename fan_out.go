@@ -0,0 +1,95 @@
+package iland
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// defaultFanOutConcurrency is the number of scopes an account-wide listing
+// fetches at once unless WithFanOutConcurrency is given.
+const defaultFanOutConcurrency = 8
+
+// fanOutList gets the list at pathFormat for each of the scope IDs, with at
+// most c.fanOutConcurrency requests at once, and concatenates the results
+// in the order of ids. Failed scopes are reported in a *ListError.
+func fanOutList[T any](ctx context.Context, c *Client, scope string, ids []string, pathFormat string) ([]T, error) {
+	results := make([][]T, len(ids))
+	errs := make([]error, len(ids))
+	c.forEach(len(ids), func(i int) {
+		list := []T{}
+		errs[i] = c.getJSON(ctx, fmt.Sprintf(pathFormat, ids[i]), &list)
+		results[i] = list
+	})
+
+	all := []T{}
+	listErr := ListError{}
+	for i, id := range ids {
+		if errs[i] != nil {
+			listErr.add(scope, id, errs[i])
+			continue
+		}
+		all = append(all, results[i]...)
+	}
+	return all, listErr.errOrNil()
+}
+
+// forEach calls fn for each index below n, with at most c.fanOutConcurrency
+// calls at once, and returns once all calls have returned.
+func (c *Client) forEach(n int, fn func(i int)) {
+	workers := c.fanOutConcurrency
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > n {
+		workers = n
+	}
+	jobs := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+func locationIDs(locations []Location) []string {
+	ids := make([]string, len(locations))
+	for i, location := range locations {
+		ids[i] = location.ID
+	}
+	return ids
+}
+
+func orgUUIDs(orgs []Org) []string {
+	ids := make([]string, len(orgs))
+	for i, org := range orgs {
+		ids[i] = org.UUID
+	}
+	return ids
+}
+
+func vdcUUIDs(vdcs []Vdc) []string {
+	ids := make([]string, len(vdcs))
+	for i, vdc := range vdcs {
+		ids[i] = vdc.UUID
+	}
+	return ids
+}
+
+func catalogUUIDs(catalogs []Catalog) []string {
+	ids := make([]string, len(catalogs))
+	for i, catalog := range catalogs {
+		ids[i] = catalog.UUID
+	}
+	return ids
+}
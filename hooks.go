@@ -0,0 +1,116 @@
+package iland
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Hooks observe the API requests sent by a Client. Each field is optional.
+// Hooks registered with WithHooks form a chain that is called in
+// registration order for every attempt of every request, including retries,
+// and for every poll and token renewal.
+type Hooks struct {
+	// BeforeRequest may modify the request before it is sent, e.g. add a
+	// header, or return it with a derived context.
+	BeforeRequest func(req *http.Request) *http.Request
+	// AfterResponse is called once the attempt has completed, with the
+	// request returned by BeforeRequest. The response body must not be read.
+	AfterResponse func(req *http.Request, result RequestResult)
+	// StartPolling is called when the client starts polling a task until it
+	// completes or an entity until it has no active tasks. The requests sent
+	// while polling use the returned context, and the returned function is
+	// called with the outcome once polling stops.
+	StartPolling func(ctx context.Context, poll Poll) (context.Context, func(err error))
+	// TokenRenewed is called after every attempt to obtain a new API token.
+	TokenRenewed func(ctx context.Context, err error)
+}
+
+const (
+	PollTask      = "task"
+	PollTaskGroup = "task group"
+	PollEntity    = "entity"
+)
+
+// Poll describes what the client is polling, see Hooks.StartPolling.
+type Poll struct {
+	// Kind is PollTask, PollTaskGroup or PollEntity. The location and UUID
+	// of a task group are empty.
+	Kind       string
+	LocationID string
+	UUID       string
+}
+
+// RequestResult is the outcome of one attempt of an API request.
+type RequestResult struct {
+	// Path is the path of the request relative to the API base URL.
+	Path string
+	// Attempt counts the attempts of the request, starting at 1.
+	Attempt  int
+	Duration time.Duration
+	// Response is nil if the request failed without a response, in which
+	// case Err is set.
+	Response *http.Response
+	Err      error
+	// Retrying reports whether the request will be attempted again, see
+	// RetryPolicy.
+	Retrying bool
+}
+
+// StatusCode returns the status of the response, or 0 if there is none.
+func (r RequestResult) StatusCode() int {
+	if r.Response == nil {
+		return 0
+	}
+	return r.Response.StatusCode
+}
+
+// WithHooks appends hooks to the client's chain of request hooks.
+func WithHooks(hooks Hooks) ClientOption {
+	return func(c *Client) {
+		c.hooks = append(c.hooks, hooks)
+	}
+}
+
+func (c *Client) beforeRequest(req *http.Request) *http.Request {
+	for _, hooks := range c.hooks {
+		if hooks.BeforeRequest != nil {
+			req = hooks.BeforeRequest(req)
+		}
+	}
+	return req
+}
+
+func (c *Client) afterResponse(req *http.Request, result RequestResult) {
+	for _, hooks := range c.hooks {
+		if hooks.AfterResponse != nil {
+			hooks.AfterResponse(req, result)
+		}
+	}
+}
+
+func (c *Client) startPolling(ctx context.Context, poll Poll) (context.Context, func(err error)) {
+	ends := []func(err error){}
+	for _, hooks := range c.hooks {
+		if hooks.StartPolling != nil {
+			var end func(err error)
+			ctx, end = hooks.StartPolling(ctx, poll)
+			if end != nil {
+				ends = append(ends, end)
+			}
+		}
+	}
+	return ctx, func(err error) {
+		for i := len(ends) - 1; i >= 0; i-- {
+			ends[i](err)
+		}
+	}
+}
+
+func (c *Client) tokenRenewed(ctx context.Context, err error) {
+	for _, hooks := range c.hooks {
+		if hooks.TokenRenewed != nil {
+			hooks.TokenRenewed(ctx, err)
+		}
+	}
+}
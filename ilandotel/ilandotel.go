@@ -0,0 +1,264 @@
+// Package ilandotel instruments an iland Client with OpenTelemetry.
+//
+//	client, err := iland.NewClientWithOptions(username, password, clientID, clientSecret,
+//		ilandotel.WithTelemetry(),
+//	)
+//
+// Every attempt of every API request gets its own span, nested under the
+// context passed to the client's Context methods, and polling a task or an
+// entity gets a span of its own that the polling requests are nested under.
+package ilandotel
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	iland "github.com/jrperry/golang-sdk"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/jrperry/golang-sdk/ilandotel"
+
+const (
+	EndpointKey     = attribute.Key("iland.endpoint")
+	ResourceUUIDKey = attribute.Key("iland.resource.uuid")
+	LocationKey     = attribute.Key("iland.location")
+	AttemptKey      = attribute.Key("iland.attempt")
+	RetryingKey     = attribute.Key("iland.retrying")
+	PollKindKey     = attribute.Key("iland.poll.kind")
+	OutcomeKey      = attribute.Key("iland.outcome")
+)
+
+type config struct {
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+}
+
+// Option configures the instrumentation.
+type Option func(*config)
+
+// WithTracerProvider sets the provider of the tracer, the global one by
+// default.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tracerProvider = provider
+	}
+}
+
+// WithMeterProvider sets the provider of the meter, the global one by
+// default.
+func WithMeterProvider(provider metric.MeterProvider) Option {
+	return func(c *config) {
+		c.meterProvider = provider
+	}
+}
+
+// WithTelemetry returns a client option that traces and measures the API
+// requests, polls and token renewals of the client.
+func WithTelemetry(opts ...Option) iland.ClientOption {
+	return iland.WithHooks(Hooks(opts...))
+}
+
+// Hooks returns the client hooks that WithTelemetry installs, for callers
+// that combine them with their own.
+func Hooks(opts ...Option) iland.Hooks {
+	c := config{
+		tracerProvider: otel.GetTracerProvider(),
+		meterProvider:  otel.GetMeterProvider(),
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	i := newInstrumentation(c)
+	return iland.Hooks{
+		BeforeRequest: i.beforeRequest,
+		AfterResponse: i.afterResponse,
+		StartPolling:  i.startPolling,
+		TokenRenewed:  i.tokenRenewed,
+	}
+}
+
+type instrumentation struct {
+	tracer        trace.Tracer
+	duration      metric.Float64Histogram
+	requests      metric.Int64Counter
+	errors        metric.Int64Counter
+	retries       metric.Int64Counter
+	pollDuration  metric.Float64Histogram
+	tokenRenewals metric.Int64Counter
+}
+
+func newInstrumentation(c config) *instrumentation {
+	meter := c.meterProvider.Meter(instrumentationName)
+	i := &instrumentation{
+		tracer: c.tracerProvider.Tracer(instrumentationName),
+	}
+	// the constructors return a no-op instrument along with any error, so
+	// a misconfigured meter never breaks the client.
+	i.duration, _ = meter.Float64Histogram("iland.client.request.duration",
+		metric.WithDescription("Duration of the attempts of iland API requests."),
+		metric.WithUnit("s"))
+	i.requests, _ = meter.Int64Counter("iland.client.requests",
+		metric.WithDescription("Number of attempts of iland API requests."),
+		metric.WithUnit("{request}"))
+	i.errors, _ = meter.Int64Counter("iland.client.errors",
+		metric.WithDescription("Number of attempts of iland API requests that failed or returned an error status."),
+		metric.WithUnit("{request}"))
+	i.retries, _ = meter.Int64Counter("iland.client.retries",
+		metric.WithDescription("Number of attempts of iland API requests that are retried."),
+		metric.WithUnit("{request}"))
+	i.pollDuration, _ = meter.Float64Histogram("iland.client.poll.duration",
+		metric.WithDescription("Time spent polling tasks and entities until they are done."),
+		metric.WithUnit("s"))
+	i.tokenRenewals, _ = meter.Int64Counter("iland.client.token.renewals",
+		metric.WithDescription("Number of attempts to obtain a new API token."),
+		metric.WithUnit("{renewal}"))
+	return i
+}
+
+func (i *instrumentation) beforeRequest(req *http.Request) *http.Request {
+	// the span is named after the endpoint once it is known, see
+	// afterResponse.
+	ctx, _ := i.tracer.Start(req.Context(), "iland "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("http.request.method", req.Method)),
+	)
+	return req.WithContext(ctx)
+}
+
+func (i *instrumentation) afterResponse(req *http.Request, result iland.RequestResult) {
+	ctx := req.Context()
+	endpoint := EndpointTemplate(result.Path)
+	attrs := []attribute.KeyValue{
+		attribute.String("http.request.method", req.Method),
+		EndpointKey.String(endpoint),
+		attribute.Int("http.response.status_code", result.StatusCode()),
+	}
+	failed := result.Err != nil || result.StatusCode() >= 300
+	opt := metric.WithAttributes(attrs...)
+	i.requests.Add(ctx, 1, opt)
+	i.duration.Record(ctx, result.Duration.Seconds(), opt)
+	if failed {
+		i.errors.Add(ctx, 1, opt)
+	}
+	if result.Retrying {
+		i.retries.Add(ctx, 1, opt)
+	}
+
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		span.End()
+		return
+	}
+	span.SetName("iland " + req.Method + " " + endpoint)
+	span.SetAttributes(attrs...)
+	span.SetAttributes(AttemptKey.Int(result.Attempt), RetryingKey.Bool(result.Retrying))
+	if uuid := ResourceUUID(result.Path); uuid != "" {
+		span.SetAttributes(ResourceUUIDKey.String(uuid))
+	}
+	switch {
+	case result.Err != nil:
+		span.RecordError(result.Err)
+		span.SetStatus(codes.Error, result.Err.Error())
+	case failed:
+		span.SetStatus(codes.Error, http.StatusText(result.StatusCode()))
+	}
+	span.End()
+}
+
+func (i *instrumentation) startPolling(ctx context.Context, poll iland.Poll) (context.Context, func(err error)) {
+	start := time.Now()
+	ctx, span := i.tracer.Start(ctx, "iland poll "+poll.Kind,
+		trace.WithAttributes(
+			PollKindKey.String(poll.Kind),
+			LocationKey.String(poll.LocationID),
+			ResourceUUIDKey.String(poll.UUID),
+		),
+	)
+	return ctx, func(err error) {
+		i.pollDuration.Record(ctx, time.Since(start).Seconds(),
+			metric.WithAttributes(PollKindKey.String(poll.Kind), OutcomeKey.String(outcome(err))))
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+func (i *instrumentation) tokenRenewed(ctx context.Context, err error) {
+	i.tokenRenewals.Add(ctx, 1, metric.WithAttributes(OutcomeKey.String(outcome(err))))
+	if err != nil {
+		trace.SpanFromContext(ctx).AddEvent("iland token renewal failed",
+			trace.WithAttributes(attribute.String("error.message", err.Error())))
+	}
+}
+
+func outcome(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// parameterAfter names the path segments that follow a fixed segment but are
+// neither UUIDs nor numbers, such as usernames and location IDs.
+var parameterAfter = map[string]string{
+	"user":      "{username}",
+	"users":     "{username}",
+	"companies": "{company}",
+	"location":  "{location}",
+	"locations": "{location}",
+	"task":      "{location}",
+	"tasks":     "{location}",
+}
+
+// EndpointTemplate returns relPath with its query removed and its resource
+// identifiers replaced by placeholders, e.g. /vm/{uuid}/poweron, so that it
+// can name spans and label metrics without unbounded cardinality.
+func EndpointTemplate(relPath string) string {
+	relPath, _, _ = strings.Cut(relPath, "?")
+	segments := strings.Split(relPath, "/")
+	for n, segment := range segments {
+		switch {
+		case segment == "":
+		case uuidPattern.MatchString(segment):
+			segments[n] = "{uuid}"
+		case isNumber(segment):
+			segments[n] = "{id}"
+		case n > 0 && parameterAfter[segments[n-1]] != "":
+			segments[n] = parameterAfter[segments[n-1]]
+		case strings.Contains(segment, "."):
+			segments[n] = "{location}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// ResourceUUID returns the last UUID in relPath, the resource the request
+// is about, or "" if there is none.
+func ResourceUUID(relPath string) string {
+	relPath, _, _ = strings.Cut(relPath, "?")
+	segments := strings.Split(relPath, "/")
+	for n := len(segments) - 1; n >= 0; n-- {
+		if uuidPattern.MatchString(segments[n]) {
+			return segments[n]
+		}
+	}
+	return ""
+}
+
+func isNumber(segment string) bool {
+	_, err := strconv.ParseUint(segment, 10, 64)
+	return err == nil
+}
@@ -0,0 +1,211 @@
+package ilandotel
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	iland "github.com/jrperry/golang-sdk"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	testLocation = "dal02.ilandcloud.com"
+	testVM       = "0a0b0c0d-1111-2222-3333-444455556666"
+	testTask     = "1a1b1c1d-1111-2222-3333-444455556666"
+)
+
+func TestEndpointTemplate(t *testing.T) {
+	tests := []struct {
+		path     string
+		template string
+		uuid     string
+	}{
+		{"/task/" + testLocation + "/" + testTask, "/task/{location}/{uuid}", testTask},
+		{"/tasks/" + testLocation, "/tasks/{location}", ""},
+		{"/task/" + testLocation + "/entity/" + testVM, "/task/{location}/entity/{uuid}", testVM},
+		{"/task/" + testLocation + "/org/" + testVM + "/active", "/task/{location}/org/{uuid}/active", testVM},
+		{"/location/" + testLocation + "/vms", "/location/{location}/vms", ""},
+		{"/locations/dal02/orgs", "/locations/{location}/orgs", ""},
+		{"/vdc/" + testVM + "/" + testLocation, "/vdc/{uuid}/{location}", testVM},
+		{"/user/jdoe/inventory", "/user/{username}/inventory", ""},
+		{"/users/john.doe", "/users/{username}", ""},
+		{"/companies/acme/locations/" + testLocation, "/companies/{company}/locations/{location}", ""},
+		{"/companies/12345", "/companies/{id}", ""},
+		{"/edge/" + testVM + "/firewall/rules/1024", "/edge/{uuid}/firewall/rules/{id}", testVM},
+		{"/vm/" + strings.ToUpper(testVM) + "/poweron", "/vm/{uuid}/poweron", strings.ToUpper(testVM)},
+		{"/vm/" + testVM + "/poweron?force=true&task=" + testTask, "/vm/{uuid}/poweron", testVM},
+		{"/vapp/" + testVM + "/vms/" + testTask, "/vapp/{uuid}/vms/{uuid}", testTask},
+		{"/vm/not-a-uuid", "/vm/not-a-uuid", ""},
+		{"/orgs?expand=true", "/orgs", ""},
+		{"", "", ""},
+	}
+	for _, test := range tests {
+		if got := EndpointTemplate(test.path); got != test.template {
+			t.Errorf("EndpointTemplate(%q) = %q, want %q", test.path, got, test.template)
+		}
+		if got := ResourceUUID(test.path); got != test.uuid {
+			t.Errorf("ResourceUUID(%q) = %q, want %q", test.path, got, test.uuid)
+		}
+	}
+}
+
+// fakeAPI serves a VM that is not busy, and a power on task that is done
+// after its third poll.
+type fakeAPI struct {
+	mu    sync.Mutex
+	polls int
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.URL.Path {
+	case "/vm/" + testVM:
+		fmt.Fprintf(w, `{"uuid":%q,"location_id":%q}`, testVM, testLocation)
+	case "/task/" + testLocation + "/entity/" + testVM:
+		fmt.Fprint(w, `[]`)
+	case "/vm/" + testVM + "/poweron":
+		fmt.Fprintf(w, `{"uuid":%q,"location_id":%q,"status":"queued","active":true}`, testTask, testLocation)
+	case "/task/" + testLocation + "/" + testTask:
+		f.polls++
+		if f.polls < 3 {
+			fmt.Fprintf(w, `{"uuid":%q,"location_id":%q,"status":"running","active":true}`, testTask, testLocation)
+			return
+		}
+		fmt.Fprintf(w, `{"uuid":%q,"location_id":%q,"status":"success","active":false,"synchronized":true}`, testTask, testLocation)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestTelemetry(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+	reader := sdkmetric.NewManualReader()
+	meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	server := httptest.NewServer(&fakeAPI{})
+	defer server.Close()
+	client, err := iland.NewClientWithOptions("", "", "", "",
+		iland.WithBaseURL(server.URL),
+		iland.WithTokenSource(iland.StaticTokenSource(iland.Token{AccessToken: "token"})),
+		WithTelemetry(WithTracerProvider(tracerProvider), WithMeterProvider(meterProvider)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, caller := tracerProvider.Tracer("test").Start(context.Background(), "caller")
+	vm, err := client.GetVirtualMachineContext(ctx, testVM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task, err := vm.PowerOnContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := task.Wait(ctx, iland.WaitOptions{PollInterval: time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	caller.End()
+
+	byName := map[string][]sdktrace.ReadOnlySpan{}
+	for _, span := range spans.Ended() {
+		byName[span.Name()] = append(byName[span.Name()], span)
+	}
+	only := func(name string) sdktrace.ReadOnlySpan {
+		t.Helper()
+		if len(byName[name]) != 1 {
+			t.Fatalf("%d spans named %q, want 1, got %v", len(byName[name]), name, byName)
+		}
+		return byName[name][0]
+	}
+	callerID := caller.SpanContext().SpanID()
+	taskPoll := only("iland poll task")
+	entityPoll := only("iland poll entity")
+	tests := []struct {
+		name   string
+		count  int
+		parent trace.SpanID
+	}{
+		{"iland poll task", 1, callerID},
+		{"iland poll entity", 1, callerID},
+		{"iland GET /vm/{uuid}", 1, callerID},
+		{"iland POST /vm/{uuid}/poweron", 1, callerID},
+		{"iland GET /task/{location}/entity/{uuid}", 1, entityPoll.SpanContext().SpanID()},
+		{"iland GET /task/{location}/{uuid}", 3, taskPoll.SpanContext().SpanID()},
+	}
+	for _, test := range tests {
+		if len(byName[test.name]) != test.count {
+			t.Errorf("%d spans named %q, want %d", len(byName[test.name]), test.name, test.count)
+		}
+		for _, span := range byName[test.name] {
+			if span.Parent().SpanID() != test.parent {
+				t.Errorf("span %q is not nested under the expected span", test.name)
+			}
+			if span.SpanContext().TraceID() != caller.SpanContext().TraceID() {
+				t.Errorf("span %q is in another trace", test.name)
+			}
+		}
+	}
+	for _, span := range byName["iland GET /task/{location}/{uuid}"] {
+		if !hasAttribute(span.Attributes(), ResourceUUIDKey.String(testTask)) || !hasAttribute(span.Attributes(), EndpointKey.String("/task/{location}/{uuid}")) {
+			t.Errorf("poll request attributes = %v", span.Attributes())
+		}
+	}
+	if attrs := taskPoll.Attributes(); !hasAttribute(attrs, PollKindKey.String(iland.PollTask)) || !hasAttribute(attrs, LocationKey.String(testLocation)) || !hasAttribute(attrs, ResourceUUIDKey.String(testTask)) {
+		t.Errorf("poll attributes = %v", attrs)
+	}
+
+	metrics := metricdata.ResourceMetrics{}
+	if err := reader.Collect(context.Background(), &metrics); err != nil {
+		t.Fatal(err)
+	}
+	requests := int64(0)
+	polls := map[string]uint64{}
+	for _, scope := range metrics.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				if m.Name == "iland.client.requests" {
+					for _, point := range data.DataPoints {
+						requests += point.Value
+					}
+				}
+			case metricdata.Histogram[float64]:
+				if m.Name == "iland.client.poll.duration" {
+					for _, point := range data.DataPoints {
+						kind, _ := point.Attributes.Value(PollKindKey)
+						outcome, _ := point.Attributes.Value(OutcomeKey)
+						polls[kind.AsString()+" "+outcome.AsString()] += point.Count
+					}
+				}
+			}
+		}
+	}
+	if requests != 6 {
+		t.Errorf("%d requests measured, want 6", requests)
+	}
+	if want := map[string]uint64{"task success": 1, "entity success": 1}; fmt.Sprint(polls) != fmt.Sprint(want) {
+		t.Errorf("polls measured = %v, want %v", polls, want)
+	}
+}
+
+func hasAttribute(attrs []attribute.KeyValue, want attribute.KeyValue) bool {
+	for _, attr := range attrs {
+		if attr == want {
+			return true
+		}
+	}
+	return false
+}
@@ -1,8 +1,10 @@
 package iland
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 )
 
 type Location struct {
@@ -13,8 +15,12 @@ type Location struct {
 }
 
 func (l Location) GetOrgs() ([]Org, error) {
+	return l.GetOrgsContext(context.Background())
+}
+
+func (l Location) GetOrgsContext(ctx context.Context) ([]Org, error) {
 	orgs := []Org{}
-	data, err := l.client.Get(fmt.Sprintf("/location/%s/orgs", l.ID))
+	data, err := l.client.GetContext(ctx, fmt.Sprintf("/location/%s/orgs", l.ID))
 	if err != nil {
 		return orgs, err
 	}
@@ -23,8 +29,12 @@ func (l Location) GetOrgs() ([]Org, error) {
 }
 
 func (l Location) GetPublicVAppTemplates() ([]VAppTemplate, error) {
+	return l.GetPublicVAppTemplatesContext(context.Background())
+}
+
+func (l Location) GetPublicVAppTemplatesContext(ctx context.Context) ([]VAppTemplate, error) {
 	vAppTemplates := []VAppTemplate{}
-	data, err := l.client.Get(fmt.Sprintf("/location/%s/public-vapp-templates", l.ID))
+	data, err := l.client.GetContext(ctx, fmt.Sprintf("/location/%s/public-vapp-templates", l.ID))
 	if err != nil {
 		return vAppTemplates, err
 	}
@@ -33,8 +43,12 @@ func (l Location) GetPublicVAppTemplates() ([]VAppTemplate, error) {
 }
 
 func (l Location) GetEntityActiveTasks(entityUUID string) ([]Task, error) {
+	return l.GetEntityActiveTasksContext(context.Background(), entityUUID)
+}
+
+func (l Location) GetEntityActiveTasksContext(ctx context.Context, entityUUID string) ([]Task, error) {
 	tasks := []Task{}
-	data, err := l.client.Get(fmt.Sprintf("/task/%s/entity/%s", l.ID, entityUUID))
+	data, err := l.client.GetContext(ctx, fmt.Sprintf("/task/%s/entity/%s", l.ID, entityUUID))
 	if err != nil {
 		return tasks, err
 	}
@@ -48,3 +62,23 @@ func (l Location) GetEntityActiveTasks(entityUUID string) ([]Task, error) {
 	}
 	return tasks, nil
 }
+
+// GetEntityTaskHistory returns the tasks of the entity initiated since the
+// given time, including completed ones.
+func (l Location) GetEntityTaskHistory(entityUUID string, since time.Time) ([]Task, error) {
+	return l.GetEntityTaskHistoryContext(context.Background(), entityUUID, since)
+}
+
+func (l Location) GetEntityTaskHistoryContext(ctx context.Context, entityUUID string, since time.Time) ([]Task, error) {
+	tasks := []Task{}
+	filter := TaskHistoryFilter{Since: since}
+	data, err := l.client.GetContext(ctx, fmt.Sprintf("/task/%s/entity/%s/historical%s", l.ID, entityUUID, filter.query()))
+	if err != nil {
+		return tasks, err
+	}
+	err = json.Unmarshal(data, &tasks)
+	if err != nil {
+		return tasks, err
+	}
+	return filterTasks(l.client, tasks, filter), nil
+}
@@ -0,0 +1,78 @@
+package iland
+
+import (
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// sensitiveHeaders are never logged in clear text.
+var sensitiveHeaders = map[string]bool{
+	"Authorization": true,
+	"Cookie":        true,
+	"Set-Cookie":    true,
+}
+
+// WithLogger makes the client log every attempt of every API request to
+// logger: its method, path, status, latency and attempt number. Successful
+// requests are logged at debug level along with their headers, failed ones
+// at warn level. Credentials and tokens are redacted.
+func WithLogger(logger *slog.Logger) ClientOption {
+	return WithHooks(Hooks{
+		AfterResponse: func(req *http.Request, result RequestResult) {
+			logRequest(logger, req, result)
+		},
+	})
+}
+
+func logRequest(logger *slog.Logger, req *http.Request, result RequestResult) {
+	level := slog.LevelDebug
+	if result.Err != nil || result.StatusCode() >= 300 {
+		level = slog.LevelWarn
+	}
+	ctx := req.Context()
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("path", result.Path),
+		slog.Int("status", result.StatusCode()),
+		slog.Duration("latency", result.Duration),
+		slog.Int("attempt", result.Attempt),
+		slog.Bool("retrying", result.Retrying),
+	}
+	if result.Err != nil {
+		attrs = append(attrs, slog.String("error", result.Err.Error()))
+	}
+	if level == slog.LevelDebug {
+		attrs = append(attrs, slog.Any("headers", redactedHeader(req.Header)))
+	}
+	logger.LogAttrs(ctx, level, "iland api request", attrs...)
+}
+
+// redactedHeader logs a request header with the values of sensitiveHeaders
+// replaced, keeping only the scheme of the Authorization header.
+type redactedHeader http.Header
+
+func (h redactedHeader) LogValue() slog.Value {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	attrs := make([]slog.Attr, 0, len(h))
+	for _, name := range names {
+		value := strings.Join(h[name], ", ")
+		switch {
+		case http.CanonicalHeaderKey(name) == "Authorization":
+			scheme, _, _ := strings.Cut(value, " ")
+			value = scheme + " [REDACTED]"
+		case sensitiveHeaders[http.CanonicalHeaderKey(name)]:
+			value = "[REDACTED]"
+		}
+		attrs = append(attrs, slog.String(name, value))
+	}
+	return slog.GroupValue(attrs...)
+}
@@ -0,0 +1,150 @@
+package iland
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ClientOption configures a Client created with NewClientWithOptions.
+type ClientOption func(*Client)
+
+// WithBaseURL overrides the iland cloud API base URL, e.g. to target a
+// regional endpoint or a local stand-in server.
+func WithBaseURL(baseURL string) ClientOption {
+	return func(c *Client) {
+		c.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithAuthURL overrides both the token and the token refresh endpoints.
+func WithAuthURL(authURL string) ClientOption {
+	return func(c *Client) {
+		c.accessURL = authURL
+		c.refreshURL = authURL
+	}
+}
+
+// WithAccessURL overrides the endpoint used to obtain a new token.
+func WithAccessURL(accessURL string) ClientOption {
+	return func(c *Client) {
+		c.accessURL = accessURL
+	}
+}
+
+// WithRefreshURL overrides the endpoint used to refresh an existing token.
+func WithRefreshURL(refreshURL string) ClientOption {
+	return func(c *Client) {
+		c.refreshURL = refreshURL
+	}
+}
+
+// WithHTTPClient makes the Client send every request through httpClient.
+// WithTransport, WithTLSConfig and WithProxy are ignored when it is set.
+func WithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithTransport sets the round tripper used by the Client's http.Client.
+// WithTLSConfig and WithProxy are ignored when it is set.
+func WithTransport(transport http.RoundTripper) ClientOption {
+	return func(c *Client) {
+		c.transport = transport
+	}
+}
+
+// WithTLSConfig sets the TLS configuration of the default transport.
+func WithTLSConfig(tlsConfig *tls.Config) ClientOption {
+	return func(c *Client) {
+		c.tlsConfig = tlsConfig
+	}
+}
+
+// WithProxy routes every request of the default transport through proxyURL.
+func WithProxy(proxyURL *url.URL) ClientOption {
+	return func(c *Client) {
+		c.proxy = http.ProxyURL(proxyURL)
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request.
+func WithUserAgent(userAgent string) ClientOption {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+// WithRetryPolicy replaces DefaultRetryPolicy. Pass RetryPolicy{} to
+// disable retries altogether.
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(c *Client) {
+		c.retryPolicy = policy
+	}
+}
+
+// WithRateLimit limits the client to requestsPerSecond requests on average,
+// allowing bursts of up to burst requests. Retries count as requests.
+func WithRateLimit(requestsPerSecond float64, burst int) ClientOption {
+	return func(c *Client) {
+		if requestsPerSecond > 0 {
+			c.rateLimiter = newRateLimiter(requestsPerSecond, burst)
+		}
+	}
+}
+
+// WithMaxInFlight caps the number of requests the client has in flight at
+// once, across all goroutines sharing it.
+func WithMaxInFlight(maxInFlight int) ClientOption {
+	return func(c *Client) {
+		if maxInFlight > 0 {
+			c.inFlight = make(chan struct{}, maxInFlight)
+		}
+	}
+}
+
+// WithTokenSource makes the client authenticate with the tokens of source
+// instead of performing the password grant with its credentials.
+func WithTokenSource(source TokenSource) ClientOption {
+	return func(c *Client) {
+		c.tokenSource = source
+	}
+}
+
+// WithTokenCache makes the client reuse the tokens stored in cache by
+// earlier processes with the same credentials, and store the tokens it
+// obtains in turn. It has no effect together with WithTokenSource.
+func WithTokenCache(cache TokenCache) ClientOption {
+	return func(c *Client) {
+		c.tokenCache = cache
+	}
+}
+
+// WithFanOutConcurrency sets how many locations, orgs, vdcs or catalogs the
+// account-wide listings of the client fetch at once. The default is 8;
+// 1 lists them one after the other.
+func WithFanOutConcurrency(concurrency int) ClientOption {
+	return func(c *Client) {
+		c.fanOutConcurrency = concurrency
+	}
+}
+
+func (c *Client) buildHTTPClient() {
+	if c.httpClient != nil {
+		return
+	}
+	transport := c.transport
+	if transport == nil {
+		defaultTransport := http.DefaultTransport.(*http.Transport).Clone()
+		if c.tlsConfig != nil {
+			defaultTransport.TLSClientConfig = c.tlsConfig
+		}
+		if c.proxy != nil {
+			defaultTransport.Proxy = c.proxy
+		}
+		transport = defaultTransport
+	}
+	c.httpClient = &http.Client{Transport: transport}
+}
@@ -1,6 +1,7 @@
 package iland
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 )
@@ -19,123 +20,254 @@ type Org struct {
 	UpdatedDate int    `json:"updated_date"`
 }
 
-func (o Org) GetCatalogs() []Catalog {
+func (o Org) GetCatalogs() ([]Catalog, error) {
+	return o.GetCatalogsContext(context.Background())
+}
+
+func (o Org) GetCatalogsContext(ctx context.Context) ([]Catalog, error) {
 	catalogs := []Catalog{}
-	data, _ := o.client.Get(fmt.Sprintf("/org/%s/catalogs", o.UUID))
-	json.Unmarshal(data, &catalogs)
+	data, err := o.client.GetContext(ctx, fmt.Sprintf("/org/%s/catalogs", o.UUID))
+	if err != nil {
+		return catalogs, err
+	}
+	err = json.Unmarshal(data, &catalogs)
+	if err != nil {
+		return catalogs, err
+	}
 	for i, catalog := range catalogs {
 		catalog.client = o.client
 		catalogs[i] = catalog
 	}
-	return catalogs
+	return catalogs, nil
 }
 
-func (o Org) GetVdcs() []Vdc {
+func (o Org) GetVdcs() ([]Vdc, error) {
+	return o.GetVdcsContext(context.Background())
+}
+
+func (o Org) GetVdcsContext(ctx context.Context) ([]Vdc, error) {
 	vdcs := []Vdc{}
-	data, _ := o.client.Get(fmt.Sprintf("/org/%s/vdcs", o.UUID))
-	json.Unmarshal(data, &vdcs)
+	data, err := o.client.GetContext(ctx, fmt.Sprintf("/org/%s/vdcs", o.UUID))
+	if err != nil {
+		return vdcs, err
+	}
+	err = json.Unmarshal(data, &vdcs)
+	if err != nil {
+		return vdcs, err
+	}
 	for i, vdc := range vdcs {
 		vdc.client = o.client
 		vdcs[i] = vdc
 	}
-	return vdcs
+	return vdcs, nil
+}
+
+func (o Org) GetEdges() ([]Edge, error) {
+	return o.GetEdgesContext(context.Background())
 }
 
-func (o Org) GetEdges() []Edge {
+func (o Org) GetEdgesContext(ctx context.Context) ([]Edge, error) {
 	edges := []Edge{}
-	data, _ := o.client.Get(fmt.Sprintf("/org/%s/edges", o.UUID))
-	json.Unmarshal(data, &edges)
+	data, err := o.client.GetContext(ctx, fmt.Sprintf("/org/%s/edges", o.UUID))
+	if err != nil {
+		return edges, err
+	}
+	err = json.Unmarshal(data, &edges)
+	if err != nil {
+		return edges, err
+	}
 	for i, edge := range edges {
 		edge.client = o.client
 		edges[i] = edge
 	}
-	return edges
+	return edges, nil
+}
+
+func (o Org) GetVdcNetworks() ([]VdcNetwork, error) {
+	return o.GetVdcNetworksContext(context.Background())
 }
 
-func (o Org) GetVdcNetworks() []VdcNetwork {
+func (o Org) GetVdcNetworksContext(ctx context.Context) ([]VdcNetwork, error) {
 	vdcNetworks := []VdcNetwork{}
-	data, _ := o.client.Get(fmt.Sprintf("/org/%s/vdc-networks", o.UUID))
-	json.Unmarshal(data, &vdcNetworks)
+	data, err := o.client.GetContext(ctx, fmt.Sprintf("/org/%s/vdc-networks", o.UUID))
+	if err != nil {
+		return vdcNetworks, err
+	}
+	err = json.Unmarshal(data, &vdcNetworks)
+	if err != nil {
+		return vdcNetworks, err
+	}
 	for i, vdcNetwork := range vdcNetworks {
 		vdcNetwork.client = o.client
 		vdcNetworks[i] = vdcNetwork
 	}
-	return vdcNetworks
+	return vdcNetworks, nil
 }
 
-func (o Org) GetVAppTemplates() []VAppTemplate {
+func (o Org) GetVAppTemplates() ([]VAppTemplate, error) {
+	return o.GetVAppTemplatesContext(context.Background())
+}
+
+func (o Org) GetVAppTemplatesContext(ctx context.Context) ([]VAppTemplate, error) {
 	vAppTemplates := []VAppTemplate{}
-	data, _ := o.client.Get(fmt.Sprintf("/org/%s/vapp-templates", o.UUID))
-	json.Unmarshal(data, &vAppTemplates)
+	data, err := o.client.GetContext(ctx, fmt.Sprintf("/org/%s/vapp-templates", o.UUID))
+	if err != nil {
+		return vAppTemplates, err
+	}
+	err = json.Unmarshal(data, &vAppTemplates)
+	if err != nil {
+		return vAppTemplates, err
+	}
 	for i, vAppTemplate := range vAppTemplates {
 		vAppTemplate.client = o.client
 		vAppTemplates[i] = vAppTemplate
 	}
-	return vAppTemplates
+	return vAppTemplates, nil
 }
 
-func (o Org) GetMedias() []Media {
+func (o Org) GetMedias() ([]Media, error) {
+	return o.GetMediasContext(context.Background())
+}
+
+func (o Org) GetMediasContext(ctx context.Context) ([]Media, error) {
 	medias := []Media{}
-	data, _ := o.client.Get(fmt.Sprintf("/org/%s/medias", o.UUID))
-	json.Unmarshal(data, &medias)
+	data, err := o.client.GetContext(ctx, fmt.Sprintf("/org/%s/medias", o.UUID))
+	if err != nil {
+		return medias, err
+	}
+	err = json.Unmarshal(data, &medias)
+	if err != nil {
+		return medias, err
+	}
 	for i, media := range medias {
 		media.client = o.client
 		medias[i] = media
 	}
-	return medias
+	return medias, nil
+}
+
+func (o Org) GetVApps() ([]VApp, error) {
+	return o.GetVAppsContext(context.Background())
 }
 
-func (o Org) GetVApps() []VApp {
+func (o Org) GetVAppsContext(ctx context.Context) ([]VApp, error) {
 	vApps := []VApp{}
-	data, _ := o.client.Get(fmt.Sprintf("/org/%s/vapps", o.UUID))
-	json.Unmarshal(data, &vApps)
+	data, err := o.client.GetContext(ctx, fmt.Sprintf("/org/%s/vapps", o.UUID))
+	if err != nil {
+		return vApps, err
+	}
+	err = json.Unmarshal(data, &vApps)
+	if err != nil {
+		return vApps, err
+	}
 	for i, vApp := range vApps {
 		vApp.client = o.client
 		vApps[i] = vApp
 	}
-	return vApps
+	return vApps, nil
+}
+
+func (o Org) GetVAppNetworks() ([]VAppNetwork, error) {
+	return o.GetVAppNetworksContext(context.Background())
 }
 
-func (o Org) GetVAppNetworks() []VAppNetwork {
+func (o Org) GetVAppNetworksContext(ctx context.Context) ([]VAppNetwork, error) {
 	vAppNetworks := []VAppNetwork{}
-	data, _ := o.client.Get(fmt.Sprintf("/org/%s/vapp-networks", o.UUID))
-	json.Unmarshal(data, &vAppNetworks)
+	data, err := o.client.GetContext(ctx, fmt.Sprintf("/org/%s/vapp-networks", o.UUID))
+	if err != nil {
+		return vAppNetworks, err
+	}
+	err = json.Unmarshal(data, &vAppNetworks)
+	if err != nil {
+		return vAppNetworks, err
+	}
 	for i, vAppNetwork := range vAppNetworks {
 		vAppNetwork.client = o.client
 		vAppNetworks[i] = vAppNetwork
 	}
-	return vAppNetworks
+	return vAppNetworks, nil
 }
 
-func (o Org) GetVirtualMachines() []VirtualMachine {
+func (o Org) GetVirtualMachines() ([]VirtualMachine, error) {
+	return o.GetVirtualMachinesContext(context.Background())
+}
+
+func (o Org) GetVirtualMachinesContext(ctx context.Context) ([]VirtualMachine, error) {
 	virtualMachines := []VirtualMachine{}
-	data, _ := o.client.Get(fmt.Sprintf("/org/%s/vms", o.UUID))
-	json.Unmarshal(data, &virtualMachines)
+	data, err := o.client.GetContext(ctx, fmt.Sprintf("/org/%s/vms", o.UUID))
+	if err != nil {
+		return virtualMachines, err
+	}
+	err = json.Unmarshal(data, &virtualMachines)
+	if err != nil {
+		return virtualMachines, err
+	}
 	for i, virtualMachine := range virtualMachines {
 		virtualMachine.client = o.client
 		virtualMachines[i] = virtualMachine
 	}
-	return virtualMachines
+	return virtualMachines, nil
 }
 
-func (o Org) GetActiveTasks() []Task {
+func (o Org) GetActiveTasks() ([]Task, error) {
+	return o.GetActiveTasksContext(context.Background())
+}
+
+func (o Org) GetActiveTasksContext(ctx context.Context) ([]Task, error) {
 	tasks := []Task{}
-	data, _ := o.client.Get(fmt.Sprintf("/task/%s/org/%s/active", o.LocationID, o.UUID))
-	json.Unmarshal(data, &tasks)
+	data, err := o.client.GetContext(ctx, fmt.Sprintf("/task/%s/org/%s/active", o.LocationID, o.UUID))
+	if err != nil {
+		return tasks, err
+	}
+	err = json.Unmarshal(data, &tasks)
+	if err != nil {
+		return tasks, err
+	}
 	for i, task := range tasks {
 		task.client = o.client
 		tasks[i] = task
 	}
-	return tasks
+	return tasks, nil
+}
+
+// GetTaskHistory returns the tasks of the org, including completed ones,
+// that pass the filter.
+func (o Org) GetTaskHistory(filter TaskHistoryFilter) ([]Task, error) {
+	return o.GetTaskHistoryContext(context.Background(), filter)
+}
+
+func (o Org) GetTaskHistoryContext(ctx context.Context, filter TaskHistoryFilter) ([]Task, error) {
+	tasks := []Task{}
+	data, err := o.client.GetContext(ctx, fmt.Sprintf("/task/%s/org/%s/historical%s", o.LocationID, o.UUID, filter.query()))
+	if err != nil {
+		return tasks, err
+	}
+	err = json.Unmarshal(data, &tasks)
+	if err != nil {
+		return tasks, err
+	}
+	return filterTasks(o.client, tasks, filter), nil
+}
+
+func (o Org) GetDefaultStorageProfile() (StorageProfile, error) {
+	return o.GetDefaultStorageProfileContext(context.Background())
 }
 
-func (o Org) GetDefaultStorageProfile() StorageProfile {
-	for _, vdc := range o.GetVdcs() {
-		for _, storageProfile := range vdc.GetStorageProfiles() {
+func (o Org) GetDefaultStorageProfileContext(ctx context.Context) (StorageProfile, error) {
+	vdcs, err := o.GetVdcsContext(ctx)
+	if err != nil {
+		return StorageProfile{}, err
+	}
+	for _, vdc := range vdcs {
+		storageProfiles, err := vdc.GetStorageProfilesContext(ctx)
+		if err != nil {
+			return StorageProfile{}, err
+		}
+		for _, storageProfile := range storageProfiles {
 			if storageProfile.Default {
-				return storageProfile
+				return storageProfile, nil
 			}
 		}
 	}
-	return StorageProfile{}
+	return StorageProfile{}, fmt.Errorf("org with UUID, %s, has no default storage profile", o.UUID)
 }
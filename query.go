@@ -0,0 +1,502 @@
+package iland
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"regexp"
+)
+
+// listScope is one list of resources a query fetches, e.g. the VMs of an org.
+type listScope struct {
+	scope string
+	id    string
+	path  string
+}
+
+// query is the part common to the query builders. Its lists are fetched
+// lazily, one after another as the results are consumed, from the most
+// specific endpoint its scope allows, and its filters are applied to every
+// resource.
+type query[T any] struct {
+	client  *Client
+	filters []func(T) bool
+}
+
+// where returns a copy of q with one more filter, leaving q untouched.
+func (q query[T]) where(filter func(T) bool) query[T] {
+	q.filters = append(q.filters[:len(q.filters):len(q.filters)], filter)
+	return q
+}
+
+func (q query[T]) matches(item T) bool {
+	for _, filter := range q.filters {
+		if !filter(item) {
+			return false
+		}
+	}
+	return true
+}
+
+// iter fetches the lists of scopes and yields their matching resources. A
+// list that cannot be fetched is yielded as a ScopeError, after which the
+// iteration continues with the next list unless the caller stops it.
+func (q query[T]) iter(ctx context.Context, scopes iter.Seq2[listScope, error], attach func(*T)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		for scope, err := range scopes {
+			if err != nil {
+				if !yield(zero, err) {
+					return
+				}
+				continue
+			}
+			list := []T{}
+			err := q.client.getJSON(ctx, scope.path, &list)
+			if err != nil {
+				if !yield(zero, ScopeError{Scope: scope.scope, ID: scope.id, Err: err}) {
+					return
+				}
+				continue
+			}
+			for _, item := range list {
+				if attach != nil {
+					attach(&item)
+				}
+				if q.matches(item) && !yield(item, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// collect returns every resource of seq along with the failed lists in a
+// *ListError. Any other error ends the collection.
+func collect[T any](seq iter.Seq2[T, error]) ([]T, error) {
+	all := []T{}
+	listErr := ListError{}
+	for item, err := range seq {
+		if err != nil {
+			if !listErr.mergeScope(err) {
+				return all, err
+			}
+			continue
+		}
+		all = append(all, item)
+	}
+	return all, listErr.errOrNil()
+}
+
+// first returns the first resource of seq. If there is none, the errors met
+// on the way are returned, or notFound.
+func first[T any](seq iter.Seq2[T, error], notFound error) (T, error) {
+	var zero T
+	listErr := ListError{}
+	for item, err := range seq {
+		if err != nil {
+			if !listErr.mergeScope(err) {
+				return zero, err
+			}
+			continue
+		}
+		return item, nil
+	}
+	if err := listErr.errOrNil(); err != nil {
+		return zero, err
+	}
+	return zero, notFound
+}
+
+// mergeScope adds err if it is a ScopeError, and reports false otherwise.
+func (e *ListError) mergeScope(err error) bool {
+	var scopeErr ScopeError
+	if errors.As(err, &scopeErr) {
+		e.Failures = append(e.Failures, scopeErr)
+		return true
+	}
+	return false
+}
+
+// singleScope yields one list.
+func singleScope(scope, id, pathFormat string) iter.Seq2[listScope, error] {
+	return func(yield func(listScope, error) bool) {
+		yield(listScope{scope: scope, id: id, path: fmt.Sprintf(pathFormat, id)}, nil)
+	}
+}
+
+// locationScopes yields the list at pathFormat of every location of the
+// account, or of the given location only.
+func (c *Client) locationScopes(ctx context.Context, locationID, pathFormat string) iter.Seq2[listScope, error] {
+	if locationID != "" {
+		return singleScope("location", locationID, pathFormat)
+	}
+	return func(yield func(listScope, error) bool) {
+		locations, err := c.GetLocationsContext(ctx)
+		if err != nil {
+			yield(listScope{}, err)
+			return
+		}
+		for _, location := range locations {
+			if !yield(listScope{scope: "location", id: location.ID, path: fmt.Sprintf(pathFormat, location.ID)}, nil) {
+				return
+			}
+		}
+	}
+}
+
+// nestedScopes yields the list at pathFormat of every parent resource of
+// parents, identified by id.
+func nestedScopes[P any](parents iter.Seq2[P, error], scope string, id func(P) string, pathFormat string) iter.Seq2[listScope, error] {
+	return func(yield func(listScope, error) bool) {
+		for parent, err := range parents {
+			if err != nil {
+				if !yield(listScope{}, err) {
+					return
+				}
+				continue
+			}
+			if !yield(listScope{scope: scope, id: id(parent), path: fmt.Sprintf(pathFormat, id(parent))}, nil) {
+				return
+			}
+		}
+	}
+}
+
+// VirtualMachineQuery finds virtual machines, see Client.VirtualMachines.
+type VirtualMachineQuery struct {
+	query[VirtualMachine]
+	locationID string
+	orgUUID    string
+	vdcUUID    string
+	vAppUUID   string
+}
+
+// VirtualMachines starts a query of the virtual machines of the account,
+// e.g.
+//
+//	client.VirtualMachines().InOrg(orgUUID).WithStatus("POWERED_OFF").Iter(ctx)
+//
+// Narrowing the query to a location, org, vdc or vApp fetches the virtual
+// machines of that scope only.
+func (c *Client) VirtualMachines() VirtualMachineQuery {
+	return VirtualMachineQuery{query: query[VirtualMachine]{client: c}}
+}
+
+func (q VirtualMachineQuery) InLocation(locationID string) VirtualMachineQuery {
+	q.locationID = locationID
+	q.query = q.where(func(v VirtualMachine) bool { return v.LocationID == locationID })
+	return q
+}
+
+func (q VirtualMachineQuery) InOrg(orgUUID string) VirtualMachineQuery {
+	q.orgUUID = orgUUID
+	q.query = q.where(func(v VirtualMachine) bool { return v.OrgUUID == orgUUID })
+	return q
+}
+
+func (q VirtualMachineQuery) InVdc(vdcUUID string) VirtualMachineQuery {
+	q.vdcUUID = vdcUUID
+	q.query = q.where(func(v VirtualMachine) bool { return v.VdcUUID == vdcUUID })
+	return q
+}
+
+func (q VirtualMachineQuery) InVApp(vAppUUID string) VirtualMachineQuery {
+	q.vAppUUID = vAppUUID
+	q.query = q.where(func(v VirtualMachine) bool { return v.VAppUUID == vAppUUID })
+	return q
+}
+
+func (q VirtualMachineQuery) WithStatus(status string) VirtualMachineQuery {
+	q.query = q.where(func(v VirtualMachine) bool { return v.Status == status })
+	return q
+}
+
+func (q VirtualMachineQuery) NameMatches(re *regexp.Regexp) VirtualMachineQuery {
+	q.query = q.where(func(v VirtualMachine) bool { return re.MatchString(v.Name) })
+	return q
+}
+
+// Where adds an arbitrary filter.
+func (q VirtualMachineQuery) Where(filter func(VirtualMachine) bool) VirtualMachineQuery {
+	q.query = q.where(filter)
+	return q
+}
+
+// Iter yields the matching virtual machines, fetching them lazily. A list
+// that cannot be fetched is yielded as an error, and the iteration goes on
+// with the next one unless the loop is stopped.
+func (q VirtualMachineQuery) Iter(ctx context.Context) iter.Seq2[VirtualMachine, error] {
+	return q.iter(ctx, q.scopes(ctx), func(v *VirtualMachine) { v.client = q.client })
+}
+
+// All returns every matching virtual machine, and the lists that could not
+// be fetched in a *ListError.
+func (q VirtualMachineQuery) All(ctx context.Context) ([]VirtualMachine, error) {
+	return collect(q.Iter(ctx))
+}
+
+// First returns the first matching virtual machine without fetching the
+// remaining lists.
+func (q VirtualMachineQuery) First(ctx context.Context) (VirtualMachine, error) {
+	return first(q.Iter(ctx), errors.New("no virtual machine matches the query"))
+}
+
+func (q VirtualMachineQuery) scopes(ctx context.Context) iter.Seq2[listScope, error] {
+	switch {
+	case q.vAppUUID != "":
+		return singleScope("vapp", q.vAppUUID, "/vapp/%s/vms")
+	case q.vdcUUID != "":
+		return singleScope("vdc", q.vdcUUID, "/vdc/%s/vms")
+	case q.orgUUID != "":
+		return singleScope("org", q.orgUUID, "/org/%s/vms")
+	}
+	return q.client.locationScopes(ctx, q.locationID, "/location/%s/vms")
+}
+
+// VAppQuery finds vApps, see Client.VApps.
+type VAppQuery struct {
+	query[VApp]
+	locationID string
+	orgUUID    string
+	vdcUUID    string
+}
+
+// VApps starts a query of the vApps of the account, see
+// Client.VirtualMachines.
+func (c *Client) VApps() VAppQuery {
+	return VAppQuery{query: query[VApp]{client: c}}
+}
+
+func (q VAppQuery) InLocation(locationID string) VAppQuery {
+	q.locationID = locationID
+	q.query = q.where(func(v VApp) bool { return v.LocationID == locationID })
+	return q
+}
+
+func (q VAppQuery) InOrg(orgUUID string) VAppQuery {
+	q.orgUUID = orgUUID
+	q.query = q.where(func(v VApp) bool { return v.OrgUUID == orgUUID })
+	return q
+}
+
+func (q VAppQuery) InVdc(vdcUUID string) VAppQuery {
+	q.vdcUUID = vdcUUID
+	q.query = q.where(func(v VApp) bool { return v.VdcUUID == vdcUUID })
+	return q
+}
+
+func (q VAppQuery) WithStatus(status string) VAppQuery {
+	q.query = q.where(func(v VApp) bool { return v.Status == status })
+	return q
+}
+
+func (q VAppQuery) NameMatches(re *regexp.Regexp) VAppQuery {
+	q.query = q.where(func(v VApp) bool { return re.MatchString(v.Name) })
+	return q
+}
+
+func (q VAppQuery) Where(filter func(VApp) bool) VAppQuery {
+	q.query = q.where(filter)
+	return q
+}
+
+func (q VAppQuery) Iter(ctx context.Context) iter.Seq2[VApp, error] {
+	return q.iter(ctx, q.scopes(ctx), func(v *VApp) { v.client = q.client })
+}
+
+func (q VAppQuery) All(ctx context.Context) ([]VApp, error) {
+	return collect(q.Iter(ctx))
+}
+
+func (q VAppQuery) First(ctx context.Context) (VApp, error) {
+	return first(q.Iter(ctx), errors.New("no vApp matches the query"))
+}
+
+func (q VAppQuery) scopes(ctx context.Context) iter.Seq2[listScope, error] {
+	switch {
+	case q.vdcUUID != "":
+		return singleScope("vdc", q.vdcUUID, "/vdc/%s/vapps")
+	case q.orgUUID != "":
+		return singleScope("org", q.orgUUID, "/org/%s/vapps")
+	}
+	return q.client.locationScopes(ctx, q.locationID, "/location/%s/vapps")
+}
+
+// VdcQuery finds vdcs, see Client.Vdcs.
+type VdcQuery struct {
+	query[Vdc]
+	locationID string
+	orgUUID    string
+}
+
+// Vdcs starts a query of the vdcs of the account, see
+// Client.VirtualMachines.
+func (c *Client) Vdcs() VdcQuery {
+	return VdcQuery{query: query[Vdc]{client: c}}
+}
+
+func (q VdcQuery) InLocation(locationID string) VdcQuery {
+	q.locationID = locationID
+	q.query = q.where(func(v Vdc) bool { return v.LocationID == locationID })
+	return q
+}
+
+func (q VdcQuery) InOrg(orgUUID string) VdcQuery {
+	q.orgUUID = orgUUID
+	q.query = q.where(func(v Vdc) bool { return v.OrgUUID == orgUUID })
+	return q
+}
+
+func (q VdcQuery) NameMatches(re *regexp.Regexp) VdcQuery {
+	q.query = q.where(func(v Vdc) bool { return re.MatchString(v.Name) })
+	return q
+}
+
+func (q VdcQuery) Where(filter func(Vdc) bool) VdcQuery {
+	q.query = q.where(filter)
+	return q
+}
+
+func (q VdcQuery) Iter(ctx context.Context) iter.Seq2[Vdc, error] {
+	return q.iter(ctx, q.scopes(ctx), func(v *Vdc) { v.client = q.client })
+}
+
+func (q VdcQuery) All(ctx context.Context) ([]Vdc, error) {
+	return collect(q.Iter(ctx))
+}
+
+func (q VdcQuery) First(ctx context.Context) (Vdc, error) {
+	return first(q.Iter(ctx), errors.New("no vdc matches the query"))
+}
+
+func (q VdcQuery) scopes(ctx context.Context) iter.Seq2[listScope, error] {
+	if q.orgUUID != "" {
+		return singleScope("org", q.orgUUID, "/org/%s/vdcs")
+	}
+	return q.client.locationScopes(ctx, q.locationID, "/location/%s/vdcs")
+}
+
+// VdcNetworkQuery finds vdc networks, see Client.VdcNetworks.
+type VdcNetworkQuery struct {
+	query[VdcNetwork]
+	locationID string
+	orgUUID    string
+	vdcUUID    string
+}
+
+// VdcNetworks starts a query of the vdc networks of the account, see
+// Client.VirtualMachines.
+func (c *Client) VdcNetworks() VdcNetworkQuery {
+	return VdcNetworkQuery{query: query[VdcNetwork]{client: c}}
+}
+
+func (q VdcNetworkQuery) InLocation(locationID string) VdcNetworkQuery {
+	q.locationID = locationID
+	q.query = q.where(func(n VdcNetwork) bool { return n.LocationID == locationID })
+	return q
+}
+
+func (q VdcNetworkQuery) InOrg(orgUUID string) VdcNetworkQuery {
+	q.orgUUID = orgUUID
+	q.query = q.where(func(n VdcNetwork) bool { return n.OrgUUID == orgUUID })
+	return q
+}
+
+func (q VdcNetworkQuery) InVdc(vdcUUID string) VdcNetworkQuery {
+	q.vdcUUID = vdcUUID
+	q.query = q.where(func(n VdcNetwork) bool { return n.VdcUUID == vdcUUID })
+	return q
+}
+
+func (q VdcNetworkQuery) NameMatches(re *regexp.Regexp) VdcNetworkQuery {
+	q.query = q.where(func(n VdcNetwork) bool { return re.MatchString(n.Name) })
+	return q
+}
+
+func (q VdcNetworkQuery) Where(filter func(VdcNetwork) bool) VdcNetworkQuery {
+	q.query = q.where(filter)
+	return q
+}
+
+func (q VdcNetworkQuery) Iter(ctx context.Context) iter.Seq2[VdcNetwork, error] {
+	return q.iter(ctx, q.scopes(ctx), func(n *VdcNetwork) { n.client = q.client })
+}
+
+func (q VdcNetworkQuery) All(ctx context.Context) ([]VdcNetwork, error) {
+	return collect(q.Iter(ctx))
+}
+
+func (q VdcNetworkQuery) First(ctx context.Context) (VdcNetwork, error) {
+	return first(q.Iter(ctx), errors.New("no vdc network matches the query"))
+}
+
+func (q VdcNetworkQuery) scopes(ctx context.Context) iter.Seq2[listScope, error] {
+	switch {
+	case q.vdcUUID != "":
+		return singleScope("vdc", q.vdcUUID, "/vdc/%s/networks")
+	case q.orgUUID != "":
+		return singleScope("org", q.orgUUID, "/org/%s/vdc-networks")
+	}
+	orgs := query[Org]{client: q.client}.iter(ctx, q.client.locationScopes(ctx, q.locationID, "/location/%s/orgs"), nil)
+	return nestedScopes(orgs, "org", func(o Org) string { return o.UUID }, "/org/%s/vdc-networks")
+}
+
+// StorageProfileQuery finds storage profiles, see Client.StorageProfiles.
+type StorageProfileQuery struct {
+	query[StorageProfile]
+	vdcs    VdcQuery
+	vdcUUID string
+}
+
+// StorageProfiles starts a query of the storage profiles of the account,
+// see Client.VirtualMachines.
+func (c *Client) StorageProfiles() StorageProfileQuery {
+	return StorageProfileQuery{query: query[StorageProfile]{client: c}, vdcs: c.Vdcs()}
+}
+
+func (q StorageProfileQuery) InLocation(locationID string) StorageProfileQuery {
+	q.vdcs = q.vdcs.InLocation(locationID)
+	return q
+}
+
+func (q StorageProfileQuery) InOrg(orgUUID string) StorageProfileQuery {
+	q.vdcs = q.vdcs.InOrg(orgUUID)
+	return q
+}
+
+func (q StorageProfileQuery) InVdc(vdcUUID string) StorageProfileQuery {
+	q.vdcUUID = vdcUUID
+	q.query = q.where(func(s StorageProfile) bool { return s.VdcUUID == vdcUUID })
+	return q
+}
+
+func (q StorageProfileQuery) NameMatches(re *regexp.Regexp) StorageProfileQuery {
+	q.query = q.where(func(s StorageProfile) bool { return re.MatchString(s.Name) })
+	return q
+}
+
+func (q StorageProfileQuery) Where(filter func(StorageProfile) bool) StorageProfileQuery {
+	q.query = q.where(filter)
+	return q
+}
+
+func (q StorageProfileQuery) Iter(ctx context.Context) iter.Seq2[StorageProfile, error] {
+	return q.iter(ctx, q.scopes(ctx), nil)
+}
+
+func (q StorageProfileQuery) All(ctx context.Context) ([]StorageProfile, error) {
+	return collect(q.Iter(ctx))
+}
+
+func (q StorageProfileQuery) First(ctx context.Context) (StorageProfile, error) {
+	return first(q.Iter(ctx), errors.New("no storage profile matches the query"))
+}
+
+func (q StorageProfileQuery) scopes(ctx context.Context) iter.Seq2[listScope, error] {
+	if q.vdcUUID != "" {
+		return singleScope("vdc", q.vdcUUID, "/vdc/%s/storage-profiles")
+	}
+	return nestedScopes(q.vdcs.Iter(ctx), "vdc", func(v Vdc) string { return v.UUID }, "/vdc/%s/storage-profiles")
+}
@@ -0,0 +1,207 @@
+package iland
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// inventory serves the lists of three locations, a, b and c, each with two
+// VMs in org o, and records the requested paths. The lists of failing
+// paths answer 403.
+type inventory struct {
+	mu      sync.Mutex
+	failing map[string]bool
+	paths   []string
+}
+
+func (i *inventory) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	path := r.URL.Path
+	if strings.HasSuffix(path, "/inventory") {
+		path = "/inventory"
+	}
+	i.paths = append(i.paths, path)
+	if i.failing[path] {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	switch {
+	case path == "/inventory":
+		fmt.Fprint(w, `[{"location_id":"a"},{"location_id":"b"},{"location_id":"c"}]`)
+	case strings.HasSuffix(path, "/vms"):
+		id := strings.Split(path, "/")[2]
+		fmt.Fprintf(w, `[
+			{"uuid":"%[1]s-1","name":"web-%[1]s","status":"POWERED_ON","location_id":"b","org_uuid":"o","vdc_uuid":"d","vapp_uuid":"p"},
+			{"uuid":"%[1]s-2","name":"db-%[1]s","status":"POWERED_OFF","location_id":"b","org_uuid":"o","vdc_uuid":"d","vapp_uuid":"p"}
+		]`, id)
+	case strings.HasSuffix(path, "/orgs"):
+		fmt.Fprint(w, `[{"uuid":"o1"},{"uuid":"o2"}]`)
+	case strings.HasSuffix(path, "/vdcs"):
+		fmt.Fprint(w, `[{"uuid":"d1","org_uuid":"o"},{"uuid":"d2","org_uuid":"o"}]`)
+	default:
+		fmt.Fprint(w, `[]`)
+	}
+}
+
+func (i *inventory) requested() []string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return append([]string{}, i.paths...)
+}
+
+func TestQueryScopes(t *testing.T) {
+	tests := []struct {
+		name  string
+		query func(c *Client) error
+		want  []string
+	}{
+		{"vms", func(c *Client) error { _, err := c.VirtualMachines().All(context.Background()); return err },
+			[]string{"/inventory", "/location/a/vms", "/location/b/vms", "/location/c/vms"}},
+		{"vms in location", func(c *Client) error {
+			_, err := c.VirtualMachines().InLocation("b").All(context.Background())
+			return err
+		}, []string{"/location/b/vms"}},
+		{"vms in org", func(c *Client) error { _, err := c.VirtualMachines().InOrg("o").All(context.Background()); return err },
+			[]string{"/org/o/vms"}},
+		{"vms in vdc", func(c *Client) error {
+			_, err := c.VirtualMachines().InOrg("o").InVdc("d").All(context.Background())
+			return err
+		}, []string{"/vdc/d/vms"}},
+		{"vms in vApp", func(c *Client) error {
+			_, err := c.VirtualMachines().InVApp("p").InLocation("b").All(context.Background())
+			return err
+		}, []string{"/vapp/p/vms"}},
+		{"vApps in org", func(c *Client) error { _, err := c.VApps().InOrg("o").All(context.Background()); return err },
+			[]string{"/org/o/vapps"}},
+		{"vApps in vdc", func(c *Client) error { _, err := c.VApps().InVdc("d").All(context.Background()); return err },
+			[]string{"/vdc/d/vapps"}},
+		{"vdcs in location", func(c *Client) error { _, err := c.Vdcs().InLocation("a").All(context.Background()); return err },
+			[]string{"/location/a/vdcs"}},
+		{"vdcs in org", func(c *Client) error { _, err := c.Vdcs().InOrg("o").All(context.Background()); return err },
+			[]string{"/org/o/vdcs"}},
+		{"vdc networks in location", func(c *Client) error {
+			_, err := c.VdcNetworks().InLocation("b").All(context.Background())
+			return err
+		}, []string{"/location/b/orgs", "/org/o1/vdc-networks", "/org/o2/vdc-networks"}},
+		{"vdc networks in org", func(c *Client) error { _, err := c.VdcNetworks().InOrg("o").All(context.Background()); return err },
+			[]string{"/org/o/vdc-networks"}},
+		{"vdc networks in vdc", func(c *Client) error { _, err := c.VdcNetworks().InVdc("d").All(context.Background()); return err },
+			[]string{"/vdc/d/networks"}},
+		{"storage profiles in org", func(c *Client) error {
+			_, err := c.StorageProfiles().InOrg("o").All(context.Background())
+			return err
+		}, []string{"/org/o/vdcs", "/vdc/d1/storage-profiles", "/vdc/d2/storage-profiles"}},
+		{"storage profiles in vdc", func(c *Client) error {
+			_, err := c.StorageProfiles().InVdc("d").All(context.Background())
+			return err
+		}, []string{"/vdc/d/storage-profiles"}},
+	}
+	for _, test := range tests {
+		server := &inventory{}
+		if err := test.query(newTestClient(t, server)); err != nil {
+			t.Errorf("%s: err = %v", test.name, err)
+		}
+		if got := server.requested(); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: requested %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestQueryFilters(t *testing.T) {
+	client := newTestClient(t, &inventory{})
+	vms, err := client.VirtualMachines().InOrg("o").WithStatus("POWERED_ON").NameMatches(regexp.MustCompile("^web")).All(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vms) != 1 || vms[0].UUID != "o-1" || vms[0].client != client {
+		t.Errorf("vms = %+v, want o-1 with its client", vms)
+	}
+	vms, err = client.VirtualMachines().InOrg("other").All(context.Background())
+	if err != nil || len(vms) != 0 {
+		t.Errorf("vms = %+v, %v, want none of another org", vms, err)
+	}
+}
+
+func TestQueryLazy(t *testing.T) {
+	server := &inventory{}
+	client := newTestClient(t, server)
+	got := []string{}
+	for vm, err := range client.VirtualMachines().Iter(context.Background()) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, vm.UUID)
+		if len(got) == 3 {
+			break
+		}
+	}
+	if want := []string{"a-1", "a-2", "b-1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("yielded %v, want %v", got, want)
+	}
+	if got, want := server.requested(), []string{"/inventory", "/location/a/vms", "/location/b/vms"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("requested %v, want %v", got, want)
+	}
+
+	server = &inventory{}
+	vm, err := newTestClient(t, server).VirtualMachines().First(context.Background())
+	if err != nil || vm.UUID != "a-1" {
+		t.Errorf("First() = %+v, %v, want a-1", vm, err)
+	}
+	if got, want := server.requested(), []string{"/inventory", "/location/a/vms"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("First requested %v, want %v", got, want)
+	}
+}
+
+func TestQueryScopeError(t *testing.T) {
+	server := &inventory{failing: map[string]bool{"/location/a/vms": true}}
+	client := newTestClient(t, server, WithRetryPolicy(RetryPolicy{}))
+	got := []string{}
+	for vm, err := range client.VirtualMachines().Iter(context.Background()) {
+		if err != nil {
+			var scopeErr ScopeError
+			if !errors.As(err, &scopeErr) || scopeErr.Scope != "location" || scopeErr.ID != "a" {
+				t.Fatalf("err = %v, want a ScopeError of location a", err)
+			}
+			got = append(got, "error")
+			continue
+		}
+		got = append(got, vm.UUID)
+	}
+	if want := []string{"error", "b-1", "b-2", "c-1", "c-2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("yielded %v, want %v", got, want)
+	}
+
+	vms, err := client.VirtualMachines().All(context.Background())
+	var listErr *ListError
+	if !errors.As(err, &listErr) || len(listErr.Failures) != 1 || listErr.Failures[0].ID != "a" {
+		t.Errorf("err = %v, want a ListError with location a", err)
+	}
+	if len(vms) != 4 {
+		t.Errorf("%d vms, want the 4 of the other locations", len(vms))
+	}
+	vm, err := client.VirtualMachines().First(context.Background())
+	if err != nil || vm.UUID != "b-1" {
+		t.Errorf("First() = %+v, %v, want b-1", vm, err)
+	}
+	if _, err := client.VirtualMachines().WithStatus("SUSPENDED").First(context.Background()); !errors.As(err, &listErr) {
+		t.Errorf("First() err = %v, want the ListError instead of not found", err)
+	}
+}
+
+func TestQueryLocationsError(t *testing.T) {
+	server := &inventory{failing: map[string]bool{"/inventory": true}}
+	client := newTestClient(t, server, WithRetryPolicy(RetryPolicy{}))
+	vms, err := client.VirtualMachines().All(context.Background())
+	var scopeErr ScopeError
+	if err == nil || errors.As(err, &scopeErr) || len(vms) != 0 {
+		t.Errorf("All() = %v, %v, want the error of the locations", vms, err)
+	}
+}
@@ -0,0 +1,85 @@
+package iland
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterWait(t *testing.T) {
+	tests := []struct {
+		name       string
+		rate       float64
+		burst      int
+		calls      int
+		minElapsed time.Duration
+		maxElapsed time.Duration
+	}{
+		{"within burst", 10, 3, 3, 0, 50 * time.Millisecond},
+		{"past burst", 20, 2, 4, 90 * time.Millisecond, 300 * time.Millisecond},
+		{"burst of at least one", 50, 0, 2, 15 * time.Millisecond, 200 * time.Millisecond},
+	}
+	for _, test := range tests {
+		l := newRateLimiter(test.rate, test.burst)
+		start := time.Now()
+		for i := 0; i < test.calls; i++ {
+			if err := l.wait(context.Background()); err != nil {
+				t.Fatalf("%s: wait: %v", test.name, err)
+			}
+		}
+		elapsed := time.Since(start)
+		if elapsed < test.minElapsed || elapsed > test.maxElapsed {
+			t.Errorf("%s: %d calls took %v, want between %v and %v", test.name, test.calls, elapsed, test.minElapsed, test.maxElapsed)
+		}
+	}
+}
+
+func TestRateLimiterRefillIsCappedAtBurst(t *testing.T) {
+	l := newRateLimiter(1000, 2)
+	l.last = time.Now().Add(-time.Hour)
+	l.wait(context.Background())
+	if l.tokens > 1 {
+		t.Errorf("tokens = %v after an idle hour, want at most burst-1", l.tokens)
+	}
+}
+
+func TestRateLimiterCancelReturnsToken(t *testing.T) {
+	l := newRateLimiter(0.1, 1)
+	l.wait(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.wait(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("wait = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if l.tokens < -0.01 {
+		t.Errorf("tokens = %v after a cancelled wait, want the reservation returned", l.tokens)
+	}
+}
+
+func TestAcquireSlot(t *testing.T) {
+	c := &Client{inFlight: make(chan struct{}, 1)}
+	release, err := c.acquireSlot(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := c.acquireSlot(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("acquireSlot while full = %v, want %v", err, context.DeadlineExceeded)
+	}
+	release()
+	release()
+	if _, err := c.acquireSlot(context.Background()); err != nil {
+		t.Fatalf("acquireSlot after release = %v", err)
+	}
+	if len(c.inFlight) != 1 {
+		t.Errorf("in flight = %d, want 1 after releasing twice", len(c.inFlight))
+	}
+
+	unlimited := &Client{}
+	release, err = unlimited.acquireSlot(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+}
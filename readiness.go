@@ -0,0 +1,103 @@
+package iland
+
+import (
+	"context"
+	"time"
+)
+
+// ReadinessPolicy controls how the methods that modify a resource, such as
+// PowerOn, Delete or UpdateFirewallConfig, wait for the resource's active
+// tasks to complete before sending their request, since the API rejects
+// changes to busy resources.
+type ReadinessPolicy struct {
+	// Disabled skips the check altogether, the request is sent right away.
+	Disabled bool
+	// FailFast returns a *ResourceBusyError instead of waiting when the
+	// resource is busy.
+	FailFast bool
+	// Timeout bounds the wait, after which a *ResourceBusyError is returned.
+	// Zero waits for as long as the context allows.
+	Timeout time.Duration
+	// PollInterval is the delay between two checks, 5 seconds by default.
+	PollInterval time.Duration
+}
+
+// DefaultReadinessPolicy is used by clients created with NewClient or
+// NewClientWithOptions unless WithReadinessPolicy is given.
+var DefaultReadinessPolicy = ReadinessPolicy{
+	Timeout:      10 * time.Minute,
+	PollInterval: 5 * time.Second,
+}
+
+// WithReadinessPolicy replaces DefaultReadinessPolicy.
+func WithReadinessPolicy(policy ReadinessPolicy) ClientOption {
+	return func(c *Client) {
+		c.readinessPolicy = policy
+	}
+}
+
+// activeTasks returns the tasks of the entity that are still running.
+func (c *Client) activeTasks(ctx context.Context, locationID, entityUUID string) ([]Task, error) {
+	location := Location{client: c, ID: locationID}
+	tasks, err := location.GetEntityActiveTasksContext(ctx, entityUUID)
+	if err != nil {
+		return tasks, err
+	}
+	active := []Task{}
+	for _, task := range tasks {
+		if task.Active {
+			active = append(active, task)
+		}
+	}
+	return active, nil
+}
+
+func (c *Client) isBusy(ctx context.Context, locationID, entityUUID string) (bool, error) {
+	tasks, err := c.activeTasks(ctx, locationID, entityUUID)
+	return len(tasks) > 0, err
+}
+
+// waitUntilObjectIsReady waits until the entity has no active tasks, as
+// configured by the client's ReadinessPolicy.
+func (c *Client) waitUntilObjectIsReady(ctx context.Context, locationID, objectUUID string) error {
+	policy := c.readinessPolicy
+	if policy.Disabled {
+		return nil
+	}
+	ctx, end := c.startPolling(ctx, Poll{Kind: PollEntity, LocationID: locationID, UUID: objectUUID})
+	err := c.pollUntilObjectIsReady(ctx, policy, locationID, objectUUID)
+	end(err)
+	return err
+}
+
+func (c *Client) pollUntilObjectIsReady(ctx context.Context, policy ReadinessPolicy, locationID, objectUUID string) error {
+	interval := policy.PollInterval
+	if interval <= 0 {
+		interval = DefaultReadinessPolicy.PollInterval
+	}
+	var deadline <-chan time.Time
+	if policy.Timeout > 0 {
+		timer := time.NewTimer(policy.Timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	for {
+		tasks, err := c.activeTasks(ctx, locationID, objectUUID)
+		if err != nil {
+			return err
+		}
+		if len(tasks) == 0 {
+			return nil
+		}
+		if policy.FailFast {
+			return &ResourceBusyError{LocationID: locationID, UUID: objectUUID, Tasks: tasks}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline:
+			return &ResourceBusyError{LocationID: locationID, UUID: objectUUID, Tasks: tasks, Waited: policy.Timeout}
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package iland
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// busyEntity serves the tasks of an entity, which is busy for the given
+// number of checks, or forever if it is negative, and records every request.
+type busyEntity struct {
+	mu       sync.Mutex
+	busy     int
+	requests []string
+}
+
+func (b *busyEntity) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.requests = append(b.requests, r.Method+" "+r.URL.Path)
+	if r.Method != "GET" {
+		fmt.Fprint(w, `{"uuid":"task","location_id":"l"}`)
+		return
+	}
+	if b.busy == 0 {
+		fmt.Fprint(w, `[{"uuid":"done","active":false}]`)
+		return
+	}
+	if b.busy > 0 {
+		b.busy--
+	}
+	fmt.Fprint(w, `[{"uuid":"done","active":false},{"uuid":"running","active":true}]`)
+}
+
+func (b *busyEntity) sent() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string{}, b.requests...)
+}
+
+func TestReadiness(t *testing.T) {
+	check := "GET /task/l/entity/vm"
+	tests := []struct {
+		name   string
+		policy ReadinessPolicy
+		busy   int
+		want   []string
+	}{
+		{"ready", ReadinessPolicy{}, 0, []string{check, "DELETE /vm/vm"}},
+		{"busy", ReadinessPolicy{PollInterval: time.Millisecond}, 2, []string{check, check, check, "DELETE /vm/vm"}},
+		{"disabled", ReadinessPolicy{Disabled: true}, -1, []string{"DELETE /vm/vm"}},
+		{"fail fast when ready", ReadinessPolicy{FailFast: true}, 0, []string{check, "DELETE /vm/vm"}},
+	}
+	for _, test := range tests {
+		entity := &busyEntity{busy: test.busy}
+		client := newTestClient(t, entity, WithReadinessPolicy(test.policy))
+		if _, err := (VirtualMachine{client: client, UUID: "vm", LocationID: "l"}).Delete(); err != nil {
+			t.Errorf("%s: err = %v", test.name, err)
+		}
+		if got := entity.sent(); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: sent %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestReadinessFailFast(t *testing.T) {
+	entity := &busyEntity{busy: -1}
+	client := newTestClient(t, entity, WithReadinessPolicy(ReadinessPolicy{FailFast: true}))
+	_, err := VirtualMachine{client: client, UUID: "vm", LocationID: "l"}.PowerOn()
+	var busyErr *ResourceBusyError
+	if !errors.As(err, &busyErr) {
+		t.Fatalf("err = %v, want a ResourceBusyError", err)
+	}
+	if busyErr.UUID != "vm" || busyErr.LocationID != "l" || busyErr.Waited != 0 {
+		t.Errorf("err = %+v", busyErr)
+	}
+	if len(busyErr.Tasks) != 1 || busyErr.Tasks[0].UUID != "running" {
+		t.Errorf("tasks = %+v, want the active one only", busyErr.Tasks)
+	}
+	if got, want := entity.sent(), []string{"GET /task/l/entity/vm"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sent %v, want %v", got, want)
+	}
+}
+
+func TestReadinessTimeout(t *testing.T) {
+	entity := &busyEntity{busy: -1}
+	timeout := 50 * time.Millisecond
+	client := newTestClient(t, entity, WithReadinessPolicy(ReadinessPolicy{Timeout: timeout, PollInterval: 10 * time.Millisecond}))
+	start := time.Now()
+	_, err := Edge{client: client, UUID: "edge", LocationID: "l"}.UpdateNATConfig(EdgeNATConfig{})
+	elapsed := time.Since(start)
+	var busyErr *ResourceBusyError
+	if !errors.As(err, &busyErr) {
+		t.Fatalf("err = %v, want a ResourceBusyError", err)
+	}
+	if busyErr.Waited != timeout {
+		t.Errorf("waited %v, want %v", busyErr.Waited, timeout)
+	}
+	if elapsed < timeout || elapsed > 2*time.Second {
+		t.Errorf("returned after %v, want about %v", elapsed, timeout)
+	}
+	for _, request := range entity.sent() {
+		if request != "GET /task/l/entity/edge" {
+			t.Errorf("sent %s, want only checks of the edge", request)
+		}
+	}
+}
+
+func TestReadinessContextCanceled(t *testing.T) {
+	entity := &busyEntity{busy: -1}
+	client := newTestClient(t, entity, WithReadinessPolicy(ReadinessPolicy{PollInterval: 10 * time.Millisecond}))
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	_, err := VApp{client: client, UUID: "app", LocationID: "l"}.PowerOnContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the context's error", err)
+	}
+}
+
+func TestIsBusy(t *testing.T) {
+	tests := []struct {
+		name   string
+		isBusy func(c *Client) (bool, error)
+		path   string
+	}{
+		{"vApp", func(c *Client) (bool, error) { return VApp{client: c, UUID: "app", LocationID: "l"}.IsBusy() }, "/task/l/entity/app"},
+		{"virtual machine", func(c *Client) (bool, error) { return VirtualMachine{client: c, UUID: "vm", LocationID: "l"}.IsBusy() }, "/task/l/entity/vm"},
+		{"edge", func(c *Client) (bool, error) { return Edge{client: c, UUID: "edge", LocationID: "l"}.IsBusy() }, "/task/l/entity/edge"},
+		{"catalog", func(c *Client) (bool, error) { return Catalog{client: c, UUID: "catalog", LocationID: "l"}.IsBusy() }, "/task/l/entity/catalog"},
+		{"vApp template", func(c *Client) (bool, error) {
+			return VAppTemplate{client: c, UUID: "template", LocationID: "l"}.IsBusy()
+		}, "/task/l/entity/template"},
+	}
+	for _, test := range tests {
+		for _, busy := range []bool{false, true} {
+			entity := &busyEntity{}
+			if busy {
+				entity.busy = -1
+			}
+			got, err := test.isBusy(newTestClient(t, entity))
+			if err != nil || got != busy {
+				t.Errorf("%s: IsBusy() = %v, %v, want %v", test.name, got, err, busy)
+			}
+			if sent, want := entity.sent(), []string{"GET " + test.path}; !reflect.DeepEqual(sent, want) {
+				t.Errorf("%s: sent %v, want %v", test.name, sent, want)
+			}
+		}
+	}
+}
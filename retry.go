@@ -0,0 +1,96 @@
+package iland
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how the Client retries requests that fail with a
+// transient error: a connection error or a 429, 502, 503 or 504 response.
+// GET, PUT and DELETE requests are retried; POST requests only when
+// RetryPost is set, since they are not idempotent.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	// A value of 1 or less disables retries.
+	MaxAttempts int
+	// MinBackoff is the wait before the first retry. It doubles with every
+	// further retry, up to MaxBackoff, and is randomized by up to half.
+	// A Retry-After header sent by the API takes precedence, capped at
+	// MaxBackoff as well.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	RetryPost  bool
+}
+
+// DefaultRetryPolicy is used by clients created with NewClient or
+// NewClientWithOptions unless WithRetryPolicy is given.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	MinBackoff:  time.Second,
+	MaxBackoff:  30 * time.Second,
+}
+
+func (p RetryPolicy) shouldRetry(ctx context.Context, verb string, attempt int, resp *http.Response, err error) bool {
+	if attempt >= p.MaxAttempts || ctx.Err() != nil {
+		return false
+	}
+	switch verb {
+	case "GET", "HEAD", "PUT", "DELETE", "OPTIONS":
+	case "POST":
+		if !p.RetryPost {
+			return false
+		}
+	default:
+		return false
+	}
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func (p RetryPolicy) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if wait, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			if p.MaxBackoff > 0 && wait > p.MaxBackoff {
+				wait = p.MaxBackoff
+			}
+			return wait
+		}
+	}
+	backoff := p.MaxBackoff
+	if attempt < 32 {
+		if exp := p.MinBackoff << (attempt - 1); exp > 0 && exp < p.MaxBackoff {
+			backoff = exp
+		}
+	}
+	if backoff <= 0 {
+		return 0
+	}
+	half := backoff / 2
+	return half + rand.N(backoff-half+1)
+}
+
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
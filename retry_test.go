@@ -0,0 +1,104 @@
+package iland
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyShouldRetry(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name      string
+		policy    RetryPolicy
+		ctx       context.Context
+		verb      string
+		attempt   int
+		status    int
+		err       error
+		wantRetry bool
+	}{
+		{"connection error", policy, context.Background(), "GET", 1, 0, errors.New("reset"), true},
+		{"service unavailable", policy, context.Background(), "GET", 1, http.StatusServiceUnavailable, nil, true},
+		{"too many requests", policy, context.Background(), "DELETE", 2, http.StatusTooManyRequests, nil, true},
+		{"bad gateway", policy, context.Background(), "PUT", 1, http.StatusBadGateway, nil, true},
+		{"gateway timeout", policy, context.Background(), "GET", 1, http.StatusGatewayTimeout, nil, true},
+		{"not found", policy, context.Background(), "GET", 1, http.StatusNotFound, nil, false},
+		{"internal error", policy, context.Background(), "GET", 1, http.StatusInternalServerError, nil, false},
+		{"last attempt", policy, context.Background(), "GET", 3, http.StatusServiceUnavailable, nil, false},
+		{"context done", policy, cancelled, "GET", 1, http.StatusServiceUnavailable, nil, false},
+		{"post", policy, context.Background(), "POST", 1, http.StatusServiceUnavailable, nil, false},
+		{"post allowed", RetryPolicy{MaxAttempts: 3, RetryPost: true}, context.Background(), "POST", 1, http.StatusServiceUnavailable, nil, true},
+		{"patch", policy, context.Background(), "PATCH", 1, http.StatusServiceUnavailable, nil, false},
+		{"disabled", RetryPolicy{MaxAttempts: 1}, context.Background(), "GET", 1, http.StatusServiceUnavailable, nil, false},
+	}
+	for _, test := range tests {
+		var resp *http.Response
+		if test.err == nil {
+			resp = &http.Response{StatusCode: test.status}
+		}
+		if got := test.policy.shouldRetry(test.ctx, test.verb, test.attempt, resp, test.err); got != test.wantRetry {
+			t.Errorf("%s: shouldRetry = %v, want %v", test.name, got, test.wantRetry)
+		}
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{MinBackoff: time.Second, MaxBackoff: 10 * time.Second}
+	tests := []struct {
+		name       string
+		policy     RetryPolicy
+		attempt    int
+		retryAfter string
+		min, max   time.Duration
+	}{
+		{"first retry", policy, 1, "", 500 * time.Millisecond, time.Second},
+		{"doubled", policy, 3, "", 2 * time.Second, 4 * time.Second},
+		{"capped", policy, 10, "", 5 * time.Second, 10 * time.Second},
+		{"shift overflow", policy, 40, "", 5 * time.Second, 10 * time.Second},
+		{"retry after", policy, 1, "7", 7 * time.Second, 7 * time.Second},
+		{"retry after zero", policy, 5, "0", 0, 0},
+		{"retry after capped", policy, 1, "86400", 10 * time.Second, 10 * time.Second},
+		{"retry after invalid", policy, 1, "soon", 500 * time.Millisecond, time.Second},
+		{"no backoff", RetryPolicy{}, 1, "", 0, 0},
+	}
+	for _, test := range tests {
+		var resp *http.Response
+		if test.retryAfter != "" {
+			resp = &http.Response{Header: http.Header{"Retry-After": {test.retryAfter}}}
+		}
+		for i := 0; i < 20; i++ {
+			got := test.policy.backoff(test.attempt, resp)
+			if got < test.min || got > test.max {
+				t.Errorf("%s: backoff = %v, want between %v and %v", test.name, got, test.min, test.max)
+				break
+			}
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value    string
+		wantOK   bool
+		min, max time.Duration
+	}{
+		{"", false, 0, 0},
+		{"120", true, 2 * time.Minute, 2 * time.Minute},
+		{"0", true, 0, 0},
+		{"-5", false, 0, 0},
+		{"later", false, 0, 0},
+		{time.Now().Add(time.Minute).UTC().Format(http.TimeFormat), true, 58 * time.Second, time.Minute},
+		{time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), true, 0, 0},
+	}
+	for _, test := range tests {
+		got, ok := parseRetryAfter(test.value)
+		if ok != test.wantOK || got < test.min || got > test.max {
+			t.Errorf("parseRetryAfter(%q) = %v, %v, want %v between %v and %v", test.value, got, ok, test.wantOK, test.min, test.max)
+		}
+	}
+}
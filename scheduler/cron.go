@@ -0,0 +1,189 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule returns the next time a policy runs after the given time.
+type Schedule interface {
+	Next(after time.Time) time.Time
+}
+
+type interval time.Duration
+
+// Every returns a schedule that runs every d, counted from the previous
+// scheduled time rather than from the end of the previous run.
+func Every(d time.Duration) Schedule {
+	return interval(d)
+}
+
+func (i interval) Next(after time.Time) time.Time {
+	return after.Add(time.Duration(i))
+}
+
+// cron is a schedule in the five field format of crontab.
+type cron struct {
+	minutes     uint64
+	hours       uint64
+	daysOfMonth uint64
+	months      uint64
+	daysOfWeek  uint64
+	// anyDay is set when the day of the month or of the week is *, in which
+	// case a day must match both fields instead of either.
+	anyDay bool
+	// anyHour is set when the hour is *, the schedule then runs in both
+	// occurrences of the hour repeated when daylight saving time ends.
+	anyHour bool
+}
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseCron parses a schedule in the five field format of crontab: minute,
+// hour, day of the month, month and day of the week, e.g. "30 2 * * 1-5"
+// for 2:30 on weekdays. Fields accept *, lists, ranges and steps, such as
+// "*/15" or "1-5,7". Sunday is 0 or 7. The descriptors @hourly, @daily,
+// @weekly, @monthly and @yearly are accepted as well. The schedule is
+// evaluated in the location of the time passed to Next. A time that doesn't
+// exist on the day daylight saving time starts is skipped, and a time that
+// occurs twice on the day it ends runs once, unless the hour is *.
+func ParseCron(spec string) (Schedule, error) {
+	if expanded, ok := cronDescriptors[strings.TrimSpace(spec)]; ok {
+		spec = expanded
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron schedule %q must have 5 fields", spec)
+	}
+	c := cron{}
+	var err error
+	if c.minutes, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, err
+	}
+	if c.hours, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, err
+	}
+	if c.daysOfMonth, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, err
+	}
+	if c.months, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, err
+	}
+	if c.daysOfWeek, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, err
+	}
+	if c.daysOfWeek&(1<<7) != 0 {
+		c.daysOfWeek |= 1
+	}
+	c.anyDay = strings.HasPrefix(fields[2], "*") || strings.HasPrefix(fields[4], "*")
+	c.anyHour = strings.HasPrefix(fields[1], "*")
+	return c, nil
+}
+
+// parseCronField returns the values of a field as a bit set.
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step in cron field %q", field)
+			}
+		}
+		low, high := min, max
+		if rangePart != "*" {
+			lowPart, highPart, isRange := strings.Cut(rangePart, "-")
+			var err error
+			low, err = strconv.Atoi(lowPart)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value in cron field %q", field)
+			}
+			high = low
+			if isRange {
+				high, err = strconv.Atoi(highPart)
+				if err != nil {
+					return 0, fmt.Errorf("invalid range in cron field %q", field)
+				}
+			} else if hasStep {
+				high = max
+			}
+		}
+		if low < min || high > max || low > high {
+			return 0, fmt.Errorf("cron field %q is out of range %d-%d", field, min, max)
+		}
+		for value := low; value <= high; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+	return bits, nil
+}
+
+func has(bits uint64, value int) bool {
+	return bits&(1<<uint(value)) != 0
+}
+
+func (c cron) dayMatches(t time.Time) bool {
+	dayOfMonth := has(c.daysOfMonth, t.Day())
+	dayOfWeek := has(c.daysOfWeek, int(t.Weekday()))
+	if c.anyDay {
+		return dayOfMonth && dayOfWeek
+	}
+	return dayOfMonth || dayOfWeek
+}
+
+// Next returns the first matching minute after the given time, or the zero
+// time if there is none within five years, e.g. for February 30.
+func (c cron) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if !has(c.months, int(t.Month())) {
+			t = advance(t, time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location()))
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = advance(t, time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location()))
+			continue
+		}
+		if !has(c.hours, t.Hour()) {
+			t = advance(t, time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location()))
+			continue
+		}
+		if !has(c.minutes, t.Minute()) || (!c.anyHour && repeated(t)) {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// advance returns next, the start of the next month, day or hour, unless a
+// daylight saving time change made time.Date normalize it to a time that
+// isn't later than t, in which case it moves t forward by a minute.
+func advance(t, next time.Time) time.Time {
+	if next.After(t) {
+		return next
+	}
+	return t.Add(time.Minute)
+}
+
+// repeated reports whether t is the second occurrence of its wall clock
+// time, when daylight saving time ends.
+func repeated(t time.Time) bool {
+	first := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, t.Location())
+	return first.Before(t)
+}
@@ -0,0 +1,89 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronNext(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone database unavailable:", err)
+	}
+	// Sunday, October 18th 2026.
+	sunday := time.Date(2026, 10, 18, 13, 7, 30, 0, time.UTC)
+	tests := []struct {
+		spec  string
+		after time.Time
+		want  time.Time
+	}{
+		{"@hourly", sunday, time.Date(2026, 10, 18, 14, 0, 0, 0, time.UTC)},
+		{"@daily", sunday, time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", sunday, time.Date(2026, 10, 25, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", sunday, time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", sunday, time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", sunday, time.Date(2026, 10, 18, 13, 15, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 10, 18, 13, 45, 0, 0, time.UTC), time.Date(2026, 10, 18, 14, 0, 0, 0, time.UTC)},
+		{"5/20 * * * *", sunday, time.Date(2026, 10, 18, 13, 25, 0, 0, time.UTC)},
+		{"30 2 * * 1-5", sunday, time.Date(2026, 10, 19, 2, 30, 0, 0, time.UTC)},
+		// Sunday is 7 as well as 0.
+		{"0 9 * * 1-5,7", time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC), time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * 0", time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC), time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", sunday, time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 2 *", sunday, time.Time{}},
+		// a restricted day of the month and of the week match either.
+		{"0 12 1 * 5", sunday, time.Date(2026, 10, 23, 12, 0, 0, 0, time.UTC)},
+		{"0 12 20 * 5", sunday, time.Date(2026, 10, 20, 12, 0, 0, 0, time.UTC)},
+		// a * day of the week makes the day of the month alone count.
+		{"0 12 20 * *", time.Date(2026, 10, 21, 0, 0, 0, 0, time.UTC), time.Date(2026, 11, 20, 12, 0, 0, 0, time.UTC)},
+		// so does a day of the month starting with *, as in Vixie cron.
+		{"0 12 */10 * 1", sunday, time.Date(2026, 12, 21, 12, 0, 0, 0, time.UTC)},
+		// 2:30 doesn't exist on March 8th 2026 in New York.
+		{"30 2 * * *", time.Date(2026, 3, 8, 0, 0, 0, 0, newYork), time.Date(2026, 3, 9, 2, 30, 0, 0, newYork)},
+		{"0 3 * * *", time.Date(2026, 3, 8, 0, 0, 0, 0, newYork), time.Date(2026, 3, 8, 3, 0, 0, 0, newYork)},
+		// 1:30 happens twice on November 1st 2026 in New York.
+		{"30 1 * * *", time.Date(2026, 11, 1, 1, 30, 0, 0, newYork), time.Date(2026, 11, 2, 1, 30, 0, 0, newYork)},
+		{"30 * * * *", time.Date(2026, 11, 1, 1, 30, 0, 0, newYork), time.Date(2026, 11, 1, 1, 30, 0, 0, newYork).Add(time.Hour)},
+	}
+	for _, test := range tests {
+		schedule, err := ParseCron(test.spec)
+		if err != nil {
+			t.Errorf("ParseCron(%q): %v", test.spec, err)
+			continue
+		}
+		if got := schedule.Next(test.after); !got.Equal(test.want) {
+			t.Errorf("ParseCron(%q).Next(%v) = %v, want %v", test.spec, test.after, got, test.want)
+		}
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	specs := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"@reboot",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"a * * * *",
+		"1-b * * * *",
+	}
+	for _, spec := range specs {
+		if _, err := ParseCron(spec); err == nil {
+			t.Errorf("ParseCron(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+func TestEvery(t *testing.T) {
+	start := time.Date(2026, 10, 18, 13, 7, 30, 0, time.UTC)
+	if got := Every(time.Hour).Next(start); !got.Equal(start.Add(time.Hour)) {
+		t.Errorf("Every(time.Hour).Next = %v, want %v", got, start.Add(time.Hour))
+	}
+}
@@ -0,0 +1,314 @@
+// Package scheduler takes snapshots of vApps and VMs on a schedule and
+// removes the snapshots that are older than a policy allows.
+//
+//	schedule, err := scheduler.ParseCron("0 2 * * *")
+//	s, err := scheduler.New(scheduler.Policy{
+//		Schedule: schedule,
+//		MaxAge:   time.Hour * 36,
+//		OnError: func(target scheduler.Target, err error) {
+//			log.Printf("snapshot of %s failed: %v", target.Name(), err)
+//		},
+//	}, scheduler.VAppTarget(vApp), scheduler.VirtualMachineTarget(vm))
+//	err = s.Run(ctx)
+//
+// Every night at 2:00 this replaces the snapshot the scheduler took the night
+// before. A vApp or VM has at most one snapshot, so a snapshot that someone
+// else took is left alone until it is older than MaxAge, here 36 hours, then
+// replaced. With Policy.ReplaceExisting it is replaced right away.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	iland "github.com/jrperry/golang-sdk"
+)
+
+// Target is a vApp or VM whose snapshot is managed by a Scheduler.
+type Target interface {
+	UUID() string
+	Name() string
+	HasSnapshot(ctx context.Context) (bool, error)
+	GetSnapshot(ctx context.Context) (iland.Snapshot, error)
+	TakeSnapshot(ctx context.Context, opts iland.SnapshotOptions) (iland.Task, error)
+	RemoveSnapshot(ctx context.Context) (iland.Task, error)
+}
+
+type vAppTarget struct {
+	vApp iland.VApp
+}
+
+// VAppTarget returns the Target of a vApp, which snapshots all its VMs.
+func VAppTarget(vApp iland.VApp) Target {
+	return vAppTarget{vApp: vApp}
+}
+
+func (t vAppTarget) UUID() string {
+	return t.vApp.UUID
+}
+
+func (t vAppTarget) Name() string {
+	return t.vApp.Name
+}
+
+func (t vAppTarget) HasSnapshot(ctx context.Context) (bool, error) {
+	return t.vApp.HasSnapshotContext(ctx)
+}
+
+func (t vAppTarget) GetSnapshot(ctx context.Context) (iland.Snapshot, error) {
+	return t.vApp.GetSnapshotContext(ctx)
+}
+
+func (t vAppTarget) TakeSnapshot(ctx context.Context, opts iland.SnapshotOptions) (iland.Task, error) {
+	return t.vApp.TakeSnapshotWithOptionsContext(ctx, opts)
+}
+
+func (t vAppTarget) RemoveSnapshot(ctx context.Context) (iland.Task, error) {
+	return t.vApp.RemoveSnapshotContext(ctx)
+}
+
+type virtualMachineTarget struct {
+	vm iland.VirtualMachine
+}
+
+// VirtualMachineTarget returns the Target of a VM.
+func VirtualMachineTarget(vm iland.VirtualMachine) Target {
+	return virtualMachineTarget{vm: vm}
+}
+
+func (t virtualMachineTarget) UUID() string {
+	return t.vm.UUID
+}
+
+func (t virtualMachineTarget) Name() string {
+	return t.vm.Name
+}
+
+func (t virtualMachineTarget) HasSnapshot(ctx context.Context) (bool, error) {
+	return t.vm.HasSnapshotContext(ctx)
+}
+
+func (t virtualMachineTarget) GetSnapshot(ctx context.Context) (iland.Snapshot, error) {
+	return t.vm.GetSnapshotContext(ctx)
+}
+
+func (t virtualMachineTarget) TakeSnapshot(ctx context.Context, opts iland.SnapshotOptions) (iland.Task, error) {
+	return t.vm.TakeSnapshotContext(ctx, opts)
+}
+
+func (t virtualMachineTarget) RemoveSnapshot(ctx context.Context) (iland.Task, error) {
+	return t.vm.RemoveSnapshotContext(ctx)
+}
+
+// Policy configures a Scheduler.
+type Policy struct {
+	// Schedule is required.
+	Schedule Schedule
+	// MaxAge is the age past which a snapshot is stale. A run removes the
+	// stale snapshot of a target before taking a new one, so that it is gone
+	// even if the new one fails. Snapshots without a creation date are never
+	// considered stale. A zero MaxAge keeps snapshots until they are replaced.
+	MaxAge time.Duration
+	// ReplaceExisting replaces a snapshot that the scheduler did not take and
+	// that is not stale. Otherwise the target is skipped until the snapshot
+	// becomes stale. The scheduler's own snapshots are replaced by every run.
+	ReplaceExisting bool
+	// NamePrefix identifies the snapshots taken by the scheduler, which are
+	// named after it and the time they are taken, "scheduled " by default.
+	NamePrefix string
+	// Options are the options of the snapshots taken. If Options.Name is
+	// set, the snapshots are named after it instead of NamePrefix, and it
+	// identifies the scheduler's snapshots.
+	Options iland.SnapshotOptions
+	// Wait configures how the snapshot tasks are waited for.
+	Wait iland.WaitOptions
+	// Concurrency is the number of targets handled at once, 4 by default.
+	Concurrency int
+	// OnError, if set, is called with the target and the error of every
+	// failed run of a target. The error wraps an *iland.TaskFailedError if the
+	// task of the snapshot or of its removal failed.
+	OnError func(target Target, err error)
+	// OnResult, if set, is called with the result of every run of a target,
+	// whether it failed or not.
+	//
+	// OnError and OnResult are called from the goroutines that handle the
+	// targets, up to Concurrency at once, and must be safe for concurrent
+	// use.
+	OnResult func(result Result)
+}
+
+const (
+	defaultConcurrency = 4
+	defaultNamePrefix  = "scheduled "
+)
+
+const (
+	// ActionTaken is the action of a run that took a snapshot.
+	ActionTaken = "taken"
+	// ActionSkipped is the action of a run that left a snapshot that the
+	// scheduler did not take, and that is not stale, in place.
+	ActionSkipped = "skipped"
+	// ActionFailed is the action of a run that failed.
+	ActionFailed = "failed"
+)
+
+// Result is the outcome of a run for one target.
+type Result struct {
+	Target Target
+	// Action is ActionTaken, ActionSkipped or ActionFailed.
+	Action string
+	// Removed is set if a stale snapshot was removed.
+	Removed bool
+	// Previous is the snapshot the target had before the run, if any.
+	Previous *iland.Snapshot
+	// Task is the task of the snapshot taken.
+	Task iland.Task
+	Err  error
+	Time time.Time
+}
+
+// Scheduler runs a Policy for a set of targets.
+type Scheduler struct {
+	policy  Policy
+	targets []Target
+	now     func() time.Time
+}
+
+func New(policy Policy, targets ...Target) (*Scheduler, error) {
+	if policy.Schedule == nil {
+		return nil, errors.New("scheduler policy requires a schedule")
+	}
+	if policy.Concurrency < 1 {
+		policy.Concurrency = defaultConcurrency
+	}
+	if policy.NamePrefix == "" {
+		policy.NamePrefix = defaultNamePrefix
+	}
+	return &Scheduler{
+		policy:  policy,
+		targets: targets,
+		now:     time.Now,
+	}, nil
+}
+
+// Run runs the policy at every time of the schedule until ctx is done, and
+// returns ctx's error, or an error if the schedule has no next time. The next
+// time is computed from the previous scheduled time, not from the end of the
+// previous run. Times that pass while a run is still going are skipped.
+func (s *Scheduler) Run(ctx context.Context) error {
+	scheduled := s.now()
+	for {
+		next := s.policy.Schedule.Next(scheduled)
+		if now := s.now(); !next.IsZero() && next.Before(now) {
+			next = s.policy.Schedule.Next(now)
+		}
+		if next.IsZero() {
+			return errors.New("schedule has no next run")
+		}
+		timer := time.NewTimer(next.Sub(s.now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		s.RunOnce(ctx)
+		scheduled = next
+	}
+}
+
+// RunOnce runs the policy for every target now, and returns the results in
+// the order of the targets.
+func (s *Scheduler) RunOnce(ctx context.Context) []Result {
+	results := make([]Result, len(s.targets))
+	wg := sync.WaitGroup{}
+	slots := make(chan struct{}, s.policy.Concurrency)
+	for i, target := range s.targets {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, target Target) {
+			defer func() { <-slots; wg.Done() }()
+			results[i] = s.run(ctx, target)
+		}(i, target)
+	}
+	wg.Wait()
+	return results
+}
+
+func (s *Scheduler) run(ctx context.Context, target Target) Result {
+	result := Result{Target: target, Time: s.now()}
+	err := s.snapshot(ctx, target, &result)
+	if err != nil {
+		result.Action = ActionFailed
+		result.Err = err
+		if s.policy.OnError != nil {
+			s.policy.OnError(target, err)
+		}
+	}
+	if s.policy.OnResult != nil {
+		s.policy.OnResult(result)
+	}
+	return result
+}
+
+func (s *Scheduler) snapshot(ctx context.Context, target Target, result *Result) error {
+	hasSnapshot, err := target.HasSnapshot(ctx)
+	if err != nil {
+		return fmt.Errorf("checking snapshot of %s: %w", target.Name(), err)
+	}
+	if hasSnapshot {
+		snapshot, err := target.GetSnapshot(ctx)
+		if err != nil {
+			return fmt.Errorf("getting snapshot of %s: %w", target.Name(), err)
+		}
+		result.Previous = &snapshot
+		if s.stale(snapshot) {
+			task, err := target.RemoveSnapshot(ctx)
+			if err != nil {
+				return fmt.Errorf("removing stale snapshot of %s: %w", target.Name(), err)
+			}
+			if _, err := task.Wait(ctx, s.policy.Wait); err != nil {
+				return fmt.Errorf("removing stale snapshot of %s: %w", target.Name(), err)
+			}
+			result.Removed = true
+		} else if !s.policy.ReplaceExisting && !s.owned(snapshot) {
+			result.Action = ActionSkipped
+			return nil
+		}
+	}
+	opts := s.policy.Options
+	if opts.Name == "" {
+		opts.Name = s.policy.NamePrefix + s.now().UTC().Format(time.RFC3339)
+	}
+	task, err := target.TakeSnapshot(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("taking snapshot of %s: %w", target.Name(), err)
+	}
+	result.Task = task
+	task, err = task.Wait(ctx, s.policy.Wait)
+	result.Task = task
+	if err != nil {
+		return fmt.Errorf("taking snapshot of %s: %w", target.Name(), err)
+	}
+	result.Action = ActionTaken
+	return nil
+}
+
+// owned reports whether the snapshot was taken by the scheduler.
+func (s *Scheduler) owned(snapshot iland.Snapshot) bool {
+	if s.policy.Options.Name != "" {
+		return snapshot.Name == s.policy.Options.Name
+	}
+	return strings.HasPrefix(snapshot.Name, s.policy.NamePrefix)
+}
+
+func (s *Scheduler) stale(snapshot iland.Snapshot) bool {
+	if s.policy.MaxAge <= 0 || snapshot.CreationDate.IsZero() {
+		return false
+	}
+	return s.now().Sub(snapshot.CreationDate) > s.policy.MaxAge
+}
@@ -0,0 +1,177 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	iland "github.com/jrperry/golang-sdk"
+)
+
+// fakeVApps serves the snapshot endpoints of vApps that have the given
+// snapshots, and records the snapshot requests.
+type fakeVApps struct {
+	mu        sync.Mutex
+	snapshots map[string]*iland.Snapshot
+	requests  []string
+}
+
+func (f *fakeVApps) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case parts[0] == "task" && parts[2] == "entity":
+		fmt.Fprint(w, `[]`)
+	case parts[0] == "task":
+		fmt.Fprintf(w, `{"uuid":%q,"location_id":"l","status":"success","active":false,"synchronized":true}`, parts[2])
+	case len(parts) == 2:
+		fmt.Fprintf(w, `{"uuid":%q,"name":%q,"location_id":"l"}`, parts[1], parts[1])
+	case len(parts) == 4 && parts[3] == "check":
+		fmt.Fprintf(w, `{"has_snapshot":%t}`, f.snapshots[parts[1]] != nil)
+	case r.Method == "GET":
+		json.NewEncoder(w).Encode(f.snapshots[parts[1]])
+	case r.Method == "POST":
+		opts := iland.SnapshotOptions{}
+		json.NewDecoder(r.Body).Decode(&opts)
+		f.snapshots[parts[1]] = &iland.Snapshot{Name: opts.Name, CreationDate: time.Now()}
+		f.requests = append(f.requests, "take "+parts[1]+" "+opts.Name)
+		fmt.Fprint(w, `{"uuid":"take","location_id":"l"}`)
+	case r.Method == "DELETE":
+		f.snapshots[parts[1]] = nil
+		f.requests = append(f.requests, "remove "+parts[1])
+		fmt.Fprint(w, `{"uuid":"remove","location_id":"l"}`)
+	}
+}
+
+func TestSchedulerRunOnce(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name         string
+		policy       Policy
+		snapshot     *iland.Snapshot
+		wantAction   string
+		wantRequests []string
+	}{
+		{
+			name:         "no snapshot",
+			wantAction:   ActionTaken,
+			wantRequests: []string{"take app scheduled "},
+		},
+		{
+			name:         "own snapshot within max age",
+			snapshot:     &iland.Snapshot{Name: "scheduled 2026-10-17T02:00:00Z", CreationDate: now.Add(-24 * time.Hour)},
+			wantAction:   ActionTaken,
+			wantRequests: []string{"take app scheduled "},
+		},
+		{
+			name:         "own named snapshot within max age",
+			policy:       Policy{Options: iland.SnapshotOptions{Name: "nightly"}},
+			snapshot:     &iland.Snapshot{Name: "nightly", CreationDate: now.Add(-24 * time.Hour)},
+			wantAction:   ActionTaken,
+			wantRequests: []string{"take app nightly"},
+		},
+		{
+			name:         "other snapshot within max age",
+			snapshot:     &iland.Snapshot{Name: "before upgrade", CreationDate: now.Add(-24 * time.Hour)},
+			wantAction:   ActionSkipped,
+			wantRequests: nil,
+		},
+		{
+			name:         "other snapshot replaced",
+			policy:       Policy{ReplaceExisting: true},
+			snapshot:     &iland.Snapshot{Name: "before upgrade", CreationDate: now.Add(-24 * time.Hour)},
+			wantAction:   ActionTaken,
+			wantRequests: []string{"take app scheduled "},
+		},
+		{
+			name:         "stale snapshot",
+			snapshot:     &iland.Snapshot{Name: "before upgrade", CreationDate: now.Add(-48 * time.Hour)},
+			wantAction:   ActionTaken,
+			wantRequests: []string{"remove app", "take app scheduled "},
+		},
+		{
+			name:         "snapshot without creation date",
+			snapshot:     &iland.Snapshot{Name: "before upgrade"},
+			wantAction:   ActionSkipped,
+			wantRequests: nil,
+		},
+	}
+	for _, test := range tests {
+		fake := &fakeVApps{snapshots: map[string]*iland.Snapshot{"app": test.snapshot}}
+		server := httptest.NewServer(fake)
+		client, err := iland.NewClientWithOptions("", "", "", "",
+			iland.WithBaseURL(server.URL),
+			iland.WithTokenSource(iland.StaticTokenSource(iland.Token{AccessToken: "token", ExpiresIn: 3600})),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vApp, err := client.GetVApp("app")
+		if err != nil {
+			t.Fatal(err)
+		}
+		policy := test.policy
+		policy.Schedule = Every(time.Hour)
+		policy.MaxAge = 36 * time.Hour
+		policy.Wait = iland.WaitOptions{PollInterval: time.Millisecond}
+		s, err := New(policy, VAppTarget(vApp))
+		if err != nil {
+			t.Fatal(err)
+		}
+		results := s.RunOnce(context.Background())
+		server.Close()
+
+		result := results[0]
+		if result.Action != test.wantAction || result.Err != nil {
+			t.Errorf("%s: action = %q, error = %v, want %q", test.name, result.Action, result.Err, test.wantAction)
+		}
+		requests := []string{}
+		for _, request := range fake.requests {
+			// the default names end with the time the snapshot is taken.
+			if i := strings.Index(request, "scheduled "); i >= 0 {
+				request = request[:i+len("scheduled ")]
+			}
+			requests = append(requests, request)
+		}
+		if strings.Join(requests, ", ") != strings.Join(test.wantRequests, ", ") {
+			t.Errorf("%s: requests = %q, want %q", test.name, requests, test.wantRequests)
+		}
+	}
+}
+
+func TestSchedulerOnError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/check") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, `{"uuid":"app","name":"app","location_id":"l"}`)
+	}))
+	defer server.Close()
+	client, _ := iland.NewClientWithOptions("", "", "", "",
+		iland.WithBaseURL(server.URL),
+		iland.WithTokenSource(iland.StaticTokenSource(iland.Token{AccessToken: "token", ExpiresIn: 3600})),
+	)
+	vApp, err := client.GetVApp("app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var errs []error
+	s, _ := New(Policy{
+		Schedule: Every(time.Hour),
+		OnError: func(target Target, err error) {
+			errs = append(errs, err)
+		},
+	}, VAppTarget(vApp))
+	results := s.RunOnce(context.Background())
+	if results[0].Action != ActionFailed || len(errs) != 1 || !iland.IsForbidden(errs[0]) {
+		t.Errorf("action = %q, errors = %v, want a forbidden error", results[0].Action, errs)
+	}
+}
@@ -2,6 +2,7 @@ package iland
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -14,9 +15,10 @@ import (
 )
 
 type Token struct {
-	AccessToken  string `json:"access_token"`
-	ExpiresIn    int64  `json:"expires_in"`
-	RefreshToken string `json:"refresh_token"`
+	AccessToken  string    `json:"access_token"`
+	ExpiresIn    int64     `json:"expires_in"`
+	RefreshToken string    `json:"refresh_token"`
+	Expiry       time.Time `json:"expiry,omitempty"`
 }
 
 type TokenRequest struct {
@@ -34,13 +36,7 @@ type RefreshTokenRequest struct {
 	GrantType    string `json:"grant_type"`
 }
 
-type APIError struct {
-	Error         string `json:"error"`
-	Message       string `json:"message"`
-	DetailMessage string `json:"detail_message"`
-}
-
-func (c *Client) getToken() error {
+func (c *Client) getToken(ctx context.Context) (Token, error) {
 	tokenRequest := TokenRequest{c.clientID, c.clientSecret, c.username, c.password, "password"}
 	form := url.Values{}
 	form.Add("client_id", tokenRequest.ClientID)
@@ -48,141 +44,185 @@ func (c *Client) getToken() error {
 	form.Add("username", tokenRequest.Username)
 	form.Add("password", tokenRequest.Password)
 	form.Add("grant_type", tokenRequest.GrantType)
-	resp, err := http.Post(accessURL, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	resp, err := c.postTokenForm(ctx, c.accessURL, form)
 	if err != nil {
-		return err
-	}
-	if resp.StatusCode >= 300 {
-		return fmt.Errorf("Could not retrieve a token.")
+		return Token{}, err
 	}
 	defer resp.Body.Close()
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return err
+		return Token{}, err
+	}
+	if resp.StatusCode >= 300 {
+		e := newAPIError("POST", c.accessURL, resp.StatusCode, body)
+		if e.Message == "" {
+			e.Message = "Could not retrieve a token."
+		}
+		return Token{}, e
 	}
 	var t Token
 	err = json.Unmarshal(body, &t)
 	if err != nil {
-		return err
+		return Token{}, err
 	}
-	c.Token = t
-	c.setTokenExpiration()
-	return nil
+	t.Expiry = time.Now().Add(time.Duration(t.ExpiresIn) * time.Second)
+	return t, nil
 }
 
-func (c *Client) refreshToken() error {
-	tokenRequest := RefreshTokenRequest{c.clientID, c.clientSecret, c.Token.RefreshToken, "refresh_token"}
+func (c *Client) refreshToken(ctx context.Context, refreshToken string) (Token, error) {
+	tokenRequest := RefreshTokenRequest{c.clientID, c.clientSecret, refreshToken, "refresh_token"}
 	form := url.Values{}
 	form.Add("client_id", tokenRequest.ClientID)
 	form.Add("client_secret", tokenRequest.ClientSecret)
 	form.Add("refresh_token", tokenRequest.RefreshToken)
 	form.Add("grant_type", tokenRequest.GrantType)
-	resp, err := http.Post(refreshURL, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	resp, err := c.postTokenForm(ctx, c.refreshURL, form)
 	if err != nil {
-		return err
-	}
-	if resp.StatusCode >= 300 {
-		return fmt.Errorf("Could not refresh current token.")
+		return Token{}, err
 	}
 	defer resp.Body.Close()
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return err
+		return Token{}, err
+	}
+	if resp.StatusCode >= 300 {
+		e := newAPIError("POST", c.refreshURL, resp.StatusCode, body)
+		if e.Message == "" {
+			e.Message = "Could not refresh current token."
+		}
+		return Token{}, e
 	}
 	var t Token
 	err = json.Unmarshal(body, &t)
 	if err != nil {
-		return err
+		return Token{}, err
 	}
-	c.Token = t
-	c.setTokenExpiration()
-	return nil
+	t.Expiry = time.Now().Add(time.Duration(t.ExpiresIn) * time.Second)
+	return t, nil
 }
 
-func (c *Client) setTokenExpiration() {
-	c.tokenExpiration = time.Now().Add(time.Duration(c.Token.ExpiresIn-60) * time.Second)
+func (c *Client) postTokenForm(ctx context.Context, tokenURL string, form url.Values) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	if c.userAgent != "" {
+		req.Header.Add("User-Agent", c.userAgent)
+	}
+	return c.httpClient.Do(req)
 }
 
 func removeJSONHijackingPrefix(b []byte) []byte {
 	return bytes.TrimPrefix(b, []byte(")]}'"))
 }
 
-func (c *Client) request(relPath, verb string, payload []byte) ([]byte, error) {
-	err := c.RefreshTokenIfNecessary()
+func (c *Client) newRequest(ctx context.Context, verb, relPath string, body io.Reader, contentType, accept string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, verb, c.baseURL+relPath, body)
 	if err != nil {
-		return []byte{}, err
+		return nil, err
 	}
-	client := &http.Client{}
-	path := apiBaseURL + relPath
-	bytesJSON := bytes.NewBuffer(payload)
-	req, err := http.NewRequest(verb, path, bytesJSON)
-	req.Header.Add("Authorization", "Bearer "+c.Token.AccessToken)
-	req.Header.Add("Accept", "application/vnd.ilandcloud.api.v0.8+json")
-	req.Header.Add("Content-Type", "application/vnd.ilandcloud.api.v0.8+json")
-	resp, err := client.Do(req)
-	if err != nil {
-		return []byte{}, err
+	if accept != "" {
+		req.Header.Add("Accept", accept)
 	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return []byte{}, err
+	req.Header.Add("Content-Type", contentType)
+	if c.userAgent != "" {
+		req.Header.Add("User-Agent", c.userAgent)
 	}
+	return req, nil
+}
 
-	statusCode := resp.StatusCode
-	responseBody := removeJSONHijackingPrefix(body)
-	if statusCode >= 300 {
-
-		var e APIError
-		err = json.Unmarshal(responseBody, &e)
+// send performs a request, retrying transient failures according to the
+// client's retry policy, and returns the response along with the number of
+// attempts made. The caller must close the response body.
+func (c *Client) send(ctx context.Context, verb, relPath string, payload []byte, contentType, accept string) (*http.Response, int, error) {
+	attempt := 0
+	for {
+		attempt++
+		token, err := c.token(ctx)
 		if err != nil {
-			return []byte{}, fmt.Errorf("Error marshaling ApiError: %s", err.Error())
+			return nil, attempt, err
 		}
-		var errMsg string
-		if e.DetailMessage != "" {
-			errMsg = e.DetailMessage
+		req, err := c.newRequest(ctx, verb, relPath, bytes.NewReader(payload), contentType, accept)
+		if err != nil {
+			return nil, attempt, err
+		}
+		req.Header.Add("Authorization", "Bearer "+token.AccessToken)
+		release, err := c.acquireSlot(ctx)
+		if err != nil {
+			return nil, attempt, err
+		}
+		req = c.beforeRequest(req)
+		start := time.Now()
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			release()
 		} else {
-			errMsg = e.Message
+			resp.Body = releaseOnClose{resp.Body, release}
+		}
+		retrying := c.retryPolicy.shouldRetry(ctx, verb, attempt, resp, err)
+		c.afterResponse(req, RequestResult{
+			Path:     relPath,
+			Attempt:  attempt,
+			Duration: time.Since(start),
+			Response: resp,
+			Err:      err,
+			Retrying: retrying,
+		})
+		if !retrying {
+			if err != nil && attempt > 1 {
+				err = fmt.Errorf("%w (after %d attempts)", err, attempt)
+			}
+			return resp, attempt, err
+		}
+		wait := c.retryPolicy.backoff(attempt, resp)
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		select {
+		case <-ctx.Done():
+			return nil, attempt, ctx.Err()
+		case <-time.After(wait):
 		}
-		return responseBody, errors.New(errMsg)
 	}
-
-	return responseBody, nil
 }
 
-func (c *Client) getBinaryStream(relPath string) (io.ReadCloser, error) {
-	err := c.RefreshTokenIfNecessary()
-	if err != nil {
-		return nil, err
+func (c *Client) request(ctx context.Context, relPath, verb string, payload []byte) ([]byte, error) {
+	if c.cache != nil {
+		return c.cachedRequest(ctx, relPath, verb, func() ([]byte, error) {
+			return c.sendAndRead(ctx, verb, relPath, payload, apiMediaType)
+		})
 	}
-	client := &http.Client{}
-	path := apiBaseURL + relPath
-	req, err := http.NewRequest("GET", path, nil)
+	return c.sendAndRead(ctx, verb, relPath, payload, apiMediaType)
+}
+
+func (c *Client) getBinaryStream(ctx context.Context, relPath string) (io.ReadCloser, error) {
+	resp, attempts, err := c.send(ctx, "GET", relPath, nil, apiMediaType, "")
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Add("Authorization", "Bearer "+c.Token.AccessToken)
-	req.Header.Add("Content-Type", "application/vnd.ilandcloud.api.v0.8+json")
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		e := newAPIError("GET", relPath, resp.StatusCode, removeJSONHijackingPrefix(body))
+		e.Attempts = attempts
+		return nil, e
 	}
 	return resp.Body, nil
 }
 
-func (c *Client) postForm(relPath, contentType string, payload []byte) ([]byte, error) {
-	err := c.RefreshTokenIfNecessary()
-	if err != nil {
-		return []byte{}, err
+func (c *Client) postForm(ctx context.Context, relPath, contentType string, payload []byte) ([]byte, error) {
+	if c.cache != nil {
+		return c.cachedRequest(ctx, relPath, "POST", func() ([]byte, error) {
+			return c.sendAndRead(ctx, "POST", relPath, payload, contentType)
+		})
 	}
-	client := &http.Client{}
-	path := apiBaseURL + relPath
-	bytesJSON := bytes.NewBuffer(payload)
-	req, err := http.NewRequest("POST", path, bytesJSON)
-	req.Header.Add("Authorization", "Bearer "+c.Token.AccessToken)
-	req.Header.Add("Accept", "application/vnd.ilandcloud.api.v0.8+json")
-	req.Header.Add("Content-Type", contentType)
-	resp, err := client.Do(req)
+	return c.sendAndRead(ctx, "POST", relPath, payload, contentType)
+}
+
+func (c *Client) sendAndRead(ctx context.Context, verb, relPath string, payload []byte, contentType string) ([]byte, error) {
+	resp, attempts, err := c.send(ctx, verb, relPath, payload, contentType, apiMediaType)
 	if err != nil {
 		return []byte{}, err
 	}
@@ -191,48 +231,36 @@ func (c *Client) postForm(relPath, contentType string, payload []byte) ([]byte,
 	if err != nil {
 		return []byte{}, err
 	}
+
 	statusCode := resp.StatusCode
 	responseBody := removeJSONHijackingPrefix(body)
 	if statusCode >= 300 {
-		var e APIError
-		err = json.Unmarshal(responseBody, &e)
-		if err != nil {
-			return []byte{}, fmt.Errorf("Error marshaling ApiError: %s", err.Error())
-		}
-		var errMsg string
-		if e.DetailMessage != "" {
-			errMsg = e.DetailMessage
-		} else {
-			errMsg = e.Message
-		}
-		return responseBody, errors.New(errMsg)
+		e := newAPIError(verb, relPath, statusCode, responseBody)
+		e.Attempts = attempts
+		return responseBody, e
 	}
+
 	return responseBody, nil
 }
 
 func (c *Client) RefreshTokenIfNecessary() error {
-	emptyToken := Token{}
-	if c == nil || c.Token == emptyToken {
-		err := c.getToken()
-		if err != nil {
-			return fmt.Errorf("Error retrieving iland cloud API token. %s", err.Error())
-		}
-	}
-	if c.isTokenExpiringSoon() {
-		err := c.refreshToken()
-		if err != nil {
-			err := c.getToken()
-			if err != nil {
-				return fmt.Errorf("Error refreshing iland cloud API token. %s", err.Error())
-			}
-		}
-	}
-	return nil
+	return c.RefreshTokenIfNecessaryContext(context.Background())
 }
 
-func (c *Client) isTokenExpiringSoon() bool {
-	if time.Now().After(c.tokenExpiration) {
-		return true
+// RefreshTokenIfNecessaryContext renews the client's token if it is about
+// to expire. It is safe to call from multiple goroutines.
+func (c *Client) RefreshTokenIfNecessaryContext(ctx context.Context) error {
+	_, err := c.token(ctx)
+	return err
+}
+
+func (c *Client) token(ctx context.Context) (*Token, error) {
+	if c.tokens == nil {
+		return nil, errors.New("client has no token source, use NewClient to create it")
+	}
+	token, err := c.tokens.token(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Error retrieving iland cloud API token. %w", err)
 	}
-	return false
+	return token, nil
 }
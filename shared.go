@@ -98,11 +98,33 @@ type BillingLineItem struct {
 }
 
 type Snapshot struct {
+	Name         string    `json:"name"`
+	Description  string    `json:"description"`
 	Size         int       `json:"size"`
 	IsPoweredOn  bool      `json:"is_powered_on"`
+	Memory       bool      `json:"memory"`
+	Quiesced     bool      `json:"quiesce"`
 	CreationDate time.Time `json:"creation_date"`
 }
 
+// SnapshotOptions configure a new snapshot. Memory includes the memory of
+// powered on VMs in the snapshot, and Quiesce asks VMware Tools to flush the
+// guest file systems first, for application-consistent snapshots.
+type SnapshotOptions struct {
+	// Name defaults to the current time.
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Memory      bool   `json:"memory"`
+	Quiesce     bool   `json:"quiesce"`
+}
+
+func (o SnapshotOptions) withDefaults() SnapshotOptions {
+	if o.Name == "" {
+		o.Name = time.Now().UTC().String()
+	}
+	return o
+}
+
 func getPerfLimit(perfInterval string) string {
 	switch perfInterval {
 	case PerfIntervalSecond:
@@ -0,0 +1,335 @@
+package iland
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// inventorySnapshotVersion is the version of the serialized format.
+const inventorySnapshotVersion = 1
+
+// InventorySnapshot is the inventory of an account at one point in time, as
+// a tree: the locations contain their orgs, the orgs their vdcs, catalogs,
+// edges and vdc networks, the vdcs their storage profiles and vApps, the
+// vApps their virtual machines and networks, and the catalogs their vApp
+// templates and media. Every resource also keeps the UUIDs of its parents.
+//
+// A snapshot serializes to JSON or YAML with the same field names as the
+// API. The resources of a snapshot read back with ReadInventorySnapshot are
+// not connected to a Client, so only their fields can be used.
+type InventorySnapshot struct {
+	Version   int                `json:"version"`
+	TakenAt   time.Time          `json:"taken_at"`
+	Locations []LocationSnapshot `json:"locations"`
+}
+
+type LocationSnapshot struct {
+	Location
+	Orgs []OrgSnapshot `json:"orgs"`
+}
+
+type OrgSnapshot struct {
+	Org
+	Vdcs        []VdcSnapshot     `json:"vdcs"`
+	Catalogs    []CatalogSnapshot `json:"catalogs"`
+	Edges       []EdgeSnapshot    `json:"edges"`
+	VdcNetworks []VdcNetwork      `json:"vdc_networks"`
+}
+
+type VdcSnapshot struct {
+	Vdc
+	StorageProfiles []StorageProfile `json:"storage_profiles"`
+	VApps           []VAppSnapshot   `json:"vapps"`
+}
+
+type VAppSnapshot struct {
+	VApp
+	Networks        []VAppNetwork            `json:"networks"`
+	VirtualMachines []VirtualMachineSnapshot `json:"vms"`
+}
+
+type VirtualMachineSnapshot struct {
+	VirtualMachine
+	Disks []Disk      `json:"disks"`
+	Nics  []Nic       `json:"nics"`
+	Tools VMwareTools `json:"tools"`
+}
+
+type EdgeSnapshot struct {
+	Edge
+	Firewall EdgeFirewallConfig `json:"firewall"`
+	NAT      EdgeNATConfig      `json:"nat"`
+}
+
+type CatalogSnapshot struct {
+	Catalog
+	VAppTemplates []VAppTemplate `json:"vapp_templates"`
+	Medias        []Media        `json:"medias"`
+}
+
+func (c *Client) SnapshotInventory() (InventorySnapshot, error) {
+	return c.SnapshotInventoryContext(context.Background())
+}
+
+// SnapshotInventoryContext walks the whole inventory of the account. The
+// parts that cannot be fetched are left out of the snapshot and reported in
+// a *ListError, unless the locations themselves cannot be listed.
+func (c *Client) SnapshotInventoryContext(ctx context.Context) (InventorySnapshot, error) {
+	snapshot := InventorySnapshot{Version: inventorySnapshotVersion, TakenAt: time.Now().UTC()}
+	locations, err := c.GetLocationsContext(ctx)
+	if err != nil {
+		return snapshot, err
+	}
+	s := inventoryWalk{client: c, ctx: ctx}
+	snapshot.Locations = make([]LocationSnapshot, len(locations))
+	for i, location := range locations {
+		snapshot.Locations[i].Location = location
+	}
+	s.orgs(snapshot.Locations)
+	return snapshot, s.errs.errOrNil()
+}
+
+// inventoryWalk fills an InventorySnapshot one level of the tree at a time,
+// fetching the children of all the resources of a level at once.
+type inventoryWalk struct {
+	client *Client
+	ctx    context.Context
+	mu     sync.Mutex
+	errs   ListError
+}
+
+func (s *inventoryWalk) fail(scope, id string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errs.add(scope, id, err)
+}
+
+// get fetches the JSON at relPath into v, recording the failure if any.
+func (s *inventoryWalk) get(scope, id, relPath string, v interface{}) {
+	if err := s.client.getJSON(s.ctx, relPath, v); err != nil {
+		s.fail(scope, id, err)
+	}
+}
+
+func (s *inventoryWalk) orgs(locations []LocationSnapshot) {
+	s.client.forEach(len(locations), func(i int) {
+		orgs := []Org{}
+		s.get("location", locations[i].ID, fmt.Sprintf("/location/%s/orgs", locations[i].ID), &orgs)
+		locations[i].Orgs = make([]OrgSnapshot, len(orgs))
+		for j, org := range orgs {
+			org.client = s.client
+			locations[i].Orgs[j].Org = org
+		}
+	})
+	orgs := []*OrgSnapshot{}
+	for i := range locations {
+		for j := range locations[i].Orgs {
+			orgs = append(orgs, &locations[i].Orgs[j])
+		}
+	}
+	s.orgChildren(orgs)
+}
+
+func (s *inventoryWalk) orgChildren(orgs []*OrgSnapshot) {
+	s.client.forEach(len(orgs), func(i int) {
+		org := orgs[i]
+		vdcs := []Vdc{}
+		s.get("org", org.UUID, fmt.Sprintf("/org/%s/vdcs", org.UUID), &vdcs)
+		org.Vdcs = make([]VdcSnapshot, len(vdcs))
+		for j, vdc := range vdcs {
+			vdc.client = s.client
+			org.Vdcs[j].Vdc = vdc
+		}
+		catalogs := []Catalog{}
+		s.get("org", org.UUID, fmt.Sprintf("/org/%s/catalogs", org.UUID), &catalogs)
+		org.Catalogs = make([]CatalogSnapshot, len(catalogs))
+		for j, catalog := range catalogs {
+			catalog.client = s.client
+			org.Catalogs[j].Catalog = catalog
+		}
+		edges := []Edge{}
+		s.get("org", org.UUID, fmt.Sprintf("/org/%s/edges", org.UUID), &edges)
+		org.Edges = make([]EdgeSnapshot, len(edges))
+		for j, edge := range edges {
+			edge.client = s.client
+			org.Edges[j].Edge = edge
+		}
+		org.VdcNetworks = []VdcNetwork{}
+		s.get("org", org.UUID, fmt.Sprintf("/org/%s/vdc-networks", org.UUID), &org.VdcNetworks)
+		for j := range org.VdcNetworks {
+			org.VdcNetworks[j].client = s.client
+		}
+	})
+
+	vdcs := []*VdcSnapshot{}
+	catalogs := []*CatalogSnapshot{}
+	edges := []*EdgeSnapshot{}
+	for _, org := range orgs {
+		for j := range org.Vdcs {
+			vdcs = append(vdcs, &org.Vdcs[j])
+		}
+		for j := range org.Catalogs {
+			catalogs = append(catalogs, &org.Catalogs[j])
+		}
+		for j := range org.Edges {
+			edges = append(edges, &org.Edges[j])
+		}
+	}
+	s.edgeConfigs(edges)
+	s.catalogChildren(catalogs)
+	s.vdcChildren(vdcs)
+}
+
+func (s *inventoryWalk) edgeConfigs(edges []*EdgeSnapshot) {
+	s.client.forEach(len(edges), func(i int) {
+		edge := edges[i]
+		s.get("edge", edge.UUID, fmt.Sprintf("/edge/%s/firewall", edge.UUID), &edge.Firewall)
+		s.get("edge", edge.UUID, fmt.Sprintf("/edge/%s/nat", edge.UUID), &edge.NAT)
+	})
+}
+
+func (s *inventoryWalk) catalogChildren(catalogs []*CatalogSnapshot) {
+	s.client.forEach(len(catalogs), func(i int) {
+		catalog := catalogs[i]
+		catalog.VAppTemplates = []VAppTemplate{}
+		s.get("catalog", catalog.UUID, fmt.Sprintf("/catalog/%s/vapp-templates", catalog.UUID), &catalog.VAppTemplates)
+		for j := range catalog.VAppTemplates {
+			catalog.VAppTemplates[j].client = s.client
+		}
+		catalog.Medias = []Media{}
+		s.get("catalog", catalog.UUID, fmt.Sprintf("/catalog/%s/medias", catalog.UUID), &catalog.Medias)
+		for j := range catalog.Medias {
+			catalog.Medias[j].client = s.client
+		}
+	})
+}
+
+func (s *inventoryWalk) vdcChildren(vdcs []*VdcSnapshot) {
+	s.client.forEach(len(vdcs), func(i int) {
+		vdc := vdcs[i]
+		vdc.StorageProfiles = []StorageProfile{}
+		s.get("vdc", vdc.UUID, fmt.Sprintf("/vdc/%s/storage-profiles", vdc.UUID), &vdc.StorageProfiles)
+		vApps := []VApp{}
+		s.get("vdc", vdc.UUID, fmt.Sprintf("/vdc/%s/vapps", vdc.UUID), &vApps)
+		vdc.VApps = make([]VAppSnapshot, len(vApps))
+		for j, vApp := range vApps {
+			vApp.client = s.client
+			vdc.VApps[j].VApp = vApp
+		}
+	})
+	vApps := []*VAppSnapshot{}
+	for _, vdc := range vdcs {
+		for j := range vdc.VApps {
+			vApps = append(vApps, &vdc.VApps[j])
+		}
+	}
+	s.vAppChildren(vApps)
+}
+
+func (s *inventoryWalk) vAppChildren(vApps []*VAppSnapshot) {
+	s.client.forEach(len(vApps), func(i int) {
+		vApp := vApps[i]
+		vApp.Networks = []VAppNetwork{}
+		s.get("vapp", vApp.UUID, fmt.Sprintf("/vapp/%s/networks", vApp.UUID), &vApp.Networks)
+		for j := range vApp.Networks {
+			vApp.Networks[j].client = s.client
+		}
+		virtualMachines := []VirtualMachine{}
+		s.get("vapp", vApp.UUID, fmt.Sprintf("/vapp/%s/vms", vApp.UUID), &virtualMachines)
+		vApp.VirtualMachines = make([]VirtualMachineSnapshot, len(virtualMachines))
+		for j, virtualMachine := range virtualMachines {
+			virtualMachine.client = s.client
+			vApp.VirtualMachines[j].VirtualMachine = virtualMachine
+		}
+	})
+	virtualMachines := []*VirtualMachineSnapshot{}
+	for _, vApp := range vApps {
+		for j := range vApp.VirtualMachines {
+			virtualMachines = append(virtualMachines, &vApp.VirtualMachines[j])
+		}
+	}
+	s.virtualMachineDetails(virtualMachines)
+}
+
+func (s *inventoryWalk) virtualMachineDetails(virtualMachines []*VirtualMachineSnapshot) {
+	s.client.forEach(len(virtualMachines), func(i int) {
+		virtualMachine := virtualMachines[i]
+		virtualMachine.Disks = []Disk{}
+		s.get("vm", virtualMachine.UUID, fmt.Sprintf("/vm/%s/virtual-disks", virtualMachine.UUID), &virtualMachine.Disks)
+		virtualMachine.Nics = []Nic{}
+		s.get("vm", virtualMachine.UUID, fmt.Sprintf("/vm/%s/vnics", virtualMachine.UUID), &virtualMachine.Nics)
+		s.get("vm", virtualMachine.UUID, fmt.Sprintf("/vm/%s/tools", virtualMachine.UUID), &virtualMachine.Tools)
+	})
+}
+
+// WriteJSON writes the snapshot as indented JSON.
+func (s InventorySnapshot) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(&s)
+}
+
+// WriteYAML writes the snapshot as YAML, with the same field names as its
+// JSON.
+func (s InventorySnapshot) WriteYAML(w io.Writer) error {
+	data, err := json.Marshal(&s)
+	if err != nil {
+		return err
+	}
+	// JSON is YAML, decoding it into a node keeps the order of the fields.
+	node := yaml.Node{}
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return err
+	}
+	clearStyle(&node)
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&node); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// clearStyle switches a node decoded from JSON to the block style of YAML.
+func clearStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		clearStyle(child)
+	}
+}
+
+// ReadInventorySnapshot reads a snapshot written by WriteJSON or WriteYAML.
+func ReadInventorySnapshot(r io.Reader) (InventorySnapshot, error) {
+	snapshot := InventorySnapshot{}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return snapshot, err
+	}
+	trimmed := bytes.TrimSpace(data)
+	if !bytes.HasPrefix(trimmed, []byte("{")) {
+		var v interface{}
+		if err := yaml.Unmarshal(data, &v); err != nil {
+			return snapshot, err
+		}
+		data, err = json.Marshal(v)
+		if err != nil {
+			return snapshot, err
+		}
+	}
+	err = json.Unmarshal(data, &snapshot)
+	if err != nil {
+		return snapshot, err
+	}
+	if snapshot.Version > inventorySnapshotVersion {
+		return snapshot, fmt.Errorf("inventory snapshot version %d is not supported", snapshot.Version)
+	}
+	return snapshot, nil
+}
@@ -0,0 +1,318 @@
+package iland
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+// InventoryChange is a resource that was added, removed or changed between
+// two inventory snapshots.
+type InventoryChange struct {
+	// Type is ChangeAdded, ChangeRemoved or ChangeChanged.
+	Type string `json:"type"`
+	// Kind is the type of the resource: "location", "org", "vdc",
+	// "storage-profile", "vapp", "vapp-network", "vm", "edge",
+	// "vdc-network", "catalog", "vapp-template" or "media".
+	Kind string `json:"kind"`
+	// UUID is the UUID of the resource, or the ID of a location.
+	UUID string `json:"uuid"`
+	Name string `json:"name"`
+	// Fields are the differences of a changed resource.
+	Fields []FieldChange `json:"fields,omitempty"`
+}
+
+// FieldChange is the difference of one field of a changed resource. Path
+// names the field with its JSON name, e.g. memory_size, and the elements of
+// lists by their ID or name when they have one, e.g. nat.rules[id=3] or
+// disks[name=Hard disk 1].size. Old is nil for an added list element, New
+// for a removed one.
+type FieldChange struct {
+	Path string      `json:"path"`
+	Old  interface{} `json:"old"`
+	New  interface{} `json:"new"`
+}
+
+// InventoryDiff lists the changes between two inventory snapshots, ordered by
+// kind of resource, then by name.
+type InventoryDiff struct {
+	Changes []InventoryChange `json:"changes"`
+}
+
+// diffIgnoredFields change without the resource changing.
+var diffIgnoredFields = map[string]bool{
+	"updated_date": true,
+}
+
+// kindOrder orders the changes from the top of the inventory tree down.
+var kindOrder = map[string]int{
+	"location":        0,
+	"org":             1,
+	"vdc":             2,
+	"storage-profile": 3,
+	"vdc-network":     4,
+	"edge":            5,
+	"vapp":            6,
+	"vapp-network":    7,
+	"vm":              8,
+	"catalog":         9,
+	"vapp-template":   10,
+	"media":           11,
+}
+
+// DiffInventory compares two snapshots of the same account, e.g. last
+// night's and the current one, resource by resource.
+func DiffInventory(old, new InventorySnapshot) InventoryDiff {
+	oldResources := snapshotResources(old)
+	newResources := snapshotResources(new)
+	diff := InventoryDiff{Changes: []InventoryChange{}}
+	for key, before := range oldResources {
+		after, ok := newResources[key]
+		if !ok {
+			diff.Changes = append(diff.Changes, InventoryChange{Type: ChangeRemoved, Kind: key.kind, UUID: key.uuid, Name: before.name})
+			continue
+		}
+		fields := diffValues("", before.value, after.value)
+		if len(fields) > 0 {
+			diff.Changes = append(diff.Changes, InventoryChange{Type: ChangeChanged, Kind: key.kind, UUID: key.uuid, Name: after.name, Fields: fields})
+		}
+	}
+	for key, after := range newResources {
+		if _, ok := oldResources[key]; !ok {
+			diff.Changes = append(diff.Changes, InventoryChange{Type: ChangeAdded, Kind: key.kind, UUID: key.uuid, Name: after.name})
+		}
+	}
+	sort.Slice(diff.Changes, func(i, j int) bool {
+		a, b := diff.Changes[i], diff.Changes[j]
+		if a.Kind != b.Kind {
+			return kindOrder[a.Kind] < kindOrder[b.Kind]
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.UUID < b.UUID
+	})
+	return diff
+}
+
+// Empty reports whether the snapshots have the same resources.
+func (d InventoryDiff) Empty() bool {
+	return len(d.Changes) == 0
+}
+
+// String lists the changes one per line, with one indented line per field.
+func (d InventoryDiff) String() string {
+	b := strings.Builder{}
+	for _, change := range d.Changes {
+		fmt.Fprintf(&b, "%s %s %s (%s)\n", change.Type, change.Kind, change.Name, change.UUID)
+		for _, field := range change.Fields {
+			fmt.Fprintf(&b, "  %s: %s -> %s\n", field.Path, formatDiffValue(field.Old), formatDiffValue(field.New))
+		}
+	}
+	return b.String()
+}
+
+func formatDiffValue(v interface{}) string {
+	if v == nil {
+		return "(none)"
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+type resourceKey struct {
+	kind string
+	uuid string
+}
+
+type snapshotResource struct {
+	name  string
+	value interface{}
+}
+
+// snapshotResources flattens the tree of a snapshot. The value of each
+// resource is its JSON without its children, which are resources of their
+// own.
+func snapshotResources(snapshot InventorySnapshot) map[resourceKey]snapshotResource {
+	resources := map[resourceKey]snapshotResource{}
+	add := func(kind, uuid, name string, v interface{}) {
+		resources[resourceKey{kind, uuid}] = snapshotResource{name: name, value: genericJSON(v)}
+	}
+	for _, location := range snapshot.Locations {
+		add("location", location.ID, location.ID, location.Location)
+		for _, org := range location.Orgs {
+			add("org", org.UUID, org.Name, org.Org)
+			for _, vdc := range org.Vdcs {
+				add("vdc", vdc.UUID, vdc.Name, vdc.Vdc)
+				for _, storageProfile := range vdc.StorageProfiles {
+					add("storage-profile", storageProfile.UUID, storageProfile.Name, storageProfile)
+				}
+				for _, vApp := range vdc.VApps {
+					add("vapp", vApp.UUID, vApp.Name, vApp.VApp)
+					for _, network := range vApp.Networks {
+						add("vapp-network", network.UUID, network.Name, network)
+					}
+					for _, virtualMachine := range vApp.VirtualMachines {
+						add("vm", virtualMachine.UUID, virtualMachine.Name, virtualMachine)
+					}
+				}
+			}
+			for _, edge := range org.Edges {
+				add("edge", edge.UUID, edge.Name, edge)
+			}
+			for _, network := range org.VdcNetworks {
+				add("vdc-network", network.UUID, network.Name, network)
+			}
+			for _, catalog := range org.Catalogs {
+				add("catalog", catalog.UUID, catalog.Name, catalog.Catalog)
+				for _, vAppTemplate := range catalog.VAppTemplates {
+					add("vapp-template", vAppTemplate.UUID, vAppTemplate.Name, vAppTemplate)
+				}
+				for _, media := range catalog.Medias {
+					add("media", media.UUID, media.Name, media)
+				}
+			}
+		}
+	}
+	return resources
+}
+
+// genericJSON returns v as decoded from its JSON into interface{}.
+func genericJSON(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var generic interface{}
+	json.Unmarshal(data, &generic)
+	return generic
+}
+
+func diffValues(path string, old, new interface{}) []FieldChange {
+	oldObject, oldIsObject := old.(map[string]interface{})
+	newObject, newIsObject := new.(map[string]interface{})
+	if oldIsObject && newIsObject {
+		return diffObjects(path, oldObject, newObject)
+	}
+	oldList, oldIsList := old.([]interface{})
+	newList, newIsList := new.([]interface{})
+	if oldIsList && newIsList {
+		if key := listKey(oldList, newList); key != "" {
+			return diffKeyedLists(path, key, oldList, newList)
+		}
+	}
+	if reflect.DeepEqual(old, new) || (isEmptyJSON(old) && isEmptyJSON(new)) {
+		return nil
+	}
+	return []FieldChange{{Path: path, Old: old, New: new}}
+}
+
+// isEmptyJSON reports whether v is null or an empty list or object, which
+// the API uses interchangeably.
+func isEmptyJSON(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+func diffObjects(path string, old, new map[string]interface{}) []FieldChange {
+	names := []string{}
+	for name := range old {
+		names = append(names, name)
+	}
+	for name := range new {
+		if _, ok := old[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	changes := []FieldChange{}
+	for _, name := range names {
+		if diffIgnoredFields[name] {
+			continue
+		}
+		fieldPath := name
+		if path != "" {
+			fieldPath = path + "." + name
+		}
+		changes = append(changes, diffValues(fieldPath, old[name], new[name])...)
+	}
+	return changes
+}
+
+// listKeys identify the elements of lists of objects, in order of
+// preference.
+var listKeys = []string{"uuid", "id", "vnic_id", "name"}
+
+// listKey returns the field that identifies every element of both lists, or
+// "" if the lists hold anything else than objects with unique IDs.
+func listKey(lists ...[]interface{}) string {
+	for _, key := range listKeys {
+		unique := true
+		for _, list := range lists {
+			seen := map[string]bool{}
+			for _, element := range list {
+				object, ok := element.(map[string]interface{})
+				if !ok {
+					return ""
+				}
+				id := fmt.Sprint(object[key])
+				if object[key] == nil || id == "" || seen[id] {
+					unique = false
+					break
+				}
+				seen[id] = true
+			}
+		}
+		if unique {
+			return key
+		}
+	}
+	return ""
+}
+
+func diffKeyedLists(path, key string, old, new []interface{}) []FieldChange {
+	oldByID := map[string]interface{}{}
+	for _, element := range old {
+		oldByID[fmt.Sprint(element.(map[string]interface{})[key])] = element
+	}
+	newByID := map[string]interface{}{}
+	for _, element := range new {
+		newByID[fmt.Sprint(element.(map[string]interface{})[key])] = element
+	}
+	changes := []FieldChange{}
+	for _, element := range old {
+		id := fmt.Sprint(element.(map[string]interface{})[key])
+		elementPath := fmt.Sprintf("%s[%s=%s]", path, key, id)
+		after, ok := newByID[id]
+		if !ok {
+			changes = append(changes, FieldChange{Path: elementPath, Old: element})
+			continue
+		}
+		changes = append(changes, diffValues(elementPath, element, after)...)
+	}
+	for _, element := range new {
+		id := fmt.Sprint(element.(map[string]interface{})[key])
+		if _, ok := oldByID[id]; !ok {
+			changes = append(changes, FieldChange{Path: fmt.Sprintf("%s[%s=%s]", path, key, id), New: element})
+		}
+	}
+	return changes
+}
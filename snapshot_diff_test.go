@@ -0,0 +1,137 @@
+package iland
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestListKey(t *testing.T) {
+	object := func(fields ...interface{}) map[string]interface{} {
+		m := map[string]interface{}{}
+		for i := 0; i < len(fields); i += 2 {
+			m[fields[i].(string)] = fields[i+1]
+		}
+		return m
+	}
+	tests := []struct {
+		name  string
+		lists [][]interface{}
+		want  string
+	}{
+		{"uuid", [][]interface{}{{object("uuid", "a", "name", "x")}, {object("uuid", "b", "name", "x")}}, "uuid"},
+		{"id when uuid missing", [][]interface{}{{object("id", 1.0), object("id", 2.0)}}, "id"},
+		{"vnic id zero", [][]interface{}{{object("vnic_id", 0.0), object("vnic_id", 1.0)}}, "vnic_id"},
+		{"name when ids repeat", [][]interface{}{{object("id", 1.0, "name", "a"), object("id", 1.0, "name", "b")}}, "name"},
+		{"name when id empty", [][]interface{}{{object("id", "", "name", "a")}}, "name"},
+		{"no unique key", [][]interface{}{{object("size", 1.0), object("size", 1.0)}}, ""},
+		{"not objects", [][]interface{}{{"a", "b"}}, ""},
+		{"empty lists", [][]interface{}{{}, {}}, "uuid"},
+	}
+	for _, test := range tests {
+		if got := listKey(test.lists...); got != test.want {
+			t.Errorf("%s: listKey = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestDiffValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new interface{}
+		want     []FieldChange
+	}{
+		{"equal scalars", 1.0, 1.0, nil},
+		{"changed scalar", "a", "b", []FieldChange{{Path: "f", Old: "a", New: "b"}}},
+		{"null and empty list", nil, []interface{}{}, nil},
+		{"empty object and null", map[string]interface{}{}, nil, nil},
+		{"type change", "1", 1.0, []FieldChange{{Path: "f", Old: "1", New: 1.0}}},
+		{
+			"nested object",
+			map[string]interface{}{"a": map[string]interface{}{"b": 1.0, "c": true}},
+			map[string]interface{}{"a": map[string]interface{}{"b": 2.0, "c": true}},
+			[]FieldChange{{Path: "f.a.b", Old: 1.0, New: 2.0}},
+		},
+		{
+			"added and removed fields",
+			map[string]interface{}{"a": 1.0},
+			map[string]interface{}{"b": 2.0},
+			[]FieldChange{{Path: "f.a", Old: 1.0}, {Path: "f.b", New: 2.0}},
+		},
+		{
+			"ignored field",
+			map[string]interface{}{"updated_date": 1.0},
+			map[string]interface{}{"updated_date": 2.0},
+			[]FieldChange{},
+		},
+		{
+			"keyed list",
+			[]interface{}{
+				map[string]interface{}{"id": 1.0, "port": 80.0},
+				map[string]interface{}{"id": 2.0, "port": 22.0},
+			},
+			[]interface{}{
+				map[string]interface{}{"id": 3.0, "port": 443.0},
+				map[string]interface{}{"id": 1.0, "port": 8080.0},
+			},
+			[]FieldChange{
+				{Path: "f[id=1].port", Old: 80.0, New: 8080.0},
+				{Path: "f[id=2]", Old: map[string]interface{}{"id": 2.0, "port": 22.0}},
+				{Path: "f[id=3]", New: map[string]interface{}{"id": 3.0, "port": 443.0}},
+			},
+		},
+		{
+			"keyed list reordered",
+			[]interface{}{map[string]interface{}{"name": "a"}, map[string]interface{}{"name": "b"}},
+			[]interface{}{map[string]interface{}{"name": "b"}, map[string]interface{}{"name": "a"}},
+			[]FieldChange{},
+		},
+		{
+			"unkeyed list",
+			[]interface{}{"a", "b"},
+			[]interface{}{"b", "a"},
+			[]FieldChange{{Path: "f", Old: []interface{}{"a", "b"}, New: []interface{}{"b", "a"}}},
+		},
+	}
+	for _, test := range tests {
+		got := diffValues("f", test.old, test.new)
+		if len(got) == 0 && len(test.want) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: diffValues = %#v, want %#v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestDiffInventory(t *testing.T) {
+	snapshot := func(vms ...VirtualMachineSnapshot) InventorySnapshot {
+		vApp := VAppSnapshot{VApp: VApp{UUID: "vapp", Name: "app"}, VirtualMachines: vms}
+		vdc := VdcSnapshot{Vdc: Vdc{UUID: "vdc", Name: "vdc"}, VApps: []VAppSnapshot{vApp}}
+		org := OrgSnapshot{Org: Org{UUID: "org", Name: "org"}, Vdcs: []VdcSnapshot{vdc}}
+		return InventorySnapshot{Locations: []LocationSnapshot{{Location: Location{ID: "loc"}, Orgs: []OrgSnapshot{org}}}}
+	}
+	web := VirtualMachineSnapshot{VirtualMachine: VirtualMachine{UUID: "vm1", Name: "web", MemoryMB: 1024, UpdatedDate: 1}}
+	db := VirtualMachineSnapshot{VirtualMachine: VirtualMachine{UUID: "vm2", Name: "db"}}
+	resized := web
+	resized.MemoryMB = 2048
+	resized.UpdatedDate = 2
+	cache := VirtualMachineSnapshot{VirtualMachine: VirtualMachine{UUID: "vm3", Name: "cache"}}
+
+	if diff := DiffInventory(snapshot(web, db), snapshot(web, db)); !diff.Empty() {
+		t.Errorf("identical snapshots differ:\n%s", diff)
+	}
+	diff := DiffInventory(snapshot(web, db), snapshot(resized, cache))
+	got := []string{}
+	for _, change := range diff.Changes {
+		got = append(got, change.Type+" "+change.Kind+" "+change.Name)
+	}
+	want := []string{"added vm cache", "removed vm db", "changed vm web"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("changes = %q, want %q", got, want)
+	}
+	for _, change := range diff.Changes {
+		if change.Type == ChangeChanged && !reflect.DeepEqual(change.Fields, []FieldChange{{Path: "memory_size", Old: 1024.0, New: 2048.0}}) {
+			t.Errorf("fields = %#v, want only memory_size", change.Fields)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package iland
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ambiguousStrings read as other types, or as YAML syntax, unless quoted.
+var ambiguousStrings = []string{
+	"123", "-1.5e3", "0x1F", "true", "no", "yes", "on", "null", "~", "",
+	"2026-10-18T00:00:00Z", "2026-10-18", "a: b", "- a", "#a", "a\nb", " a ",
+	"[a]", "{a: b}", "'a'", `"a"`, "&a", "*a", "!a", "%a", "@a", "`a",
+}
+
+func testInventorySnapshot() InventorySnapshot {
+	vms := []VirtualMachineSnapshot{}
+	for i, name := range ambiguousStrings {
+		vms = append(vms, VirtualMachineSnapshot{
+			VirtualMachine: VirtualMachine{
+				Name:                name,
+				UUID:                fmt.Sprintf("vm-%d", i),
+				Description:         name,
+				VCPU:                2,
+				MemoryMB:            4096,
+				StorageProfileUUIDs: []string{name},
+				Deployed:            i%2 == 0,
+			},
+			Disks: []Disk{{Name: "Hard disk 1", Size: 20480, Type: name}},
+			Nics: []Nic{
+				{Index: 0, IPAddress: "10.1.1.10", IPAllocationMode: "POOL", Primary: true, Connected: true, NetworkName: name},
+				{Index: 1, MacAddress: "00:50:56:01:02:03", NetworkName: "db"},
+			},
+			Tools: VMwareTools{Status: "toolsOk", Version: "12352"},
+		})
+	}
+	vms = append(vms, VirtualMachineSnapshot{VirtualMachine: VirtualMachine{Name: "without details"}})
+	return InventorySnapshot{
+		Version: inventorySnapshotVersion,
+		TakenAt: time.Date(2026, 10, 18, 2, 0, 0, 0, time.UTC),
+		Locations: []LocationSnapshot{{
+			Location: Location{ID: "dal02.ilandcloud.com", UpdatedDate: 1760745600000},
+			Orgs: []OrgSnapshot{
+				{
+					Org: Org{Name: "123", UUID: "org", Enabled: true},
+					Vdcs: []VdcSnapshot{{
+						Vdc:             Vdc{Name: "true", UUID: "vdc", AllocatedMemory: 65536},
+						StorageProfiles: []StorageProfile{},
+						VApps: []VAppSnapshot{{
+							VApp:            VApp{Name: "2026-10-18T00:00:00Z", UUID: "app", StorageProfileUUIDs: []string{}},
+							Networks:        []VAppNetwork{{Name: "web", Gateway: "10.1.1.254", Netmask: "255.255.255.0", IPRanges: []IPRange{{Start: "10.1.1.10", End: "10.1.1.20"}}}},
+							VirtualMachines: vms,
+						}},
+					}},
+					Catalogs:    []CatalogSnapshot{},
+					Edges:       nil,
+					VdcNetworks: []VdcNetwork{},
+				},
+				{Org: Org{Name: "empty", UUID: "empty"}},
+			},
+		}},
+	}
+}
+
+func TestInventorySnapshotRoundTrip(t *testing.T) {
+	snapshot := testInventorySnapshot()
+	writers := map[string]func(InventorySnapshot, *bytes.Buffer) error{
+		"json": func(s InventorySnapshot, w *bytes.Buffer) error { return s.WriteJSON(w) },
+		"yaml": func(s InventorySnapshot, w *bytes.Buffer) error { return s.WriteYAML(w) },
+	}
+	for format, write := range writers {
+		buffer := bytes.Buffer{}
+		if err := write(snapshot, &buffer); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		written := buffer.String()
+		read, err := ReadInventorySnapshot(&buffer)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if !reflect.DeepEqual(read, snapshot) {
+			t.Errorf("%s: read %+v\nwant %+v\nfrom %s", format, read, snapshot, written)
+		}
+		org := read.Locations[0].Orgs[0]
+		if org.Catalogs == nil || org.Edges != nil {
+			t.Errorf("%s: catalogs %#v and edges %#v, want an empty list and nil", format, org.Catalogs, org.Edges)
+		}
+	}
+}
+
+func TestInventorySnapshotYAML(t *testing.T) {
+	buffer := bytes.Buffer{}
+	if err := testInventorySnapshot().WriteYAML(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	yaml := buffer.String()
+	for _, want := range []string{
+		"version: 1\n",
+		"taken_at: \"2026-10-18T02:00:00Z\"\n",
+		"name: \"123\"\n",
+		"name: \"true\"\n",
+		"edges: null\n",
+		"catalogs: []\n",
+		"memory_size: 4096\n",
+	} {
+		if !strings.Contains(yaml, want) {
+			t.Errorf("YAML has no %q", want)
+		}
+	}
+	if strings.Contains(yaml, "{\"") {
+		t.Error("YAML is written in flow style")
+	}
+}
+
+func TestReadInventorySnapshot(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{"json", `{"version":1,"locations":[{"location_id":"l"}]}`, ""},
+		{"json with space", "\n  {\"version\":1}", ""},
+		{"yaml", "version: 1\nlocations:\n  - location_id: l\n", ""},
+		{"older version", `{"version":0}`, ""},
+		{"newer json version", `{"version":2}`, "inventory snapshot version 2 is not supported"},
+		{"newer yaml version", "version: 2\n", "inventory snapshot version 2 is not supported"},
+		{"invalid json", `{"version":`, "unexpected end of JSON input"},
+		{"invalid yaml", "version: [1\n", "yaml"},
+		{"wrong type", "version: one\n", "cannot unmarshal"},
+	}
+	for _, test := range tests {
+		_, err := ReadInventorySnapshot(strings.NewReader(test.input))
+		if test.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: err = %v", test.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("%s: err = %v, want one containing %q", test.name, err, test.wantErr)
+		}
+	}
+}
+
+func TestSnapshotInventoryPartialFailure(t *testing.T) {
+	responses := map[string]string{
+		"/location/l/orgs":        `[{"uuid":"o1","name":"first"},{"uuid":"o2","name":"second"}]`,
+		"/org/o1/vdcs":            `[{"uuid":"d","name":"vdc"}]`,
+		"/org/o1/edges":           `[{"uuid":"e","name":"edge"}]`,
+		"/edge/e/nat":             `{"enabled":true,"rules":[{"id":1,"type":"DNAT"}]}`,
+		"/vdc/d/vapps":            `[{"uuid":"a","name":"app"}]`,
+		"/vapp/a/vms":             `[{"uuid":"v1","name":"vm1"},{"uuid":"v2","name":"vm2"}]`,
+		"/vm/v1/virtual-disks":    `[{"name":"Hard disk 1","size":20480}]`,
+		"/vm/v2/virtual-disks":    `[{"name":"Hard disk 1","size":40960}]`,
+		"/vm/v1/tools":            `{"status":"toolsOk"}`,
+		"/catalog/c/medias":       `[]`,
+		"/vdc/d/storage-profiles": `[]`,
+	}
+	failing := map[string]bool{
+		"/org/o2/vdcs": true,
+		"/vm/v2/tools": true,
+	}
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/inventory"):
+			fmt.Fprint(w, `[{"location_id":"l"}]`)
+		case failing[r.URL.Path]:
+			w.WriteHeader(http.StatusInternalServerError)
+		case responses[r.URL.Path] != "":
+			fmt.Fprint(w, responses[r.URL.Path])
+		case strings.HasSuffix(r.URL.Path, "s"):
+			fmt.Fprint(w, `[]`)
+		default:
+			fmt.Fprint(w, `{}`)
+		}
+	}), WithRetryPolicy(RetryPolicy{}))
+	snapshot, err := client.SnapshotInventoryContext(context.Background())
+
+	var listErr *ListError
+	if !errors.As(err, &listErr) {
+		t.Fatalf("err = %v, want a ListError", err)
+	}
+	failures := []string{}
+	for _, failure := range listErr.Failures {
+		failures = append(failures, failure.Scope+" "+failure.ID)
+	}
+	sort.Strings(failures)
+	if want := []string{"org o2", "vm v2"}; !reflect.DeepEqual(failures, want) {
+		t.Errorf("failures = %v, want %v", failures, want)
+	}
+	orgs := snapshot.Locations[0].Orgs
+	if len(orgs) != 2 || len(orgs[0].Vdcs) != 1 || len(orgs[1].Vdcs) != 0 {
+		t.Fatalf("orgs = %+v, want the vdc of the first org only", orgs)
+	}
+	if nat := orgs[0].Edges[0].NAT; !nat.Enabled || len(nat.Rules) != 1 {
+		t.Errorf("edge NAT = %+v", nat)
+	}
+	vms := orgs[0].Vdcs[0].VApps[0].VirtualMachines
+	if len(vms) != 2 || vms[0].Tools.Status != "toolsOk" || vms[1].Tools.Status != "" {
+		t.Fatalf("vms = %+v, want the tools of the first VM only", vms)
+	}
+	if len(vms[1].Disks) != 1 || vms[1].Disks[0].Size != 40960 {
+		t.Errorf("disks of vm2 = %+v, want them kept despite the tools failure", vms[1].Disks)
+	}
+	if snapshot.Version != inventorySnapshotVersion || snapshot.TakenAt.IsZero() {
+		t.Errorf("version %d taken at %v", snapshot.Version, snapshot.TakenAt)
+	}
+}
@@ -1,6 +1,7 @@
 package iland
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -38,27 +39,45 @@ type TicketComment struct {
 	CreationDate    int    `json:"creation_date"`
 }
 
-func (t *SupportTicket) GetAttachments() []TicketAttachment {
+func (t *SupportTicket) GetAttachments() ([]TicketAttachment, error) {
+	return t.GetAttachmentsContext(context.Background())
+}
+
+func (t *SupportTicket) GetAttachmentsContext(ctx context.Context) ([]TicketAttachment, error) {
 	attachments := []TicketAttachment{}
-	data, _ := t.client.Get(fmt.Sprintf("/companies/%s/support-tickets/%d/attachments", t.CRM, t.ID))
-	err := json.Unmarshal(data, &attachments)
+	data, err := t.client.GetContext(ctx, fmt.Sprintf("/companies/%s/support-tickets/%d/attachments", t.CRM, t.ID))
 	if err != nil {
-		fmt.Println(err)
+		return attachments, err
 	}
-	return attachments
+	err = json.Unmarshal(data, &attachments)
+	return attachments, err
 }
 
 func (t *SupportTicket) DownloadAttachment(attachmentID int) (io.ReadCloser, error) {
-	reader, err := t.client.getBinaryStream(fmt.Sprintf("/companies/%s/support-tickets/%d/attachments/%d", t.CRM, t.ID, attachmentID))
+	return t.DownloadAttachmentContext(context.Background(), attachmentID)
+}
+
+func (t *SupportTicket) DownloadAttachmentContext(ctx context.Context, attachmentID int) (io.ReadCloser, error) {
+	reader, err := t.client.getBinaryStream(ctx, fmt.Sprintf("/companies/%s/support-tickets/%d/attachments/%d", t.CRM, t.ID, attachmentID))
 	if err != nil {
 		return nil, err
 	}
 	return reader, nil
 }
 
-func (t *SupportTicket) GetComments() []TicketComment {
+func (t *SupportTicket) GetComments() ([]TicketComment, error) {
+	return t.GetCommentsContext(context.Background())
+}
+
+func (t *SupportTicket) GetCommentsContext(ctx context.Context) ([]TicketComment, error) {
 	comments := []TicketComment{}
-	data, _ := t.client.Get(fmt.Sprintf("/companies/%s/support-tickets/%d/comments", t.CRM, t.ID))
-	json.Unmarshal(data, &comments)
-	return comments
+	data, err := t.client.GetContext(ctx, fmt.Sprintf("/companies/%s/support-tickets/%d/comments", t.CRM, t.ID))
+	if err != nil {
+		return comments, err
+	}
+	err = json.Unmarshal(data, &comments)
+	if err != nil {
+		return comments, err
+	}
+	return comments, nil
 }
@@ -1,8 +1,12 @@
 package iland
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/url"
+	"strconv"
 	"time"
 )
 
@@ -27,23 +31,251 @@ type Task struct {
 	EndTime        int    `json:"end_time"`
 }
 
+// Refresh returns the latest state of the task, or the task unchanged if it
+// can't be fetched.
+//
+// Deprecated: use RefreshContext, which reports the error.
 func (t Task) Refresh() Task {
+	task, err := t.RefreshContext(context.Background())
+	if err != nil {
+		return t
+	}
+	return task
+}
+
+func (t Task) RefreshContext(ctx context.Context) (Task, error) {
 	task := Task{}
-	data, _ := t.client.Get(fmt.Sprintf("/task/%s/%s", t.LocationID, t.UUID))
-	json.Unmarshal(data, &task)
+	data, err := t.client.GetContext(ctx, fmt.Sprintf("/task/%s/%s", t.LocationID, t.UUID))
+	if err != nil {
+		return task, err
+	}
+	err = json.Unmarshal(data, &task)
+	if err != nil {
+		return task, err
+	}
 	task.client = t.client
-	return task
+	return task, nil
+}
+
+// Cancel asks the API to cancel the task if it is queued or running. The
+// task may still complete if it was about to, see Wait.
+func (t Task) Cancel() error {
+	return t.CancelContext(context.Background())
+}
+
+func (t Task) CancelContext(ctx context.Context) error {
+	_, err := t.client.PostContext(ctx, fmt.Sprintf("/task/%s/%s/cancel", t.LocationID, t.UUID), nil)
+	return err
+}
+
+// TaskHistoryFilter selects the tasks returned by the task history queries.
+// Zero fields match every task.
+type TaskHistoryFilter struct {
+	Operation string
+	Status    string
+	Username  string
+	// Since and Until bound the initiation time of the tasks.
+	Since time.Time
+	Until time.Time
+}
+
+// Matches reports whether task passes the filter.
+func (f TaskHistoryFilter) Matches(task Task) bool {
+	if f.Operation != "" && task.Operation != f.Operation {
+		return false
+	}
+	if f.Status != "" && task.Status != f.Status {
+		return false
+	}
+	if f.Username != "" && task.Username != f.Username {
+		return false
+	}
+	if !f.Since.IsZero() && task.InitiationTime < getUnixMilliseconds(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && task.InitiationTime > getUnixMilliseconds(f.Until) {
+		return false
+	}
+	return true
 }
 
+// query returns the query string that narrows the history down on the API
+// side, the filter is applied again to the tasks returned.
+func (f TaskHistoryFilter) query() string {
+	query := url.Values{}
+	if !f.Since.IsZero() {
+		query.Set("timestamp", strconv.Itoa(getUnixMilliseconds(f.Since)))
+	}
+	if len(query) == 0 {
+		return ""
+	}
+	return "?" + query.Encode()
+}
+
+func filterTasks(client *Client, tasks []Task, filter TaskHistoryFilter) []Task {
+	filtered := []Task{}
+	for _, task := range tasks {
+		if filter.Matches(task) {
+			task.client = client
+			filtered = append(filtered, task)
+		}
+	}
+	return filtered
+}
+
+const trackInterval = time.Second * 10
+
+// Track polls the task every 10 seconds until it is no longer active and
+// returns its final state. Polls that fail are retried, so Track blocks for
+// as long as the API can't be reached.
+//
+// Deprecated: use Wait, which reports errors and failed tasks.
 func (t Task) Track() Task {
+	task := t
+	for {
+		latest, err := task.TrackContext(context.Background())
+		if err == nil {
+			return latest
+		}
+		task = latest
+		time.Sleep(trackInterval)
+	}
+}
+
+// TrackContext polls the task every 10 seconds until it is no longer active
+// or ctx is done. Unlike Wait it does not report failed tasks as errors.
+func (t Task) TrackContext(ctx context.Context) (Task, error) {
+	task, err := t.Wait(ctx, WaitOptions{PollInterval: trackInterval, BackoffFactor: 1})
+	var failed *TaskFailedError
+	if errors.As(err, &failed) {
+		return task, nil
+	}
+	return task, err
+}
+
+// WaitOptions configure Task.Wait. The zero value is ready to use.
+type WaitOptions struct {
+	// PollInterval is the delay between the first two polls, 2 seconds by
+	// default. It is multiplied by BackoffFactor after every poll, up to
+	// MaxPollInterval.
+	PollInterval time.Duration
+	// MaxPollInterval is 30 seconds by default.
+	MaxPollInterval time.Duration
+	// BackoffFactor is 1.5 by default. Values below 1 are treated as 1, a
+	// constant interval.
+	BackoffFactor float64
+	// Timeout, if positive, bounds the wait in addition to ctx.
+	Timeout time.Duration
+	// OnProgress is called with the task whenever its Status, Progress or
+	// Message changes, from the goroutine calling Wait.
+	OnProgress func(task Task)
+	// MaxPollErrors is the number of polls in a row that may fail, once the
+	// client's RetryPolicy has run out, before Wait returns the error of the
+	// last one, 3 by default. Failed polls are retried at the poll interval.
+	// A negative value retries them until ctx is done or Timeout has elapsed.
+	MaxPollErrors int
+}
+
+const (
+	defaultPollInterval    = time.Second * 2
+	defaultMaxPollInterval = time.Second * 30
+	defaultBackoffFactor   = 1.5
+	defaultMaxPollErrors   = 3
+)
+
+func (o WaitOptions) withDefaults() WaitOptions {
+	if o.PollInterval <= 0 {
+		o.PollInterval = defaultPollInterval
+	}
+	if o.MaxPollInterval <= 0 {
+		o.MaxPollInterval = defaultMaxPollInterval
+	}
+	if o.MaxPollInterval < o.PollInterval {
+		o.MaxPollInterval = o.PollInterval
+	}
+	if o.BackoffFactor == 0 {
+		o.BackoffFactor = defaultBackoffFactor
+	}
+	if o.BackoffFactor < 1 {
+		o.BackoffFactor = 1
+	}
+	if o.MaxPollErrors == 0 {
+		o.MaxPollErrors = defaultMaxPollErrors
+	}
+	return o
+}
+
+// next returns the poll interval that follows interval.
+func (o WaitOptions) next(interval time.Duration) time.Duration {
+	interval = time.Duration(float64(interval) * o.BackoffFactor)
+	if interval > o.MaxPollInterval {
+		interval = o.MaxPollInterval
+	}
+	return interval
+}
+
+// tooManyPollErrors reports whether Wait gives up after n failed polls in
+// a row.
+func (o WaitOptions) tooManyPollErrors(n int) bool {
+	return o.MaxPollErrors > 0 && n >= o.MaxPollErrors
+}
+
+// Wait polls the task until it completes and returns its final state. The
+// error is a *TaskFailedError if the task completed with another status than
+// TaskStatusSuccess, the error of the last poll if opts.MaxPollErrors polls
+// failed in a row, or ctx.Err() if ctx is done or opts.Timeout has elapsed
+// first. The last observed task is returned in every case.
+func (t Task) Wait(ctx context.Context, opts WaitOptions) (Task, error) {
+	opts = opts.withDefaults()
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	ctx, end := t.client.startPolling(ctx, Poll{Kind: PollTask, LocationID: t.LocationID, UUID: t.UUID})
+	task, err := t.wait(ctx, opts)
+	end(err)
+	return task, err
+}
+
+func (t Task) wait(ctx context.Context, opts WaitOptions) (Task, error) {
+	task := t
+	interval := opts.PollInterval
+	pollErrors := 0
 	for {
-		task := Task{}
-		data, _ := t.client.Get(fmt.Sprintf("/task/%s/%s", t.LocationID, t.UUID))
-		json.Unmarshal(data, &task)
-		if !task.Active && task.Synchronized {
-			task.client = t.client
-			return task
+		latest, err := task.RefreshContext(ctx)
+		if err != nil {
+			pollErrors++
+			if opts.tooManyPollErrors(pollErrors) {
+				return task, err
+			}
+		} else {
+			pollErrors = 0
+			if latest.UUID == "" {
+				latest = task
+			}
+			if opts.OnProgress != nil && (latest.Status != task.Status || latest.Progress != task.Progress || latest.Message != task.Message) {
+				opts.OnProgress(latest)
+			}
+			task = latest
+			if task.done() {
+				t.client.entityChanged(task.EntityUUID)
+				if task.Status != TaskStatusSuccess {
+					return task, &TaskFailedError{Task: task}
+				}
+				return task, nil
+			}
 		}
-		time.Sleep(time.Second * 10)
+		select {
+		case <-ctx.Done():
+			return task, ctx.Err()
+		case <-time.After(interval):
+		}
+		interval = opts.next(interval)
 	}
 }
+
+// done reports whether the task has completed and its final state is known.
+func (t Task) done() bool {
+	return !t.Active && t.Synchronized
+}
@@ -0,0 +1,291 @@
+package iland
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// TaskGroup waits for a set of tasks at once. Tasks of the same org are
+// polled together with one request for the org's active tasks, and only
+// the tasks that are no longer active are fetched one by one. Tasks may come
+// from different clients, whose StartPolling hooks are called once per Wait.
+// The requests for the tasks of a client use the context returned by its own
+// hooks, and at most its fan-out concurrency of tasks are fetched at once.
+type TaskGroup struct {
+	// OnProgress, if set, is called with the aggregate progress of the group
+	// whenever the state of one of its tasks changes.
+	OnProgress func(progress TaskGroupProgress)
+	tasks      []Task
+}
+
+// TaskGroupProgress is the aggregate progress of a TaskGroup.
+type TaskGroupProgress struct {
+	Total  int
+	Done   int
+	Failed int
+	// Progress is the mean progress of the tasks, from 0 to 100, where
+	// completed tasks count as 100.
+	Progress int
+}
+
+// TaskResult is the final state of a task of a TaskGroup. Err is set as
+// Task.Wait would set it.
+type TaskResult struct {
+	Task Task
+	Err  error
+}
+
+func NewTaskGroup(tasks ...Task) *TaskGroup {
+	g := &TaskGroup{}
+	g.Add(tasks...)
+	return g
+}
+
+func (g *TaskGroup) Add(tasks ...Task) {
+	g.tasks = append(g.tasks, tasks...)
+}
+
+func (g *TaskGroup) Len() int {
+	return len(g.tasks)
+}
+
+// Wait polls the tasks until all of them complete, ctx is done or
+// opts.Timeout has elapsed, and returns their results in the order the
+// tasks were added. opts.OnProgress is called for every task that changes.
+// A task that can't be polled opts.MaxPollErrors times in a row fails with
+// the error of the last poll, the others are still waited for. The error is
+// a *TaskGroupError if any task failed or could not be polled.
+func (g *TaskGroup) Wait(ctx context.Context, opts WaitOptions) ([]TaskResult, error) {
+	results := make([]TaskResult, len(g.tasks))
+	for i, task := range g.tasks {
+		results[i].Task = task
+	}
+	if len(g.tasks) == 0 {
+		return results, nil
+	}
+	opts = opts.withDefaults()
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	clients := g.clients()
+	pollCtxs := make(map[*Client]context.Context, len(clients))
+	ends := make(map[*Client]func(error), len(clients))
+	for _, client := range clients {
+		pollCtxs[client], ends[client] = client.startPolling(ctx, Poll{Kind: PollTaskGroup})
+	}
+	err := g.wait(ctx, pollCtxs, opts, results)
+	for i := len(clients) - 1; i >= 0; i-- {
+		clientResults := []TaskResult{}
+		for _, result := range results {
+			if result.Task.client == clients[i] {
+				clientResults = append(clientResults, result)
+			}
+		}
+		ends[clients[i]](groupErr(clientResults))
+	}
+	return results, err
+}
+
+// clients returns the clients of the tasks, in the order they were added.
+func (g *TaskGroup) clients() []*Client {
+	clients := []*Client{}
+	seen := map[*Client]bool{}
+	for _, task := range g.tasks {
+		if !seen[task.client] {
+			seen[task.client] = true
+			clients = append(clients, task.client)
+		}
+	}
+	return clients
+}
+
+func (g *TaskGroup) wait(ctx context.Context, pollCtxs map[*Client]context.Context, opts WaitOptions, results []TaskResult) error {
+	pending := make([]int, len(results))
+	for i := range results {
+		pending[i] = i
+	}
+	pollErrors := make([]int, len(results))
+	interval := opts.PollInterval
+	for {
+		finished := g.poll(ctx, pollCtxs, opts, results, pending, pollErrors)
+		remaining := pending[:0]
+		for _, i := range pending {
+			if !finished[i] {
+				remaining = append(remaining, i)
+			}
+		}
+		pending = remaining
+		if len(pending) == 0 {
+			return groupErr(results)
+		}
+		select {
+		case <-ctx.Done():
+			for _, i := range pending {
+				results[i].Err = ctx.Err()
+			}
+			return groupErr(results)
+		case <-time.After(interval):
+		}
+		interval = opts.next(interval)
+	}
+}
+
+// poll updates the results of the pending tasks and returns the ones that
+// have finished. pollErrors counts the failed polls in a row of every task.
+func (g *TaskGroup) poll(ctx context.Context, pollCtxs map[*Client]context.Context, opts WaitOptions, results []TaskResult, pending []int, pollErrors []int) map[int]bool {
+	type orgKey struct {
+		client     *Client
+		locationID string
+		orgUUID    string
+	}
+	byOrg := map[orgKey][]int{}
+	refresh := []int{}
+	for _, i := range pending {
+		task := results[i].Task
+		if task.OrgUUID == "" {
+			refresh = append(refresh, i)
+			continue
+		}
+		key := orgKey{task.client, task.LocationID, task.OrgUUID}
+		byOrg[key] = append(byOrg[key], i)
+	}
+
+	latest := make(map[int]Task, len(pending))
+	for key, indexes := range byOrg {
+		org := Org{client: key.client, LocationID: key.locationID, UUID: key.orgUUID}
+		active, err := org.GetActiveTasksContext(pollCtxs[key.client])
+		if err != nil {
+			// fall back to fetching the tasks one by one.
+			refresh = append(refresh, indexes...)
+			continue
+		}
+		activeByUUID := make(map[string]Task, len(active))
+		for _, task := range active {
+			activeByUUID[task.UUID] = task
+		}
+		for _, i := range indexes {
+			if task, ok := activeByUUID[results[i].Task.UUID]; ok {
+				latest[i] = task
+			} else {
+				refresh = append(refresh, i)
+			}
+		}
+	}
+
+	errs := map[int]error{}
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	slots := map[*Client]chan struct{}{}
+	for _, i := range refresh {
+		client := results[i].Task.client
+		if slots[client] == nil {
+			slots[client] = make(chan struct{}, concurrency(client))
+		}
+	}
+	for _, i := range refresh {
+		client := results[i].Task.client
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			slots[client] <- struct{}{}
+			defer func() { <-slots[client] }()
+			task, err := results[i].Task.RefreshContext(pollCtxs[client])
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs[i] = err
+			} else {
+				latest[i] = task
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	finished := map[int]bool{}
+	changed := false
+	for _, i := range pending {
+		if err, ok := errs[i]; ok {
+			pollErrors[i]++
+			if ctx.Err() == nil && opts.tooManyPollErrors(pollErrors[i]) {
+				results[i].Err = fmt.Errorf("polling task %s: %w", results[i].Task.UUID, err)
+				finished[i] = true
+				changed = true
+			}
+			continue
+		}
+		pollErrors[i] = 0
+		task, ok := latest[i]
+		if !ok || task.UUID == "" {
+			continue
+		}
+		previous := results[i].Task
+		results[i].Task = task
+		if task.Status != previous.Status || task.Progress != previous.Progress || task.Message != previous.Message {
+			changed = true
+			if opts.OnProgress != nil {
+				opts.OnProgress(task)
+			}
+		}
+		if task.done() {
+			task.client.entityChanged(task.EntityUUID)
+			finished[i] = true
+			changed = true
+			if task.Status != TaskStatusSuccess {
+				results[i].Err = &TaskFailedError{Task: task}
+			}
+		}
+	}
+	if changed && g.OnProgress != nil {
+		g.OnProgress(g.progress(results, pending, finished))
+	}
+	return finished
+}
+
+func (g *TaskGroup) progress(results []TaskResult, pending []int, finished map[int]bool) TaskGroupProgress {
+	running := make(map[int]bool, len(pending))
+	for _, i := range pending {
+		running[i] = !finished[i]
+	}
+	progress := TaskGroupProgress{Total: len(results)}
+	sum := 0
+	for i, result := range results {
+		if running[i] {
+			sum += result.Task.Progress
+			continue
+		}
+		sum += 100
+		progress.Done++
+		if result.Err != nil {
+			progress.Failed++
+		}
+	}
+	progress.Progress = sum / len(results)
+	return progress
+}
+
+// concurrency is the number of tasks of a client refreshed at once.
+func concurrency(client *Client) int {
+	n := client.fanOutConcurrency
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// groupErr returns a *TaskGroupError with the failed results, or nil.
+func groupErr(results []TaskResult) error {
+	groupErr := TaskGroupError{Total: len(results)}
+	for _, result := range results {
+		if result.Err != nil {
+			groupErr.Failures = append(groupErr.Failures, result)
+		}
+	}
+	if len(groupErr.Failures) == 0 {
+		return nil
+	}
+	return &groupErr
+}
@@ -0,0 +1,316 @@
+package iland
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeTask is a task that stays running for a number of polls, then
+// completes with status.
+type fakeTask struct {
+	org    string
+	status string
+	// running is the number of polls, of the task or of its org's active
+	// tasks, that still see the task running.
+	running int
+	// failures is the number of times the task still can't be fetched.
+	failures int
+}
+
+// fakeTasks serves the task and active task endpoints of a location "l".
+type fakeTasks struct {
+	mu    sync.Mutex
+	tasks map[string]*fakeTask
+	// activeStatus, if set, is the status of every active tasks request.
+	activeStatus int
+	activePolls  int
+	refreshes    map[string]int
+}
+
+func (f *fakeTasks) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) == 5 && parts[2] == "org" && parts[4] == "active" {
+		if f.activeStatus != 0 {
+			w.WriteHeader(f.activeStatus)
+			return
+		}
+		f.activePolls++
+		active := []string{}
+		for uuid, task := range f.tasks {
+			if task.org == parts[3] && task.running > 0 {
+				task.running--
+				active = append(active, fmt.Sprintf(`{"uuid":%q,"location_id":"l","org_uuid":%q,"status":"running","progress":50,"active":true}`, uuid, task.org))
+			}
+		}
+		fmt.Fprintf(w, "[%s]", strings.Join(active, ","))
+		return
+	}
+	uuid := parts[2]
+	task := f.tasks[uuid]
+	if f.refreshes == nil {
+		f.refreshes = map[string]int{}
+	}
+	f.refreshes[uuid]++
+	switch {
+	case task == nil:
+		w.WriteHeader(http.StatusNotFound)
+	case task.failures > 0:
+		task.failures--
+		w.WriteHeader(http.StatusInternalServerError)
+	case task.running > 0:
+		task.running--
+		fmt.Fprintf(w, `{"uuid":%q,"location_id":"l","org_uuid":%q,"status":"running","progress":50,"active":true}`, uuid, task.org)
+	default:
+		fmt.Fprintf(w, `{"uuid":%q,"location_id":"l","org_uuid":%q,"status":%q,"progress":100,"active":false,"synchronized":true}`, uuid, task.org, task.status)
+	}
+}
+
+func (f *fakeTasks) group(client *Client, uuids ...string) *TaskGroup {
+	g := NewTaskGroup()
+	for _, uuid := range uuids {
+		g.Add(Task{client: client, UUID: uuid, LocationID: "l", OrgUUID: f.tasks[uuid].org})
+	}
+	return g
+}
+
+func TestTaskGroupWait(t *testing.T) {
+	tests := []struct {
+		name         string
+		tasks        map[string]*fakeTask
+		activeStatus int
+		// wantErrs is "" for a task that succeeds, "failed" for one that
+		// completes with an error, and "poll" for one that can't be polled.
+		wantErrs        map[string]string
+		wantActivePolls int
+		wantRefreshes   map[string]int
+	}{
+		{
+			name: "org tasks polled together",
+			tasks: map[string]*fakeTask{
+				"a": {org: "o", running: 2, status: TaskStatusSuccess},
+				"b": {org: "o", running: 1, status: TaskStatusError},
+				"c": {running: 1, status: TaskStatusSuccess},
+			},
+			wantErrs:        map[string]string{"a": "", "b": "failed", "c": ""},
+			wantActivePolls: 3,
+			wantRefreshes:   map[string]int{"a": 1, "b": 1, "c": 2},
+		},
+		{
+			name: "active tasks unavailable",
+			tasks: map[string]*fakeTask{
+				"a": {org: "o", running: 2, status: TaskStatusSuccess},
+				"b": {org: "o", running: 1, status: TaskStatusCancelled},
+			},
+			activeStatus:  http.StatusForbidden,
+			wantErrs:      map[string]string{"a": "", "b": "failed"},
+			wantRefreshes: map[string]int{"a": 3, "b": 2},
+		},
+		{
+			name: "poll errors",
+			tasks: map[string]*fakeTask{
+				"a": {failures: 2, status: TaskStatusSuccess},
+				"b": {failures: 5, status: TaskStatusSuccess},
+				"c": {running: 4, status: TaskStatusSuccess},
+			},
+			wantErrs:      map[string]string{"a": "", "b": "poll", "c": ""},
+			wantRefreshes: map[string]int{"a": 3, "b": 3, "c": 5},
+		},
+	}
+	for _, test := range tests {
+		fake := &fakeTasks{tasks: test.tasks, activeStatus: test.activeStatus}
+		client := newTestClient(t, fake, WithRetryPolicy(RetryPolicy{}))
+		uuids := []string{}
+		for uuid := range test.tasks {
+			uuids = append(uuids, uuid)
+		}
+		results, err := fake.group(client, uuids...).Wait(context.Background(), WaitOptions{PollInterval: time.Millisecond})
+
+		failures := map[string]error{}
+		var groupErr *TaskGroupError
+		if errors.As(err, &groupErr) {
+			if groupErr.Total != len(uuids) {
+				t.Errorf("%s: Total = %d, want %d", test.name, groupErr.Total, len(uuids))
+			}
+			for _, failure := range groupErr.Failures {
+				failures[failure.Task.UUID] = failure.Err
+			}
+		} else if err != nil {
+			t.Errorf("%s: err = %v, want a TaskGroupError or nil", test.name, err)
+		}
+		for i, result := range results {
+			if result.Task.UUID != uuids[i] {
+				t.Errorf("%s: result %d is task %s, want %s", test.name, i, result.Task.UUID, uuids[i])
+			}
+			var failed *TaskFailedError
+			var apiErr *APIError
+			switch test.wantErrs[uuids[i]] {
+			case "":
+				if result.Err != nil || result.Task.Status != TaskStatusSuccess {
+					t.Errorf("%s: task %s = %s, %v, want success", test.name, uuids[i], result.Task.Status, result.Err)
+				}
+			case "failed":
+				if !errors.As(result.Err, &failed) || failed.Task.Status != test.tasks[uuids[i]].status {
+					t.Errorf("%s: task %s err = %v, want a TaskFailedError", test.name, uuids[i], result.Err)
+				}
+			case "poll":
+				if !errors.As(result.Err, &apiErr) {
+					t.Errorf("%s: task %s err = %v, want an APIError", test.name, uuids[i], result.Err)
+				}
+			}
+			if (result.Err != nil) != (failures[uuids[i]] != nil) {
+				t.Errorf("%s: task %s err = %v, TaskGroupError has %v", test.name, uuids[i], result.Err, failures[uuids[i]])
+			}
+		}
+		if fake.activePolls != test.wantActivePolls {
+			t.Errorf("%s: active task polls = %d, want %d", test.name, fake.activePolls, test.wantActivePolls)
+		}
+		if fmt.Sprint(fake.refreshes) != fmt.Sprint(test.wantRefreshes) {
+			t.Errorf("%s: refreshes = %v, want %v", test.name, fake.refreshes, test.wantRefreshes)
+		}
+	}
+}
+
+func TestTaskGroupProgress(t *testing.T) {
+	fake := &fakeTasks{tasks: map[string]*fakeTask{
+		"a": {running: 1, status: TaskStatusSuccess},
+		"b": {running: 2, status: TaskStatusError},
+	}}
+	client := newTestClient(t, fake)
+	g := fake.group(client, "a", "b")
+	progress := []TaskGroupProgress{}
+	g.OnProgress = func(p TaskGroupProgress) {
+		progress = append(progress, p)
+	}
+	g.Wait(context.Background(), WaitOptions{PollInterval: time.Millisecond})
+	want := []TaskGroupProgress{
+		{Total: 2, Progress: 50},
+		{Total: 2, Done: 1, Progress: 75},
+		{Total: 2, Done: 2, Failed: 1, Progress: 100},
+	}
+	if fmt.Sprint(progress) != fmt.Sprint(want) {
+		t.Errorf("progress = %v, want %v", progress, want)
+	}
+}
+
+func TestTaskGroupTimeout(t *testing.T) {
+	fake := &fakeTasks{tasks: map[string]*fakeTask{
+		"a": {status: TaskStatusSuccess},
+		"b": {running: 1000, status: TaskStatusSuccess},
+	}}
+	client := newTestClient(t, fake)
+	results, err := fake.group(client, "a", "b").Wait(context.Background(), WaitOptions{PollInterval: time.Millisecond, Timeout: 50 * time.Millisecond})
+	var groupErr *TaskGroupError
+	if !errors.As(err, &groupErr) || len(groupErr.Failures) != 1 {
+		t.Fatalf("err = %v, want a TaskGroupError with one failure", err)
+	}
+	if results[0].Err != nil || !errors.Is(results[1].Err, context.DeadlineExceeded) {
+		t.Errorf("errors = %v, %v, want nil, %v", results[0].Err, results[1].Err, context.DeadlineExceeded)
+	}
+}
+
+type pollingClientKey struct{}
+
+func TestTaskGroupClients(t *testing.T) {
+	mu := sync.Mutex{}
+	// requests records the client named by the polling context of every
+	// request sent by each client.
+	requests := map[string][]any{}
+	ends := map[string]error{}
+	hooks := func(name string) Hooks {
+		return Hooks{
+			StartPolling: func(ctx context.Context, poll Poll) (context.Context, func(error)) {
+				return context.WithValue(ctx, pollingClientKey{}, name), func(err error) {
+					mu.Lock()
+					defer mu.Unlock()
+					ends[name] = err
+				}
+			},
+			BeforeRequest: func(req *http.Request) *http.Request {
+				mu.Lock()
+				defer mu.Unlock()
+				requests[name] = append(requests[name], req.Context().Value(pollingClientKey{}))
+				return req
+			},
+		}
+	}
+	fakeA := &fakeTasks{tasks: map[string]*fakeTask{
+		"a1": {org: "o", running: 1, status: TaskStatusSuccess},
+		"a2": {running: 1, status: TaskStatusSuccess},
+	}}
+	fakeB := &fakeTasks{tasks: map[string]*fakeTask{
+		"b1": {org: "o", running: 1, status: TaskStatusError},
+	}}
+	clientA := newTestClient(t, fakeA, WithHooks(hooks("a")))
+	clientB := newTestClient(t, fakeB, WithHooks(hooks("b")))
+	g := fakeA.group(clientA, "a1", "a2")
+	g.Add(fakeB.group(clientB, "b1").tasks...)
+	results, err := g.Wait(context.Background(), WaitOptions{PollInterval: time.Millisecond})
+
+	var groupErr *TaskGroupError
+	if !errors.As(err, &groupErr) || groupErr.Total != 3 || len(groupErr.Failures) != 1 {
+		t.Fatalf("err = %v, want a TaskGroupError with one failure out of 3", err)
+	}
+	if results[0].Err != nil || results[1].Err != nil || results[2].Err == nil {
+		t.Errorf("errors = %v, %v, %v, want only the task of client b to fail", results[0].Err, results[1].Err, results[2].Err)
+	}
+	for name, values := range requests {
+		if len(values) == 0 {
+			t.Errorf("client %s sent no request", name)
+		}
+		for _, value := range values {
+			if value != name {
+				t.Errorf("request of client %s has the polling context of client %v", name, value)
+			}
+		}
+	}
+	if ends["a"] != nil {
+		t.Errorf("polling of client a ended with %v, want nil", ends["a"])
+	}
+	if !errors.As(ends["b"], &groupErr) || groupErr.Total != 1 || len(groupErr.Failures) != 1 {
+		t.Errorf("polling of client b ended with %v, want a TaskGroupError with one failure out of 1", ends["b"])
+	}
+}
+
+func TestTaskGroupClientsConcurrency(t *testing.T) {
+	// the tasks of client a can only be fetched once a task of client b is,
+	// which requires them to be fetched at the same time.
+	seenB := make(chan struct{})
+	closeSeenB := sync.OnceFunc(func() { close(seenB) })
+	fakeA := &fakeTasks{tasks: map[string]*fakeTask{
+		"a1": {status: TaskStatusSuccess},
+		"a2": {status: TaskStatusSuccess},
+	}}
+	fakeB := &fakeTasks{tasks: map[string]*fakeTask{
+		"b1": {status: TaskStatusSuccess},
+	}}
+	blocked := atomic.Bool{}
+	clientA := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-seenB:
+		case <-time.After(time.Second):
+			blocked.Store(true)
+		}
+		fakeA.ServeHTTP(w, r)
+	}), WithFanOutConcurrency(1))
+	clientB := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		closeSeenB()
+		fakeB.ServeHTTP(w, r)
+	}), WithFanOutConcurrency(1))
+	g := fakeA.group(clientA, "a1", "a2")
+	g.Add(fakeB.group(clientB, "b1").tasks...)
+	if _, err := g.Wait(context.Background(), WaitOptions{PollInterval: time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	if blocked.Load() {
+		t.Error("the tasks of client b were not fetched while client a was busy")
+	}
+}
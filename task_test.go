@@ -0,0 +1,223 @@
+package iland
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// taskSequence serves the given states of a task, one per poll, and keeps
+// serving the last one. An empty state is answered with an internal error.
+type taskSequence struct {
+	mu     sync.Mutex
+	states []string
+	polls  int
+}
+
+func (s *taskSequence) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := s.states[min(s.polls, len(s.states)-1)]
+	s.polls++
+	if state == "" {
+		http.Error(w, `{"error":"internal","message":"try again"}`, http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintf(w, `{"uuid":"task","location_id":"l",%s}`, state)
+}
+
+const (
+	taskQueued    = `"status":"queued","active":true`
+	taskRunning   = `"status":"running","progress":50,"active":true`
+	taskSucceeded = `"status":"success","progress":100,"active":false,"synchronized":true`
+	taskFailed    = `"status":"error","message":"disk full","active":false,"synchronized":true`
+	taskCancelled = `"status":"cancelled","active":false,"synchronized":true`
+	taskUnsynced  = `"status":"success","progress":100,"active":false,"synchronized":false`
+)
+
+func TestWaitOptionsNext(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     WaitOptions
+		interval time.Duration
+		want     time.Duration
+	}{
+		{"default backoff", WaitOptions{}, 2 * time.Second, 3 * time.Second},
+		{"capped", WaitOptions{}, 25 * time.Second, 30 * time.Second},
+		{"constant", WaitOptions{BackoffFactor: 1}, 10 * time.Second, 10 * time.Second},
+		{"factor below one", WaitOptions{BackoffFactor: 0.5}, 10 * time.Second, 10 * time.Second},
+		{"custom", WaitOptions{BackoffFactor: 2, MaxPollInterval: time.Minute}, 20 * time.Second, 40 * time.Second},
+		{"max below interval", WaitOptions{PollInterval: time.Minute, MaxPollInterval: time.Second}, time.Minute, time.Minute},
+	}
+	for _, test := range tests {
+		if got := test.opts.withDefaults().next(test.interval); got != test.want {
+			t.Errorf("%s: next(%v) = %v, want %v", test.name, test.interval, got, test.want)
+		}
+	}
+}
+
+func TestTaskWait(t *testing.T) {
+	tests := []struct {
+		name          string
+		states        []string
+		maxPollErrors int
+		wantStatus    string
+		wantFailed    bool
+		wantAPIError  bool
+		wantProgress  []string
+		wantPolls     int
+	}{
+		{
+			name:         "success",
+			states:       []string{taskQueued, taskRunning, taskRunning, taskSucceeded},
+			wantStatus:   TaskStatusSuccess,
+			wantProgress: []string{"running 50", "success 100"},
+			wantPolls:    4,
+		},
+		{
+			name:         "not synchronized yet",
+			states:       []string{taskUnsynced, taskSucceeded},
+			wantStatus:   TaskStatusSuccess,
+			wantProgress: []string{"success 100"},
+			wantPolls:    2,
+		},
+		{
+			name:         "error",
+			states:       []string{taskRunning, taskFailed},
+			wantStatus:   TaskStatusError,
+			wantFailed:   true,
+			wantProgress: []string{"running 50", "error 0"},
+			wantPolls:    2,
+		},
+		{
+			name:         "cancelled",
+			states:       []string{taskCancelled},
+			wantStatus:   TaskStatusCancelled,
+			wantFailed:   true,
+			wantProgress: []string{"cancelled 0"},
+			wantPolls:    1,
+		},
+		{
+			name:         "poll errors retried",
+			states:       []string{taskRunning, "", "", taskSucceeded},
+			wantStatus:   TaskStatusSuccess,
+			wantProgress: []string{"running 50", "success 100"},
+			wantPolls:    4,
+		},
+		{
+			name:         "too many poll errors",
+			states:       []string{taskRunning, "", "", ""},
+			wantStatus:   TaskStatusRunning,
+			wantAPIError: true,
+			wantProgress: []string{"running 50"},
+			wantPolls:    4,
+		},
+		{
+			name:          "first poll error",
+			states:        []string{""},
+			maxPollErrors: 1,
+			wantStatus:    TaskStatusQueued,
+			wantAPIError:  true,
+			wantPolls:     1,
+		},
+		{
+			name:          "poll errors retried without limit",
+			states:        []string{"", "", "", "", "", taskSucceeded},
+			maxPollErrors: -1,
+			wantStatus:    TaskStatusSuccess,
+			wantProgress:  []string{"success 100"},
+			wantPolls:     6,
+		},
+	}
+	for _, test := range tests {
+		sequence := &taskSequence{states: test.states}
+		client := newTestClient(t, sequence)
+		progress := []string{}
+		task := Task{client: client, UUID: "task", LocationID: "l", Status: TaskStatusQueued, Active: true}
+		task, err := task.Wait(context.Background(), WaitOptions{
+			PollInterval:  time.Millisecond,
+			MaxPollErrors: test.maxPollErrors,
+			OnProgress: func(task Task) {
+				progress = append(progress, fmt.Sprintf("%s %d", task.Status, task.Progress))
+			},
+		})
+		var failed *TaskFailedError
+		var apiErr *APIError
+		switch {
+		case test.wantFailed && !errors.As(err, &failed):
+			t.Errorf("%s: err = %v, want a TaskFailedError", test.name, err)
+		case test.wantFailed && failed.Task.Status != test.wantStatus:
+			t.Errorf("%s: failed task status = %q, want %q", test.name, failed.Task.Status, test.wantStatus)
+		case test.wantAPIError && !errors.As(err, &apiErr):
+			t.Errorf("%s: err = %v, want an APIError", test.name, err)
+		case !test.wantFailed && !test.wantAPIError && err != nil:
+			t.Errorf("%s: err = %v", test.name, err)
+		}
+		if task.Status != test.wantStatus {
+			t.Errorf("%s: status = %q, want %q", test.name, task.Status, test.wantStatus)
+		}
+		if fmt.Sprint(progress) != fmt.Sprint(test.wantProgress) {
+			t.Errorf("%s: progress = %v, want %v", test.name, progress, test.wantProgress)
+		}
+		if sequence.polls != test.wantPolls {
+			t.Errorf("%s: polls = %d, want %d", test.name, sequence.polls, test.wantPolls)
+		}
+	}
+}
+
+func TestTaskWaitBackoff(t *testing.T) {
+	mu := sync.Mutex{}
+	polls := []time.Time{}
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		polls = append(polls, time.Now())
+		if len(polls) < 4 {
+			fmt.Fprintf(w, `{"uuid":"task",%s}`, taskRunning)
+		} else {
+			fmt.Fprintf(w, `{"uuid":"task",%s}`, taskSucceeded)
+		}
+	}))
+	task := Task{client: client, UUID: "task", LocationID: "l"}
+	_, err := task.Wait(context.Background(), WaitOptions{PollInterval: 20 * time.Millisecond, BackoffFactor: 2, MaxPollInterval: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []time.Duration{20 * time.Millisecond, 40 * time.Millisecond, 50 * time.Millisecond}
+	for i, interval := range want {
+		if got := polls[i+1].Sub(polls[i]); got < interval {
+			t.Errorf("interval %d = %v, want at least %v", i, got, interval)
+		}
+	}
+}
+
+func TestTaskWaitTimeout(t *testing.T) {
+	client := newTestClient(t, &taskSequence{states: []string{taskRunning}})
+	task := Task{client: client, UUID: "task", LocationID: "l"}
+	start := time.Now()
+	task, err := task.Wait(context.Background(), WaitOptions{PollInterval: 10 * time.Millisecond, BackoffFactor: 1, Timeout: 50 * time.Millisecond})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Wait returned after %v, want about 50ms", elapsed)
+	}
+	if task.Status != TaskStatusRunning {
+		t.Errorf("status = %q, want the last observed %q", task.Status, TaskStatusRunning)
+	}
+}
+
+func TestTaskTrackContext(t *testing.T) {
+	client := newTestClient(t, &taskSequence{states: []string{taskFailed}})
+	task, err := Task{client: client, UUID: "task", LocationID: "l"}.TrackContext(context.Background())
+	if err != nil {
+		t.Errorf("err = %v, want a failed task to be returned without error", err)
+	}
+	if task.Status != TaskStatusError {
+		t.Errorf("status = %q, want %q", task.Status, TaskStatusError)
+	}
+}
@@ -0,0 +1,275 @@
+package iland
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// TaskWatchFilter selects the tasks reported by Client.WatchTasks.
+type TaskWatchFilter struct {
+	// LocationID is required.
+	LocationID string
+	// OrgUUID restricts the events to one org, otherwise the tasks of every
+	// org of the location are watched.
+	OrgUUID string
+	// EntityUUID, Operation and Username, if set, restrict the events to the
+	// matching tasks.
+	EntityUUID string
+	Operation  string
+	Username   string
+	// PollInterval is the delay between two polls, 5 seconds by default.
+	PollInterval time.Duration
+	// OnError, if set, is called with the errors that interrupt polling.
+	// Watching resumes with the next poll, with a growing delay while the
+	// errors persist.
+	OnError func(err error)
+}
+
+// TaskEvent reports a task that was seen for the first time or whose status
+// changed.
+type TaskEvent struct {
+	Task Task
+	// PreviousStatus is the status the task had when last seen, or "" if it
+	// was not seen before.
+	PreviousStatus string
+	Time           time.Time
+}
+
+// Final reports whether the task has completed with this event, no other
+// event follows for it.
+func (e TaskEvent) Final() bool {
+	return e.Task.done()
+}
+
+const (
+	defaultWatchInterval = time.Second * 5
+	maxWatchInterval     = time.Minute
+	// watchOrgsInterval is how often a location-wide watch lists the orgs of
+	// the location again.
+	watchOrgsInterval = time.Minute * 5
+	// watchFinishedTTL is how long completed tasks are remembered to ignore
+	// them if they are listed as active again by a lagging API.
+	watchFinishedTTL = time.Hour
+	// watchHistoryOverlap widens the task history read by each poll, to
+	// catch tasks that the API lists late.
+	watchHistoryOverlap = time.Minute
+)
+
+// WatchTasks polls the active tasks of an org or location and sends an event
+// whenever a task appears or its status changes, e.g. from queued to running
+// and from running to success or error. Tasks that are already active when
+// the watch starts are reported first. Each transition is reported once.
+// Every poll also reads the task history of the orgs since the previous poll,
+// so a task that starts and completes between two polls is reported too,
+// with a single event for its final state. The channel is closed when ctx is
+// done.
+func (c *Client) WatchTasks(ctx context.Context, filter TaskWatchFilter) (<-chan TaskEvent, error) {
+	if filter.LocationID == "" {
+		return nil, errors.New("watching tasks requires a location ID")
+	}
+	if filter.PollInterval <= 0 {
+		filter.PollInterval = defaultWatchInterval
+	}
+	events := make(chan TaskEvent, 64)
+	w := &taskWatch{
+		client:   c,
+		filter:   filter,
+		events:   events,
+		known:    map[string]Task{},
+		finished: map[string]time.Time{},
+		started:  time.Now(),
+	}
+	w.historySince = w.started
+	go w.run(ctx)
+	return events, nil
+}
+
+type taskWatch struct {
+	client *Client
+	filter TaskWatchFilter
+	events chan<- TaskEvent
+	// known are the tasks seen active, by UUID.
+	known    map[string]Task
+	finished map[string]time.Time
+	orgs     []Org
+	orgsTime time.Time
+	// started is when the watch started, tasks that completed earlier are
+	// not reported.
+	started time.Time
+	// historySince is when the last successful poll started.
+	historySince time.Time
+}
+
+func (w *taskWatch) run(ctx context.Context) {
+	defer close(w.events)
+	interval := w.filter.PollInterval
+	for {
+		err := w.poll(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			if w.filter.OnError != nil {
+				w.filter.OnError(err)
+			}
+			interval *= 2
+			if interval > maxWatchInterval {
+				interval = maxWatchInterval
+			}
+		} else {
+			interval = w.filter.PollInterval
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+func (w *taskWatch) poll(ctx context.Context) error {
+	orgs, err := w.watchedOrgs(ctx)
+	if err != nil {
+		return err
+	}
+	pollTime := time.Now()
+	active := map[string]Task{}
+	history := []Task{}
+	for _, org := range orgs {
+		tasks, err := org.GetActiveTasksContext(ctx)
+		if err != nil {
+			// the known tasks of this org must not be taken for completed.
+			return err
+		}
+		for _, task := range tasks {
+			active[task.UUID] = task
+		}
+		tasks, err = org.GetTaskHistoryContext(ctx, TaskHistoryFilter{Since: w.historySince.Add(-watchHistoryOverlap)})
+		if err != nil {
+			return err
+		}
+		history = append(history, tasks...)
+	}
+
+	now := time.Now()
+	for uuid, finishedAt := range w.finished {
+		if now.Sub(finishedAt) > watchFinishedTTL {
+			delete(w.finished, uuid)
+		}
+	}
+	for uuid, task := range active {
+		if _, ok := w.finished[uuid]; ok {
+			continue
+		}
+		if !w.observe(ctx, task) {
+			return ctx.Err()
+		}
+	}
+	// the history has the final state of the tasks that completed since the
+	// previous poll, including the ones that were never listed as active.
+	startedAt := getUnixMilliseconds(w.started)
+	for _, task := range history {
+		if _, ok := active[task.UUID]; ok || !task.done() {
+			continue
+		}
+		if _, ok := w.finished[task.UUID]; ok {
+			continue
+		}
+		if task.EndTime > 0 && task.EndTime < startedAt {
+			continue
+		}
+		if !w.observe(ctx, task) {
+			return ctx.Err()
+		}
+	}
+	// the tasks that are no longer listed as active have completed, fetch
+	// their final state.
+	var refreshErr error
+	for uuid, task := range w.known {
+		if _, ok := active[uuid]; ok {
+			continue
+		}
+		latest, err := task.RefreshContext(ctx)
+		if err != nil {
+			if IsNotFound(err) {
+				delete(w.known, uuid)
+				continue
+			}
+			refreshErr = err
+			continue
+		}
+		if !w.observe(ctx, latest) {
+			return ctx.Err()
+		}
+	}
+	if refreshErr == nil {
+		w.historySince = pollTime
+	}
+	return refreshErr
+}
+
+// observe records the latest state of a task and sends an event if its
+// status changed. It returns false if ctx is done.
+func (w *taskWatch) observe(ctx context.Context, task Task) bool {
+	previous, seen := w.known[task.UUID]
+	done := task.done()
+	if done {
+		w.client.entityChanged(task.EntityUUID)
+		delete(w.known, task.UUID)
+		w.finished[task.UUID] = time.Now()
+	} else {
+		w.known[task.UUID] = task
+	}
+	if seen && previous.Status == task.Status && !done {
+		return true
+	}
+	if !w.matches(task) {
+		return true
+	}
+	event := TaskEvent{Task: task, PreviousStatus: previous.Status, Time: time.Now()}
+	select {
+	case w.events <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (w *taskWatch) matches(task Task) bool {
+	if w.filter.EntityUUID != "" && task.EntityUUID != w.filter.EntityUUID {
+		return false
+	}
+	if w.filter.Operation != "" && task.Operation != w.filter.Operation {
+		return false
+	}
+	if w.filter.Username != "" && task.Username != w.filter.Username {
+		return false
+	}
+	return true
+}
+
+func (w *taskWatch) watchedOrgs(ctx context.Context) ([]Org, error) {
+	if w.filter.OrgUUID != "" {
+		return []Org{{client: w.client, LocationID: w.filter.LocationID, UUID: w.filter.OrgUUID}}, nil
+	}
+	if w.orgs != nil && time.Since(w.orgsTime) < watchOrgsInterval {
+		return w.orgs, nil
+	}
+	location := Location{client: w.client, ID: w.filter.LocationID}
+	orgs, err := location.GetOrgsContext(ctx)
+	if err != nil {
+		if w.orgs != nil {
+			return w.orgs, nil
+		}
+		return nil, err
+	}
+	for i, org := range orgs {
+		org.client = w.client
+		org.LocationID = w.filter.LocationID
+		orgs[i] = org
+	}
+	w.orgs = orgs
+	w.orgsTime = time.Now()
+	return orgs, nil
+}
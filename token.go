@@ -0,0 +1,144 @@
+package iland
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// tokenExpiryDelta is how long before its expiry a token is renewed.
+const tokenExpiryDelta = 60 * time.Second
+
+// TokenSource supplies the tokens used to authenticate API requests. It
+// follows the semantics of golang.org/x/oauth2.TokenSource: Token returns a
+// token that is currently valid, obtaining a new one if necessary. A token
+// with a zero Expiry never expires.
+//
+// The Client caches the returned token until it is about to expire and
+// never calls Token from more than one goroutine at a time.
+type TokenSource interface {
+	Token() (*Token, error)
+}
+
+// TokenSourceFunc adapts a function to a TokenSource, e.g. to wrap an
+// oauth2.TokenSource or a secret store lookup.
+type TokenSourceFunc func() (*Token, error)
+
+func (f TokenSourceFunc) Token() (*Token, error) {
+	return f()
+}
+
+// StaticTokenSource returns a TokenSource that always returns token, e.g. a
+// pre-issued token obtained out of band.
+func StaticTokenSource(token Token) TokenSource {
+	return TokenSourceFunc(func() (*Token, error) {
+		return &token, nil
+	})
+}
+
+func (t *Token) valid() bool {
+	if t == nil || t.AccessToken == "" {
+		return false
+	}
+	return t.Expiry.IsZero() || time.Now().Add(tokenExpiryDelta).Before(t.Expiry)
+}
+
+// contextTokenSource is implemented by the built-in token sources, which
+// honor the context of the request that triggered the token renewal.
+type contextTokenSource interface {
+	tokenContext(ctx context.Context) (*Token, error)
+}
+
+// passwordTokenSource obtains tokens with the password grant and renews
+// them with the refresh token grant, falling back to the password grant.
+type passwordTokenSource struct {
+	client *Client
+	last   Token
+}
+
+func (s *passwordTokenSource) Token() (*Token, error) {
+	return s.tokenContext(context.Background())
+}
+
+func (s *passwordTokenSource) tokenContext(ctx context.Context) (*Token, error) {
+	cache := s.client.tokenCache
+	cacheKey := tokenCacheKey(s.client.username, s.client.clientID, s.client.accessURL)
+	if s.last.AccessToken == "" && cache != nil {
+		if cached, ok, err := cache.Load(cacheKey); err == nil && ok {
+			s.last = cached
+			if cached.valid() {
+				return &cached, nil
+			}
+		}
+	}
+	token, err := s.renew(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.last = token
+	if cache != nil {
+		// a cache that cannot be written only costs a password grant on the
+		// next run, so it does not fail the request.
+		cache.Store(cacheKey, token)
+	}
+	return &token, nil
+}
+
+func (s *passwordTokenSource) renew(ctx context.Context) (Token, error) {
+	if s.last.RefreshToken != "" {
+		token, err := s.client.refreshToken(ctx, s.last.RefreshToken)
+		if err == nil {
+			return token, nil
+		}
+	}
+	return s.client.getToken(ctx)
+}
+
+// reuseTokenSource caches the token of its source until it is about to
+// expire. Concurrent callers that find the token expired wait for a single
+// renewal instead of each renewing it.
+type reuseTokenSource struct {
+	lock      chan struct{}
+	current   *Token
+	source    TokenSource
+	onRenewal func(ctx context.Context, token *Token, err error)
+}
+
+func newReuseTokenSource(source TokenSource, onRenewal func(ctx context.Context, token *Token, err error)) *reuseTokenSource {
+	return &reuseTokenSource{
+		lock:      make(chan struct{}, 1),
+		source:    source,
+		onRenewal: onRenewal,
+	}
+}
+
+func (s *reuseTokenSource) token(ctx context.Context) (*Token, error) {
+	select {
+	case s.lock <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-s.lock }()
+	if s.current.valid() {
+		return s.current, nil
+	}
+	var token *Token
+	var err error
+	if source, ok := s.source.(contextTokenSource); ok {
+		token, err = source.tokenContext(ctx)
+	} else {
+		token, err = s.source.Token()
+	}
+	if err == nil && (token == nil || token.AccessToken == "") {
+		err = errors.New("token source returned an empty token")
+	}
+	if err != nil {
+		token = nil
+	} else {
+		s.current = token
+	}
+	if s.onRenewal != nil {
+		s.onRenewal(ctx, token, err)
+	}
+	return token, err
+}
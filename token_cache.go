@@ -0,0 +1,153 @@
+package iland
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// TokenCache persists the tokens obtained with the password grant so that
+// short-lived processes sharing the same credentials can reuse them, see
+// WithTokenCache.
+type TokenCache interface {
+	// Load returns the token stored under key, or false if there is none.
+	Load(key string) (Token, bool, error)
+	Store(key string, token Token) error
+}
+
+// FileTokenCache is a TokenCache backed by a single file, readable and
+// writable by the current user only, that is optionally encrypted.
+type FileTokenCache struct {
+	mu   sync.Mutex
+	path string
+	aead cipher.AEAD
+}
+
+// NewFileTokenCache returns a TokenCache that stores tokens in plain JSON
+// at path.
+func NewFileTokenCache(path string) *FileTokenCache {
+	return &FileTokenCache{path: path}
+}
+
+// NewEncryptedFileTokenCache returns a TokenCache that stores tokens at path
+// encrypted with AES-GCM. The key must be 16, 24 or 32 bytes long.
+func NewEncryptedFileTokenCache(path string, key []byte) (*FileTokenCache, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &FileTokenCache{path: path, aead: aead}, nil
+}
+
+// DefaultTokenCachePath returns the path of the token cache file in the
+// user's cache directory.
+func DefaultTokenCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "iland", "tokens"), nil
+}
+
+func (f *FileTokenCache) Load(key string) (Token, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	tokens, err := f.read()
+	if err != nil {
+		return Token{}, false, err
+	}
+	token, ok := tokens[key]
+	return token, ok, nil
+}
+
+func (f *FileTokenCache) Store(key string, token Token) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	tokens, err := f.read()
+	if err != nil {
+		// an unreadable cache, e.g. one encrypted with another key, is
+		// replaced rather than blocking authentication forever.
+		tokens = map[string]Token{}
+	}
+	tokens[key] = token
+	return f.write(tokens)
+}
+
+func (f *FileTokenCache) read() (map[string]Token, error) {
+	tokens := map[string]Token{}
+	data, err := ioutil.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return tokens, nil
+	}
+	if err != nil {
+		return tokens, err
+	}
+	if f.aead != nil {
+		nonceSize := f.aead.NonceSize()
+		if len(data) < nonceSize {
+			return tokens, errors.New("token cache is corrupted")
+		}
+		data, err = f.aead.Open(nil, data[:nonceSize], data[nonceSize:], nil)
+		if err != nil {
+			return tokens, err
+		}
+	}
+	err = json.Unmarshal(data, &tokens)
+	return tokens, err
+}
+
+func (f *FileTokenCache) write(tokens map[string]Token) error {
+	data, err := json.Marshal(&tokens)
+	if err != nil {
+		return err
+	}
+	if f.aead != nil {
+		nonce := make([]byte, f.aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return err
+		}
+		data = f.aead.Seal(nonce, nonce, data, nil)
+	}
+	dir := filepath.Dir(f.path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	// write to a temporary file first so that concurrent processes never
+	// read a partially written cache.
+	tmp, err := ioutil.TempFile(dir, filepath.Base(f.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
+
+// tokenCacheKey identifies the tokens of one user of one API client without
+// storing the username in clear text.
+func tokenCacheKey(username, clientID, accessURL string) string {
+	sum := sha256.Sum256([]byte(username + "\x00" + clientID + "\x00" + accessURL))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,155 @@
+package iland
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileTokenCache(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	encrypted, err := NewEncryptedFileTokenCache(filepath.Join(t.TempDir(), "iland", "tokens"), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caches := map[string]*FileTokenCache{
+		"plain":     NewFileTokenCache(filepath.Join(t.TempDir(), "iland", "tokens")),
+		"encrypted": encrypted,
+	}
+	token := Token{AccessToken: "secret-access", RefreshToken: "secret-refresh", ExpiresIn: 3600, Expiry: time.Date(2026, 10, 18, 3, 0, 0, 0, time.UTC)}
+	for name, cache := range caches {
+		if _, ok, err := cache.Load("a"); ok || err != nil {
+			t.Errorf("%s: Load from a missing file = %v, %v, want nothing", name, ok, err)
+		}
+		if err := cache.Store("a", token); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if err := cache.Store("b", Token{AccessToken: "other"}); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		loaded, ok, err := cache.Load("a")
+		if err != nil || !ok || loaded != token {
+			t.Errorf("%s: Load = %+v, %v, %v, want %+v", name, loaded, ok, err, token)
+		}
+		if loaded, ok, _ := cache.Load("b"); !ok || loaded.AccessToken != "other" {
+			t.Errorf("%s: Load(b) = %+v, %v, want the other token kept", name, loaded, ok)
+		}
+
+		info, err := os.Stat(cache.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("%s: file mode %v, want 0600", name, info.Mode().Perm())
+		}
+		if info, _ := os.Stat(filepath.Dir(cache.path)); info.Mode().Perm() != 0700 {
+			t.Errorf("%s: directory mode %v, want 0700", name, info.Mode().Perm())
+		}
+		entries, _ := os.ReadDir(filepath.Dir(cache.path))
+		if len(entries) != 1 {
+			t.Errorf("%s: %d files in the cache directory, want the temporary ones removed", name, len(entries))
+		}
+		data, _ := os.ReadFile(cache.path)
+		if secret := strings.Contains(string(data), "secret-access"); secret != (name == "plain") {
+			t.Errorf("%s: file contains the access token in clear: %v", name, secret)
+		}
+	}
+}
+
+func TestEncryptedFileTokenCacheKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens")
+	if _, err := NewEncryptedFileTokenCache(path, []byte("short")); err == nil {
+		t.Error("no error for an invalid key")
+	}
+	cache, _ := NewEncryptedFileTokenCache(path, bytes.Repeat([]byte{1}, 32))
+	cache.Store("a", Token{AccessToken: "a"})
+	other, _ := NewEncryptedFileTokenCache(path, bytes.Repeat([]byte{2}, 32))
+	if _, ok, err := other.Load("a"); ok || err == nil {
+		t.Errorf("Load with another key = %v, %v, want an error", ok, err)
+	}
+	if err := other.Store("b", Token{AccessToken: "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if token, ok, err := other.Load("b"); err != nil || !ok || token.AccessToken != "b" {
+		t.Errorf("Load = %+v, %v, %v, want the cache replaced", token, ok, err)
+	}
+}
+
+func TestTokenCacheKey(t *testing.T) {
+	key := tokenCacheKey("user", "client", "https://auth")
+	if key != tokenCacheKey("user", "client", "https://auth") {
+		t.Error("key is not stable")
+	}
+	for _, other := range []string{
+		tokenCacheKey("other", "client", "https://auth"),
+		tokenCacheKey("user", "other", "https://auth"),
+		tokenCacheKey("user", "client", "https://other"),
+		tokenCacheKey("userclient", "", "https://auth"),
+	} {
+		if other == key {
+			t.Errorf("key %s is shared", key)
+		}
+	}
+	if strings.Contains(key, "user") {
+		t.Errorf("key %s contains the username", key)
+	}
+}
+
+func TestClientTokenCache(t *testing.T) {
+	tokens, authURL := newTokenServer(t, 3600)
+	path := filepath.Join(t.TempDir(), "tokens")
+	cache, _ := NewEncryptedFileTokenCache(path, bytes.Repeat([]byte{1}, 32))
+	newClient := func(username, clientID string, cache TokenCache) *Client {
+		t.Helper()
+		client, err := NewClientWithOptions(username, "password", clientID, "secret", WithAuthURL(authURL), WithTokenCache(cache))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return client
+	}
+
+	first := newClient("user", "client", cache)
+	second := newClient("user", "client", cache)
+	if tokens.count("password") != 1 || second.Token.AccessToken != first.Token.AccessToken {
+		t.Errorf("%d password grants and tokens %s and %s, want the cached token reused", tokens.count("password"), first.Token.AccessToken, second.Token.AccessToken)
+	}
+	newClient("other", "client", cache)
+	newClient("user", "other", cache)
+	if got := tokens.count("password"); got != 3 {
+		t.Errorf("%d password grants, want one per username and client ID", got)
+	}
+
+	otherKey, _ := NewEncryptedFileTokenCache(path, bytes.Repeat([]byte{2}, 32))
+	if client := newClient("user", "client", otherKey); client.Token.AccessToken != "password-4" {
+		t.Errorf("token = %s, want a password grant with a cache that cannot be decrypted", client.Token.AccessToken)
+	}
+	if _, ok, err := otherKey.Load(tokenCacheKey("user", "client", authURL)); !ok || err != nil {
+		t.Errorf("Load = %v, %v, want the cache rewritten with the new key", ok, err)
+	}
+
+	if err := os.WriteFile(path, []byte("corrupted"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if client := newClient("user", "client", NewFileTokenCache(path)); client.Token.AccessToken != "password-5" {
+		t.Errorf("token = %s, want a password grant with a corrupted cache", client.Token.AccessToken)
+	}
+}
+
+func TestClientTokenCacheExpired(t *testing.T) {
+	tokens, authURL := newTokenServer(t, 3600)
+	cache := NewFileTokenCache(filepath.Join(t.TempDir(), "tokens"))
+	cache.Store(tokenCacheKey("user", "client", authURL), Token{AccessToken: "old", RefreshToken: "refresh", Expiry: time.Now().Add(-time.Hour)})
+	client, err := NewClientWithOptions("user", "password", "client", "secret", WithAuthURL(authURL), WithTokenCache(cache))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.Token.AccessToken != "refresh_token-1" || tokens.count("password") != 0 {
+		t.Errorf("token = %s, want the cached refresh token used", client.Token.AccessToken)
+	}
+	if cached, _, _ := cache.Load(tokenCacheKey("user", "client", authURL)); cached.AccessToken != "refresh_token-1" {
+		t.Errorf("cached token = %s, want the refreshed one stored", cached.AccessToken)
+	}
+}
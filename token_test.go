@@ -0,0 +1,189 @@
+package iland
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// tokenServer grants tokens valid for expiresIn seconds, named after their
+// grant type and rank, and counts the grants of each type.
+type tokenServer struct {
+	mu        sync.Mutex
+	expiresIn int64
+	delay     time.Duration
+	grants    map[string]int
+	issued    int
+}
+
+func newTokenServer(t *testing.T, expiresIn int64) (*tokenServer, string) {
+	tokens := &tokenServer{expiresIn: expiresIn, grants: map[string]int{}}
+	server := httptest.NewServer(tokens)
+	t.Cleanup(server.Close)
+	return tokens, server.URL
+}
+
+func (s *tokenServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	time.Sleep(s.delay)
+	grant := r.PostForm.Get("grant_type")
+	s.grants[grant]++
+	s.issued++
+	fmt.Fprintf(w, `{"access_token":"%s-%d","expires_in":%d,"refresh_token":"refresh-%d"}`, grant, s.issued, s.expiresIn, s.issued)
+}
+
+func (s *tokenServer) set(expiresIn int64, delay time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expiresIn, s.delay = expiresIn, delay
+}
+
+func (s *tokenServer) count(grant string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.grants[grant]
+}
+
+func TestTokenValid(t *testing.T) {
+	tests := []struct {
+		name  string
+		token *Token
+		want  bool
+	}{
+		{"nil", nil, false},
+		{"empty", &Token{}, false},
+		{"never expires", &Token{AccessToken: "a"}, true},
+		{"expired", &Token{AccessToken: "a", Expiry: time.Now().Add(-time.Minute)}, false},
+		{"about to expire", &Token{AccessToken: "a", Expiry: time.Now().Add(tokenExpiryDelta / 2)}, false},
+		{"valid", &Token{AccessToken: "a", Expiry: time.Now().Add(time.Hour)}, true},
+	}
+	for _, test := range tests {
+		if got := test.token.valid(); got != test.want {
+			t.Errorf("%s: valid() = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestReuseTokenSourceConcurrent(t *testing.T) {
+	calls := int32(0)
+	source := newReuseTokenSource(TokenSourceFunc(func() (*Token, error) {
+		n := atomic.AddInt32(&calls, 1)
+		time.Sleep(20 * time.Millisecond)
+		return &Token{AccessToken: fmt.Sprint("token-", n), Expiry: time.Now().Add(time.Hour)}, nil
+	}), nil)
+	source.current = &Token{AccessToken: "expired", Expiry: time.Now().Add(-time.Minute)}
+	tokens := make([]*Token, 50)
+	errs := make([]error, len(tokens))
+	wg := sync.WaitGroup{}
+	for i := range tokens {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tokens[i], errs[i] = source.token(context.Background())
+		}(i)
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Errorf("token renewed %d times, want once", calls)
+	}
+	for i, token := range tokens {
+		if errs[i] != nil || token == nil || token.AccessToken != "token-1" {
+			t.Fatalf("caller %d got %+v, %v, want token-1", i, token, errs[i])
+		}
+	}
+}
+
+func TestReuseTokenSourceErrors(t *testing.T) {
+	results := []func() (*Token, error){
+		func() (*Token, error) { return nil, errors.New("unavailable") },
+		func() (*Token, error) { return &Token{}, nil },
+		func() (*Token, error) { return &Token{AccessToken: "a", Expiry: time.Now().Add(time.Hour)}, nil },
+	}
+	calls := 0
+	renewals := []string{}
+	source := newReuseTokenSource(TokenSourceFunc(func() (*Token, error) {
+		calls++
+		return results[calls-1]()
+	}), func(ctx context.Context, token *Token, err error) {
+		renewals = append(renewals, fmt.Sprint(token != nil, err))
+	})
+	if _, err := source.token(context.Background()); err == nil || err.Error() != "unavailable" {
+		t.Errorf("first err = %v, want the source's error", err)
+	}
+	if _, err := source.token(context.Background()); err == nil {
+		t.Error("no error for an empty token")
+	}
+	for i := 0; i < 2; i++ {
+		if token, err := source.token(context.Background()); err != nil || token.AccessToken != "a" {
+			t.Errorf("token = %+v, %v, want a", token, err)
+		}
+	}
+	if calls != 3 {
+		t.Errorf("source called %d times, want 3: failures are not cached, valid tokens are", calls)
+	}
+	want := fmt.Sprint([]string{"false unavailable", "false token source returned an empty token", "true <nil>"})
+	if fmt.Sprint(renewals) != want {
+		t.Errorf("renewals = %v, want %v", renewals, want)
+	}
+}
+
+func TestReuseTokenSourceCanceled(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	source := newReuseTokenSource(TokenSourceFunc(func() (*Token, error) {
+		close(started)
+		<-release
+		return &Token{AccessToken: "a"}, nil
+	}), nil)
+	go source.token(context.Background())
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := source.token(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the context's error while another renewal is running", err)
+	}
+	close(release)
+}
+
+func TestClientTokenRenewedOnce(t *testing.T) {
+	tokens, authURL := newTokenServer(t, 30)
+	client, err := NewClientWithOptions("user", "password", "client", "secret", WithAuthURL(authURL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.Token.AccessToken != "password-1" {
+		t.Fatalf("token = %s, want password-1", client.Token.AccessToken)
+	}
+	// the first token expires within tokenExpiryDelta, so every caller
+	// below finds it expired.
+	tokens.set(3600, 20*time.Millisecond)
+	errs := make(chan error, 20)
+	for i := 0; i < cap(errs); i++ {
+		go func() {
+			errs <- client.RefreshTokenIfNecessary()
+		}()
+	}
+	for i := 0; i < cap(errs); i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := tokens.count("refresh_token"); got != 1 {
+		t.Errorf("%d refresh grants, want 1", got)
+	}
+	if got := tokens.count("password"); got != 1 {
+		t.Errorf("%d password grants, want 1", got)
+	}
+	token, err := client.token(context.Background())
+	if err != nil || token.AccessToken != "refresh_token-2" {
+		t.Errorf("token = %+v, %v, want refresh_token-2", token, err)
+	}
+}
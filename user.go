@@ -1,6 +1,7 @@
 package iland
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 )
@@ -32,16 +33,36 @@ type UserRole struct {
 	OrgUUID  string `json:"org_uuid"`
 }
 
-func (u *User) GetRoles() []UserRole {
+func (u *User) GetRoles() ([]UserRole, error) {
+	return u.GetRolesContext(context.Background())
+}
+
+func (u *User) GetRolesContext(ctx context.Context) ([]UserRole, error) {
 	roles := []UserRole{}
-	data, _ := u.client.Get(fmt.Sprintf("/user/%s/roles", u.Name))
-	json.Unmarshal(data, &roles)
-	return roles
+	data, err := u.client.GetContext(ctx, fmt.Sprintf("/user/%s/roles", u.Name))
+	if err != nil {
+		return roles, err
+	}
+	err = json.Unmarshal(data, &roles)
+	if err != nil {
+		return roles, err
+	}
+	return roles, nil
+}
+
+func (u *User) GetAlerts() ([]Alert, error) {
+	return u.GetAlertsContext(context.Background())
 }
 
-func (u *User) GetAlerts() []Alert {
+func (u *User) GetAlertsContext(ctx context.Context) ([]Alert, error) {
 	alerts := []Alert{}
-	data, _ := u.client.Get(fmt.Sprintf("/user/%s/alerts", u.Name))
-	json.Unmarshal(data, &alerts)
-	return alerts
+	data, err := u.client.GetContext(ctx, fmt.Sprintf("/user/%s/alerts", u.Name))
+	if err != nil {
+		return alerts, err
+	}
+	err = json.Unmarshal(data, &alerts)
+	if err != nil {
+		return alerts, err
+	}
+	return alerts, nil
 }
@@ -1,10 +1,12 @@
 package iland
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
+	"regexp"
 	"time"
 )
 
@@ -25,32 +27,68 @@ type VApp struct {
 	UpdatedDate         int      `json:"updated_date"`
 }
 
-func (v VApp) GetVirtualMachines() []VirtualMachine {
+func (v VApp) GetVirtualMachines() ([]VirtualMachine, error) {
+	return v.GetVirtualMachinesContext(context.Background())
+}
+
+func (v VApp) GetVirtualMachinesContext(ctx context.Context) ([]VirtualMachine, error) {
 	virtualMachines := []VirtualMachine{}
-	data, _ := v.client.Get(fmt.Sprintf("/vapp/%s/vms", v.UUID))
-	json.Unmarshal(data, &virtualMachines)
+	data, err := v.client.GetContext(ctx, fmt.Sprintf("/vapp/%s/vms", v.UUID))
+	if err != nil {
+		return virtualMachines, err
+	}
+	err = json.Unmarshal(data, &virtualMachines)
+	if err != nil {
+		return virtualMachines, err
+	}
 	for i, virtualMachine := range virtualMachines {
 		virtualMachine.client = v.client
 		virtualMachines[i] = virtualMachine
 	}
-	return virtualMachines
+	return virtualMachines, nil
 }
 
-func (v VApp) GetVAppNetworks() []VAppNetwork {
+func (v VApp) GetVAppNetworks() ([]VAppNetwork, error) {
+	return v.GetVAppNetworksContext(context.Background())
+}
+
+func (v VApp) GetVAppNetworksContext(ctx context.Context) ([]VAppNetwork, error) {
 	vAppNetworks := []VAppNetwork{}
-	data, _ := v.client.Get(fmt.Sprintf("/vapp/%s/networks", v.UUID))
-	json.Unmarshal(data, &vAppNetworks)
+	data, err := v.client.GetContext(ctx, fmt.Sprintf("/vapp/%s/networks", v.UUID))
+	if err != nil {
+		return vAppNetworks, err
+	}
+	err = json.Unmarshal(data, &vAppNetworks)
+	if err != nil {
+		return vAppNetworks, err
+	}
 	for i, vAppNetwork := range vAppNetworks {
 		vAppNetwork.client = v.client
 		vAppNetworks[i] = vAppNetwork
 	}
-	return vAppNetworks
+	return vAppNetworks, nil
+}
+
+// IsBusy reports whether the vApp has active tasks, in which case the
+// methods that modify it wait or fail, see ReadinessPolicy.
+func (v VApp) IsBusy() (bool, error) {
+	return v.IsBusyContext(context.Background())
+}
+
+func (v VApp) IsBusyContext(ctx context.Context) (bool, error) {
+	return v.client.isBusy(ctx, v.LocationID, v.UUID)
 }
 
 func (v VApp) Delete() (Task, error) {
-	v.client.waitUntilObjectIsReady(v.LocationID, v.UUID)
+	return v.DeleteContext(context.Background())
+}
+
+func (v VApp) DeleteContext(ctx context.Context) (Task, error) {
+	if err := v.client.waitUntilObjectIsReady(ctx, v.LocationID, v.UUID); err != nil {
+		return Task{}, err
+	}
 	task := Task{}
-	data, err := v.client.Delete(fmt.Sprintf("/vapp/%s", v.UUID))
+	data, err := v.client.DeleteContext(ctx, fmt.Sprintf("/vapp/%s", v.UUID))
 	if err != nil {
 		return task, err
 	}
@@ -60,9 +98,15 @@ func (v VApp) Delete() (Task, error) {
 }
 
 func (v VApp) PowerOn() (Task, error) {
-	v.client.waitUntilObjectIsReady(v.LocationID, v.UUID)
+	return v.PowerOnContext(context.Background())
+}
+
+func (v VApp) PowerOnContext(ctx context.Context) (Task, error) {
+	if err := v.client.waitUntilObjectIsReady(ctx, v.LocationID, v.UUID); err != nil {
+		return Task{}, err
+	}
 	task := Task{}
-	data, err := v.client.Post(fmt.Sprintf("/vapp/%s/poweron", v.UUID), []byte{})
+	data, err := v.client.PostContext(ctx, fmt.Sprintf("/vapp/%s/poweron", v.UUID), []byte{})
 	if err != nil {
 		return task, err
 	}
@@ -72,9 +116,15 @@ func (v VApp) PowerOn() (Task, error) {
 }
 
 func (v VApp) PowerOff() (Task, error) {
-	v.client.waitUntilObjectIsReady(v.LocationID, v.UUID)
+	return v.PowerOffContext(context.Background())
+}
+
+func (v VApp) PowerOffContext(ctx context.Context) (Task, error) {
+	if err := v.client.waitUntilObjectIsReady(ctx, v.LocationID, v.UUID); err != nil {
+		return Task{}, err
+	}
 	task := Task{}
-	data, err := v.client.Post(fmt.Sprintf("/vapp/%s/poweroff", v.UUID), []byte{})
+	data, err := v.client.PostContext(ctx, fmt.Sprintf("/vapp/%s/poweroff", v.UUID), []byte{})
 	if err != nil {
 		return task, err
 	}
@@ -84,9 +134,15 @@ func (v VApp) PowerOff() (Task, error) {
 }
 
 func (v VApp) Suspend() (Task, error) {
-	v.client.waitUntilObjectIsReady(v.LocationID, v.UUID)
+	return v.SuspendContext(context.Background())
+}
+
+func (v VApp) SuspendContext(ctx context.Context) (Task, error) {
+	if err := v.client.waitUntilObjectIsReady(ctx, v.LocationID, v.UUID); err != nil {
+		return Task{}, err
+	}
 	task := Task{}
-	data, err := v.client.Post(fmt.Sprintf("/vapp/%s/suspend", v.UUID), []byte{})
+	data, err := v.client.PostContext(ctx, fmt.Sprintf("/vapp/%s/suspend", v.UUID), []byte{})
 	if err != nil {
 		return task, err
 	}
@@ -96,7 +152,13 @@ func (v VApp) Suspend() (Task, error) {
 }
 
 func (v VApp) Rename(newVAppName string) (Task, error) {
-	v.client.waitUntilObjectIsReady(v.LocationID, v.UUID)
+	return v.RenameContext(context.Background(), newVAppName)
+}
+
+func (v VApp) RenameContext(ctx context.Context, newVAppName string) (Task, error) {
+	if err := v.client.waitUntilObjectIsReady(ctx, v.LocationID, v.UUID); err != nil {
+		return Task{}, err
+	}
 	task := Task{}
 	params := struct {
 		Name string `json:"name"`
@@ -104,7 +166,7 @@ func (v VApp) Rename(newVAppName string) (Task, error) {
 		Name: newVAppName,
 	}
 	output, _ := json.Marshal(&params)
-	data, err := v.client.Put(fmt.Sprintf("/vapp/%s/name", v.UUID), output)
+	data, err := v.client.PutContext(ctx, fmt.Sprintf("/vapp/%s/name", v.UUID), output)
 	if err != nil {
 		return task, err
 	}
@@ -113,21 +175,29 @@ func (v VApp) Rename(newVAppName string) (Task, error) {
 	return task, err
 }
 
-func (v VApp) HasSnapshot() bool {
+func (v VApp) HasSnapshot() (bool, error) {
+	return v.HasSnapshotContext(context.Background())
+}
+
+func (v VApp) HasSnapshotContext(ctx context.Context) (bool, error) {
 	check := struct {
 		HasSnapshot bool `json:"has_snapshot"`
 	}{}
-	data, err := v.client.Get(fmt.Sprintf("/vapp/%s/snapshot/check", v.UUID))
+	data, err := v.client.GetContext(ctx, fmt.Sprintf("/vapp/%s/snapshot/check", v.UUID))
 	if err != nil {
-		return false
+		return false, err
 	}
-	json.Unmarshal(data, &check)
-	return check.HasSnapshot
+	err = json.Unmarshal(data, &check)
+	return check.HasSnapshot, err
 }
 
 func (v VApp) GetSnapshot() (Snapshot, error) {
+	return v.GetSnapshotContext(context.Background())
+}
+
+func (v VApp) GetSnapshotContext(ctx context.Context) (Snapshot, error) {
 	snapshot := Snapshot{}
-	data, err := v.client.Get(fmt.Sprintf("/vapp/%s/snapshot", v.UUID))
+	data, err := v.client.GetContext(ctx, fmt.Sprintf("/vapp/%s/snapshot", v.UUID))
 	if err != nil {
 		return snapshot, err
 	}
@@ -136,20 +206,45 @@ func (v VApp) GetSnapshot() (Snapshot, error) {
 }
 
 func (v VApp) TakeSnapshot() (Task, error) {
-	v.client.waitUntilObjectIsReady(v.LocationID, v.UUID)
-	task := Task{}
-	params := struct {
-		Name        string `json:"name"`
-		Description string `json:"description"`
-		Memory      bool   `json:"memory"`
-		Quiesce     bool   `json:"quiesce"`
-	}{
-		Name:    time.Now().UTC().String(),
-		Memory:  false,
-		Quiesce: false,
+	return v.TakeSnapshotContext(context.Background())
+}
+
+func (v VApp) TakeSnapshotContext(ctx context.Context) (Task, error) {
+	return v.TakeSnapshotWithOptionsContext(ctx, SnapshotOptions{})
+}
+
+// TakeSnapshotWithOptions replaces the snapshot of the vApp, if any, with a
+// new one.
+func (v VApp) TakeSnapshotWithOptions(opts SnapshotOptions) (Task, error) {
+	return v.TakeSnapshotWithOptionsContext(context.Background(), opts)
+}
+
+func (v VApp) TakeSnapshotWithOptionsContext(ctx context.Context, opts SnapshotOptions) (Task, error) {
+	if err := v.client.waitUntilObjectIsReady(ctx, v.LocationID, v.UUID); err != nil {
+		return Task{}, err
 	}
+	task := Task{}
+	params := opts.withDefaults()
 	output, _ := json.Marshal(&params)
-	data, err := v.client.Post(fmt.Sprintf("/vapp/%s/snapshot", v.UUID), output)
+	data, err := v.client.PostContext(ctx, fmt.Sprintf("/vapp/%s/snapshot", v.UUID), output)
+	if err != nil {
+		return task, err
+	}
+	err = json.Unmarshal(data, &task)
+	task.client = v.client
+	return task, err
+}
+
+func (v VApp) RemoveSnapshot() (Task, error) {
+	return v.RemoveSnapshotContext(context.Background())
+}
+
+func (v VApp) RemoveSnapshotContext(ctx context.Context) (Task, error) {
+	if err := v.client.waitUntilObjectIsReady(ctx, v.LocationID, v.UUID); err != nil {
+		return Task{}, err
+	}
+	task := Task{}
+	data, err := v.client.DeleteContext(ctx, fmt.Sprintf("/vapp/%s/snapshot", v.UUID))
 	if err != nil {
 		return task, err
 	}
@@ -159,9 +254,15 @@ func (v VApp) TakeSnapshot() (Task, error) {
 }
 
 func (v VApp) RevertSnapshot() (Task, error) {
-	v.client.waitUntilObjectIsReady(v.LocationID, v.UUID)
+	return v.RevertSnapshotContext(context.Background())
+}
+
+func (v VApp) RevertSnapshotContext(ctx context.Context) (Task, error) {
+	if err := v.client.waitUntilObjectIsReady(ctx, v.LocationID, v.UUID); err != nil {
+		return Task{}, err
+	}
 	task := Task{}
-	data, err := v.client.Post(fmt.Sprintf("/vapp/%s/snapshot/restore", v.UUID), []byte{})
+	data, err := v.client.PostContext(ctx, fmt.Sprintf("/vapp/%s/snapshot/restore", v.UUID), []byte{})
 	if err != nil {
 		return task, err
 	}
@@ -171,7 +272,13 @@ func (v VApp) RevertSnapshot() (Task, error) {
 }
 
 func (v VApp) Clone(targetVdcUUID, newVAppName string) (Task, error) {
-	v.client.waitUntilObjectIsReady(v.LocationID, v.UUID)
+	return v.CloneContext(context.Background(), targetVdcUUID, newVAppName)
+}
+
+func (v VApp) CloneContext(ctx context.Context, targetVdcUUID, newVAppName string) (Task, error) {
+	if err := v.client.waitUntilObjectIsReady(ctx, v.LocationID, v.UUID); err != nil {
+		return Task{}, err
+	}
 	task := Task{}
 	params := struct {
 		Name string `json:"name"`
@@ -179,7 +286,7 @@ func (v VApp) Clone(targetVdcUUID, newVAppName string) (Task, error) {
 		Name: newVAppName,
 	}
 	output, _ := json.Marshal(&params)
-	data, err := v.client.Post(fmt.Sprintf("/vapp/%s/copy/%s", v.UUID, targetVdcUUID), output)
+	data, err := v.client.PostContext(ctx, fmt.Sprintf("/vapp/%s/copy/%s", v.UUID, targetVdcUUID), output)
 	if err != nil {
 		return task, err
 	}
@@ -197,29 +304,42 @@ type AddVAppNetworkParams struct {
 	IPRanges     []IPRange `json:"ip_ranges"`
 }
 
-func (v VApp) AddVAppNetwork(params AddVAppNetworkParams) (Task, error) {
-	v.client.waitUntilObjectIsReady(v.LocationID, v.UUID)
-	task := Task{}
-	gateway := net.ParseIP(params.Gateway)
+func (p AddVAppNetworkParams) validate() error {
+	gateway := net.ParseIP(p.Gateway)
 	if gateway == nil {
-		return task, errors.New("invalid gateway address")
+		return errors.New("invalid gateway address")
 	}
-	netmask := net.ParseIP(params.Netmask)
+	netmask := net.ParseIP(p.Netmask)
 	if netmask == nil {
-		return task, errors.New("invalid netmask")
+		return errors.New("invalid netmask")
 	}
-	for _, ipRange := range params.IPRanges {
+	for _, ipRange := range p.IPRanges {
 		startAddress := net.ParseIP(ipRange.Start)
 		if startAddress == nil {
-			return task, errors.New("invalid ip range start address")
+			return errors.New("invalid ip range start address")
 		}
 		endAddress := net.ParseIP(ipRange.End)
 		if endAddress == nil {
-			return task, errors.New("invalid ip range end address")
+			return errors.New("invalid ip range end address")
 		}
 	}
+	return nil
+}
+
+func (v VApp) AddVAppNetwork(params AddVAppNetworkParams) (Task, error) {
+	return v.AddVAppNetworkContext(context.Background(), params)
+}
+
+func (v VApp) AddVAppNetworkContext(ctx context.Context, params AddVAppNetworkParams) (Task, error) {
+	if err := v.client.waitUntilObjectIsReady(ctx, v.LocationID, v.UUID); err != nil {
+		return Task{}, err
+	}
+	task := Task{}
+	if err := params.validate(); err != nil {
+		return task, err
+	}
 	output, _ := json.Marshal(&params)
-	data, err := v.client.Post(fmt.Sprintf("/vapp/%s/vapp-network", v.UUID), output)
+	data, err := v.client.PostContext(ctx, fmt.Sprintf("/vapp/%s/vapp-network", v.UUID), output)
 	if err != nil {
 		return task, err
 	}
@@ -229,9 +349,281 @@ func (v VApp) AddVAppNetwork(params AddVAppNetworkParams) (Task, error) {
 }
 
 func (v VApp) RemoveNetwork(vAppNetworkUUID string) (Task, error) {
-	v.client.waitUntilObjectIsReady(v.LocationID, v.UUID)
+	return v.RemoveNetworkContext(context.Background(), vAppNetworkUUID)
+}
+
+func (v VApp) RemoveNetworkContext(ctx context.Context, vAppNetworkUUID string) (Task, error) {
+	if err := v.client.waitUntilObjectIsReady(ctx, v.LocationID, v.UUID); err != nil {
+		return Task{}, err
+	}
+	task := Task{}
+	data, err := v.client.DeleteContext(ctx, fmt.Sprintf("/vapp/%s/network/%s", v.UUID, vAppNetworkUUID))
+	if err != nil {
+		return task, err
+	}
+	err = json.Unmarshal(data, &task)
+	task.client = v.client
+	return task, err
+}
+
+// VirtualMachineSpec describes a blank VM, built without a vApp template.
+type VirtualMachineSpec struct {
+	Name        string
+	Description string
+	// OperatingSystem is the guest OS type, e.g. "ubuntu64Guest" or
+	// "windows9Server64Guest".
+	OperatingSystem string
+	VCPU            int
+	// CoresPerSocket, if set, must divide VCPU.
+	CoresPerSocket  int
+	MemoryMB        int
+	HardwareVersion string
+	// StorageProfileUUID defaults to the default storage profile of the vdc.
+	StorageProfileUUID string
+	Disks              []VirtualMachineDiskSpec
+	// Nics are numbered in order. The first one is the primary nic unless
+	// another one is marked Primary.
+	Nics []VirtualMachineNicSpec
+	// BootMediaUUID, if set, is the media inserted in the VM to install its
+	// operating system from.
+	BootMediaUUID string
+}
+
+// VirtualMachineDiskSpec is a disk of a VirtualMachineSpec.
+type VirtualMachineDiskSpec struct {
+	// Name defaults to the name the API gives, e.g. "Hard disk 1".
+	Name   string
+	SizeMB int
+	// AdapterType is the bus of the disk, e.g. "paravirtual", "lsilogicsas",
+	// "sata" or "nvme". It defaults to the one the guest OS type prefers.
+	AdapterType string
+}
+
+// VirtualMachineNicSpec is a nic of a VirtualMachineSpec.
+type VirtualMachineNicSpec struct {
+	// NetworkName is the vApp network the nic connects to.
+	NetworkName string
+	// IPAddressMode is one of the IPAddressMode constants, DHCP by default.
+	IPAddressMode string
+	// IPAddress is required by MANUAL mode, and only allowed by it. It must
+	// be in the subnet of the network.
+	IPAddress string
+	// AdapterType, e.g. "VMXNET3" or "E1000E", defaults to the one the guest
+	// OS type prefers.
+	AdapterType string
+	Primary     bool
+	// Disconnected leaves the nic disconnected from its network.
+	Disconnected bool
+}
+
+type buildVirtualMachineParams struct {
+	Name               string            `json:"name"`
+	Description        string            `json:"description"`
+	OperatingSystem    string            `json:"os"`
+	VCPU               int               `json:"cpus_number"`
+	CoresPerSocket     int               `json:"cores_per_socket,omitempty"`
+	MemoryMB           int               `json:"memory_size"`
+	HardwareVersion    string            `json:"hardware_version,omitempty"`
+	StorageProfileUUID string            `json:"storage_profile_uuid,omitempty"`
+	Disks              []buildDiskParams `json:"disks"`
+	Nics               []buildNicParams  `json:"vnics"`
+	BootMediaUUID      string            `json:"media_uuid,omitempty"`
+}
+
+type buildDiskParams struct {
+	Name        string `json:"name,omitempty"`
+	Size        int    `json:"size"`
+	AdapterType string `json:"adapter_type,omitempty"`
+}
+
+type buildNicParams struct {
+	Index            int    `json:"vnic_id"`
+	NetworkName      string `json:"net_name"`
+	IPAllocationMode string `json:"address_mode"`
+	IPAddress        string `json:"ip_addr,omitempty"`
+	AdapterType      string `json:"adapter_type,omitempty"`
+	Primary          bool   `json:"primary_cnx"`
+	Connected        bool   `json:"connected"`
+}
+
+// validate checks the spec on its own, checkNetworks checks the networks of
+// its nics.
+func (s VirtualMachineSpec) validate() error {
+	if s.Name == "" {
+		return errors.New("virtual machine name is required")
+	}
+	if s.OperatingSystem == "" {
+		return fmt.Errorf("virtual machine, %s, has no operating system type", s.Name)
+	}
+	if s.VCPU < 1 {
+		return fmt.Errorf("virtual machine, %s, requires at least one cpu", s.Name)
+	}
+	if s.CoresPerSocket < 0 || (s.CoresPerSocket > 0 && s.VCPU%s.CoresPerSocket != 0) {
+		return fmt.Errorf("virtual machine, %s, has %d cpus which can't be split in sockets of %d cores", s.Name, s.VCPU, s.CoresPerSocket)
+	}
+	if s.MemoryMB < 1 {
+		return fmt.Errorf("virtual machine, %s, requires memory", s.Name)
+	}
+	for i, disk := range s.Disks {
+		if disk.SizeMB < 1 {
+			return fmt.Errorf("disk %d of virtual machine, %s, has no size", i, s.Name)
+		}
+	}
+	primary := 0
+	for i, nic := range s.Nics {
+		if nic.NetworkName == "" {
+			return fmt.Errorf("nic %d of virtual machine, %s, has no network", i, s.Name)
+		}
+		if err := validateIPAddressMode(nic.ipAddressMode(), nic.IPAddress); err != nil {
+			return fmt.Errorf("nic %d of virtual machine, %s: %w", i, s.Name, err)
+		}
+		if nic.Primary {
+			primary++
+		}
+	}
+	if primary > 1 {
+		return fmt.Errorf("virtual machine, %s, has more than one primary nic", s.Name)
+	}
+	return nil
+}
+
+// checkNetworks checks that the nics connect to networks of the vApp, given
+// by name, and that their manual addresses are in the networks' subnets.
+func (s VirtualMachineSpec) checkNetworks(networks map[string]subnet) error {
+	for i, nic := range s.Nics {
+		network, ok := networks[nic.NetworkName]
+		if !ok {
+			return fmt.Errorf("nic %d of virtual machine, %s, connects to network, %s, which is not a network of the vApp", i, s.Name, nic.NetworkName)
+		}
+		if nic.ipAddressMode() == IPAddressModeManual {
+			if err := network.check(nic.IPAddress); err != nil {
+				return fmt.Errorf("nic %d of virtual machine, %s: %w", i, s.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// subnet is the gateway and netmask of a network.
+type subnet struct {
+	gateway string
+	netmask string
+}
+
+// check checks that ipAddress is an address of the subnet other than its
+// gateway. Subnets that aren't IPv4 or whose gateway or netmask is unknown
+// are not checked.
+func (n subnet) check(ipAddress string) error {
+	ip := net.ParseIP(ipAddress).To4()
+	gateway := net.ParseIP(n.gateway).To4()
+	netmask := net.ParseIP(n.netmask).To4()
+	if ip == nil || gateway == nil || netmask == nil {
+		return nil
+	}
+	mask := net.IPMask(netmask)
+	if !ip.Mask(mask).Equal(gateway.Mask(mask)) {
+		return fmt.Errorf("ip address, %s, is not in the subnet of gateway %s and netmask %s", ipAddress, n.gateway, n.netmask)
+	}
+	if ip.Equal(gateway) {
+		return fmt.Errorf("ip address, %s, is the gateway of the network", ipAddress)
+	}
+	return nil
+}
+
+func (n VirtualMachineNicSpec) ipAddressMode() string {
+	if n.IPAddressMode == "" {
+		return IPAddressModeDHCP
+	}
+	return n.IPAddressMode
+}
+
+func (s VirtualMachineSpec) params() buildVirtualMachineParams {
+	params := buildVirtualMachineParams{
+		Name:               s.Name,
+		Description:        s.Description,
+		OperatingSystem:    s.OperatingSystem,
+		VCPU:               s.VCPU,
+		CoresPerSocket:     s.CoresPerSocket,
+		MemoryMB:           s.MemoryMB,
+		HardwareVersion:    s.HardwareVersion,
+		StorageProfileUUID: s.StorageProfileUUID,
+		Disks:              []buildDiskParams{},
+		Nics:               []buildNicParams{},
+		BootMediaUUID:      s.BootMediaUUID,
+	}
+	for _, disk := range s.Disks {
+		params.Disks = append(params.Disks, buildDiskParams{
+			Name:        disk.Name,
+			Size:        disk.SizeMB,
+			AdapterType: disk.AdapterType,
+		})
+	}
+	hasPrimary := false
+	for _, nic := range s.Nics {
+		hasPrimary = hasPrimary || nic.Primary
+	}
+	for i, nic := range s.Nics {
+		params.Nics = append(params.Nics, buildNicParams{
+			Index:            i,
+			NetworkName:      nic.NetworkName,
+			IPAllocationMode: nic.ipAddressMode(),
+			IPAddress:        nic.IPAddress,
+			AdapterType:      nic.AdapterType,
+			Primary:          nic.Primary || (!hasPrimary && i == 0),
+			Connected:        !nic.Disconnected,
+		})
+	}
+	return params
+}
+
+// validateIPAddressMode checks an IP address mode and the address it
+// requires.
+func validateIPAddressMode(mode, ipAddress string) error {
+	switch mode {
+	case IPAddressModeDHCP, IPAddressModePool, IPAddressModeNone:
+		if ipAddress != "" {
+			return fmt.Errorf("ip address, %s, requires ip address mode %s", ipAddress, IPAddressModeManual)
+		}
+	case IPAddressModeManual:
+		if net.ParseIP(ipAddress) == nil {
+			return fmt.Errorf("invalid ip address, %q", ipAddress)
+		}
+	default:
+		return fmt.Errorf("invalid ip address mode, %q", mode)
+	}
+	return nil
+}
+
+// AddVirtualMachine builds a blank VM in the vApp. The spec is validated,
+// including that its nics connect to networks of the vApp and that their
+// manual addresses are in the networks' subnets, before adding it.
+func (v VApp) AddVirtualMachine(spec VirtualMachineSpec) (Task, error) {
+	return v.AddVirtualMachineContext(context.Background(), spec)
+}
+
+func (v VApp) AddVirtualMachineContext(ctx context.Context, spec VirtualMachineSpec) (Task, error) {
 	task := Task{}
-	data, err := v.client.Delete(fmt.Sprintf("/vapp/%s/network/%s", v.UUID, vAppNetworkUUID))
+	if err := spec.validate(); err != nil {
+		return task, err
+	}
+	if err := v.client.waitUntilObjectIsReady(ctx, v.LocationID, v.UUID); err != nil {
+		return task, err
+	}
+	if len(spec.Nics) > 0 {
+		networks, err := v.GetVAppNetworksContext(ctx)
+		if err != nil {
+			return task, err
+		}
+		subnets := map[string]subnet{}
+		for _, network := range networks {
+			subnets[network.Name] = subnet{gateway: network.Gateway, netmask: network.Netmask}
+		}
+		if err := spec.checkNetworks(subnets); err != nil {
+			return task, err
+		}
+	}
+	output, _ := json.Marshal(spec.params())
+	data, err := v.client.PostContext(ctx, fmt.Sprintf("/vapp/%s/build-vm", v.UUID), output)
 	if err != nil {
 		return task, err
 	}
@@ -240,10 +632,31 @@ func (v VApp) RemoveNetwork(vAppNetworkUUID string) (Task, error) {
 	return task, err
 }
 
+// AddVirtualMachineFromVAppTemplateParams describes a VM copied from a VM of
+// a vApp template. The fields after SourceVirtualMachineUUID are optional.
 type AddVirtualMachineFromVAppTemplateParams struct {
 	NewVirtualMachineName    string
 	SourceVAppTemplateUUID   string
 	SourceVirtualMachineUUID string
+	// NetworkUUID is the vApp network the VM connects to, the first network
+	// of the vApp by default. The VM is not connected if the vApp has none.
+	NetworkUUID string
+	// IPAddressMode is one of the IPAddressMode constants, DHCP by default,
+	// or NONE if the VM is not connected.
+	IPAddressMode string
+	// IPAddress is the address of the VM, required by MANUAL mode only. It
+	// must be in the subnet of the network.
+	IPAddress string
+	// StorageProfileUUID defaults to the storage profile of the template.
+	StorageProfileUUID string
+	// VCPU, CoresPerSocket and MemoryMB override the sizing of the template.
+	// CoresPerSocket must divide VCPU when both are set.
+	VCPU           int
+	CoresPerSocket int
+	MemoryMB       int
+	// ComputerName is the hostname set by guest customization, up to 63
+	// letters, digits and hyphens.
+	ComputerName string
 }
 
 type addVirtualMachinesFromVAppTemplateParams struct {
@@ -253,26 +666,113 @@ type addVirtualMachinesFromVAppTemplateParams struct {
 	IPAddressMode            string `json:"ip_address_mode"`
 	NetworkUUID              string `json:"network_uuid"`
 	IPAddress                string `json:"ip_address"`
+	StorageProfileUUID       string `json:"storage_profile_uuid,omitempty"`
+	VCPU                     int    `json:"cpus_number,omitempty"`
+	CoresPerSocket           int    `json:"cores_per_socket,omitempty"`
+	MemoryMB                 int    `json:"memory_size,omitempty"`
+	ComputerName             string `json:"computer_name,omitempty"`
+}
+
+var computerNamePattern = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`)
+
+// validate checks the params that don't depend on the vApp.
+func (p AddVirtualMachineFromVAppTemplateParams) validate() error {
+	if p.NewVirtualMachineName == "" {
+		return errors.New("virtual machine name is required")
+	}
+	if p.SourceVAppTemplateUUID == "" || p.SourceVirtualMachineUUID == "" {
+		return fmt.Errorf("virtual machine, %s, requires a source vApp template and virtual machine", p.NewVirtualMachineName)
+	}
+	if p.IPAddressMode != "" || p.IPAddress != "" {
+		mode := p.IPAddressMode
+		if mode == "" {
+			mode = IPAddressModeDHCP
+		}
+		if err := validateIPAddressMode(mode, p.IPAddress); err != nil {
+			return fmt.Errorf("virtual machine, %s: %w", p.NewVirtualMachineName, err)
+		}
+	}
+	if p.VCPU < 0 || p.CoresPerSocket < 0 || p.MemoryMB < 0 {
+		return fmt.Errorf("virtual machine, %s, has negative sizing", p.NewVirtualMachineName)
+	}
+	if p.VCPU > 0 && p.CoresPerSocket > 0 && p.VCPU%p.CoresPerSocket != 0 {
+		return fmt.Errorf("virtual machine, %s, has %d cpus which can't be split in sockets of %d cores", p.NewVirtualMachineName, p.VCPU, p.CoresPerSocket)
+	}
+	if p.ComputerName != "" && !computerNamePattern.MatchString(p.ComputerName) {
+		return fmt.Errorf("invalid computer name, %q, for virtual machine, %s", p.ComputerName, p.NewVirtualMachineName)
+	}
+	return nil
 }
 
 func (v VApp) AddVirtualMachinesFromVAppTemplates(params []AddVirtualMachineFromVAppTemplateParams) (Task, error) {
-	v.client.waitUntilObjectIsReady(v.LocationID, v.UUID)
+	return v.AddVirtualMachinesFromVAppTemplatesContext(context.Background(), params)
+}
+
+// AddVirtualMachinesFromVAppTemplatesContext validates the params, including
+// that their networks belong to the vApp, before adding any VM.
+func (v VApp) AddVirtualMachinesFromVAppTemplatesContext(ctx context.Context, params []AddVirtualMachineFromVAppTemplateParams) (Task, error) {
+	task := Task{}
+	if len(params) == 0 {
+		return task, errors.New("no virtual machine to add")
+	}
+	for _, param := range params {
+		if err := param.validate(); err != nil {
+			return task, err
+		}
+	}
+	if err := v.client.waitUntilObjectIsReady(ctx, v.LocationID, v.UUID); err != nil {
+		return task, err
+	}
+	networks, err := v.GetVAppNetworksContext(ctx)
+	if err != nil {
+		return task, err
+	}
+	subnets := map[string]subnet{}
+	for _, network := range networks {
+		subnets[network.UUID] = subnet{gateway: network.Gateway, netmask: network.Netmask}
+	}
 	virtualMachineParams := []addVirtualMachinesFromVAppTemplateParams{}
-	networks := v.GetVAppNetworks()
 	for _, param := range params {
+		networkUUID := param.NetworkUUID
+		if networkUUID == "" && len(networks) > 0 {
+			networkUUID = networks[0].UUID
+		}
+		network, ok := subnets[networkUUID]
+		if networkUUID != "" && !ok {
+			return task, fmt.Errorf("network with UUID, %s, does not belong to vApp, %s", networkUUID, v.UUID)
+		}
+		ipAddressMode := param.IPAddressMode
+		if ipAddressMode == "" {
+			ipAddressMode = IPAddressModeDHCP
+			if networkUUID == "" {
+				ipAddressMode = IPAddressModeNone
+			}
+		}
+		if networkUUID == "" && ipAddressMode != IPAddressModeNone {
+			return task, fmt.Errorf("virtual machine, %s, requires a network for ip address mode %s, vApp, %s, has none", param.NewVirtualMachineName, ipAddressMode, v.UUID)
+		}
+		if ipAddressMode == IPAddressModeManual {
+			if err := network.check(param.IPAddress); err != nil {
+				return task, fmt.Errorf("virtual machine, %s: %w", param.NewVirtualMachineName, err)
+			}
+		}
 		virtualMachineParam := addVirtualMachinesFromVAppTemplateParams{
 			NewVirtualMachineName:    param.NewVirtualMachineName,
 			SourceVAppTemplateUUID:   param.SourceVAppTemplateUUID,
 			SourceVirtualMachineUUID: param.SourceVirtualMachineUUID,
-			IPAddressMode:            "DHCP",
-			NetworkUUID:              networks[0].UUID,
-			IPAddress:                "",
+			IPAddressMode:            ipAddressMode,
+			NetworkUUID:              networkUUID,
+			IPAddress:                param.IPAddress,
+			StorageProfileUUID:       param.StorageProfileUUID,
+			VCPU:                     param.VCPU,
+			CoresPerSocket:           param.CoresPerSocket,
+			MemoryMB:                 param.MemoryMB,
+			ComputerName:             param.ComputerName,
 		}
 		virtualMachineParams = append(virtualMachineParams, virtualMachineParam)
 	}
-	task := Task{}
 	output, _ := json.Marshal(&virtualMachineParams)
-	data, err := v.client.Post(fmt.Sprintf("/vapp/%s/vms", v.UUID), output)
+	data, err := v.client.PostContext(ctx, fmt.Sprintf("/vapp/%s/vms", v.UUID), output)
 	if err != nil {
 		return task, err
 	}
@@ -282,10 +782,14 @@ func (v VApp) AddVirtualMachinesFromVAppTemplates(params []AddVirtualMachineFrom
 }
 
 func (v VApp) GetPerformance(start, end time.Time, perfInterval string, metric PerfMetric) (PerfResults, error) {
+	return v.GetPerformanceContext(context.Background(), start, end, perfInterval, metric)
+}
+
+func (v VApp) GetPerformanceContext(ctx context.Context, start, end time.Time, perfInterval string, metric PerfMetric) (PerfResults, error) {
 	results := PerfResults{}
 	limit := getPerfLimit(perfInterval)
 	queryParams := fmt.Sprintf("?group=%s&name=%s&type=%s&start=%d&end=%d&interval=%s&limit=%s", metric.Group, metric.Name, metric.Type, getUnixMilliseconds(start), getUnixMilliseconds(end), perfInterval, limit)
-	data, err := v.client.Get(fmt.Sprintf("/vapp/%s/p%s", v.UUID, queryParams))
+	data, err := v.client.GetContext(ctx, fmt.Sprintf("/vapp/%s/p%s", v.UUID, queryParams))
 	if err != nil {
 		return results, err
 	}
@@ -294,8 +798,12 @@ func (v VApp) GetPerformance(start, end time.Time, perfInterval string, metric P
 }
 
 func (v VApp) GetCurrentBill() (BillingSummary, error) {
+	return v.GetCurrentBillContext(context.Background())
+}
+
+func (v VApp) GetCurrentBillContext(ctx context.Context) (BillingSummary, error) {
 	billing := BillingSummary{}
-	data, err := v.client.Get(fmt.Sprintf("/vapp/%s/bill", v.UUID))
+	data, err := v.client.GetContext(ctx, fmt.Sprintf("/vapp/%s/bill", v.UUID))
 	if err != nil {
 		return billing, err
 	}
@@ -304,9 +812,13 @@ func (v VApp) GetCurrentBill() (BillingSummary, error) {
 }
 
 func (v VApp) GetPrevBill(month, year int) (BillingSummary, error) {
+	return v.GetPrevBillContext(context.Background(), month, year)
+}
+
+func (v VApp) GetPrevBillContext(ctx context.Context, month, year int) (BillingSummary, error) {
 	billing := BillingSummary{}
 	queryParams := fmt.Sprintf("?month=%d&year=%d", month, year)
-	data, err := v.client.Get(fmt.Sprintf("/vapp/%s/bill%s", v.UUID, queryParams))
+	data, err := v.client.GetContext(ctx, fmt.Sprintf("/vapp/%s/bill%s", v.UUID, queryParams))
 	if err != nil {
 		return billing, err
 	}
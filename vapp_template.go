@@ -1,6 +1,7 @@
 package iland
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 )
@@ -34,10 +35,26 @@ type VAppTemplateVirtualMachine struct {
 	SizeGB           int    `json:"size_gb"`
 }
 
+// IsBusy reports whether the vApp template has active tasks, in which case the
+// methods that modify it wait or fail, see ReadinessPolicy.
+func (v VAppTemplate) IsBusy() (bool, error) {
+	return v.IsBusyContext(context.Background())
+}
+
+func (v VAppTemplate) IsBusyContext(ctx context.Context) (bool, error) {
+	return v.client.isBusy(ctx, v.LocationID, v.UUID)
+}
+
 func (v VAppTemplate) Delete() (Task, error) {
-	v.client.waitUntilObjectIsReady(v.LocationID, v.UUID)
+	return v.DeleteContext(context.Background())
+}
+
+func (v VAppTemplate) DeleteContext(ctx context.Context) (Task, error) {
+	if err := v.client.waitUntilObjectIsReady(ctx, v.LocationID, v.UUID); err != nil {
+		return Task{}, err
+	}
 	task := Task{}
-	data, err := v.client.Delete(fmt.Sprintf("/vapp-template/%s", v.UUID))
+	data, err := v.client.DeleteContext(ctx, fmt.Sprintf("/vapp-template/%s", v.UUID))
 	if err != nil {
 		return task, err
 	}
@@ -46,21 +63,37 @@ func (v VAppTemplate) Delete() (Task, error) {
 	return task, err
 }
 
-func (v VAppTemplate) GetVirtualMachines() []VAppTemplateVirtualMachine {
+func (v VAppTemplate) GetVirtualMachines() ([]VAppTemplateVirtualMachine, error) {
+	return v.GetVirtualMachinesContext(context.Background())
+}
+
+func (v VAppTemplate) GetVirtualMachinesContext(ctx context.Context) ([]VAppTemplateVirtualMachine, error) {
 	virtualMachines := []VAppTemplateVirtualMachine{}
-	data, _ := v.client.Get(fmt.Sprintf("/vapp-template/%s/vms", v.UUID))
-	json.Unmarshal(data, &virtualMachines)
+	data, err := v.client.GetContext(ctx, fmt.Sprintf("/vapp-template/%s/vms", v.UUID))
+	if err != nil {
+		return virtualMachines, err
+	}
+	err = json.Unmarshal(data, &virtualMachines)
+	if err != nil {
+		return virtualMachines, err
+	}
 	for i, virtualMachine := range virtualMachines {
 		virtualMachine.LocationID = v.LocationID
 		virtualMachine.VdcUUID = v.VdcUUID
 		virtualMachine.VAppTemplateUUID = v.UUID
 		virtualMachines[i] = virtualMachine
 	}
-	return virtualMachines
+	return virtualMachines, nil
 }
 
 func (v VAppTemplate) Deploy(vdcUUID, NewVAppName string) (Task, error) {
-	v.client.waitUntilObjectIsReady(v.LocationID, v.UUID)
+	return v.DeployContext(context.Background(), vdcUUID, NewVAppName)
+}
+
+func (v VAppTemplate) DeployContext(ctx context.Context, vdcUUID, NewVAppName string) (Task, error) {
+	if err := v.client.waitUntilObjectIsReady(ctx, v.LocationID, v.UUID); err != nil {
+		return Task{}, err
+	}
 	task := Task{}
 	params := struct {
 		VAppTemplateUUID string `json:"vapp_template_uuid"`
@@ -69,12 +102,12 @@ func (v VAppTemplate) Deploy(vdcUUID, NewVAppName string) (Task, error) {
 		VAppTemplateUUID: v.UUID,
 		Name:             NewVAppName,
 	}
-	vdc, err := v.client.GetVdc(vdcUUID)
+	vdc, err := v.client.GetVdcContext(ctx, vdcUUID)
 	if err != nil {
 		return task, err
 	}
 	output, _ := json.Marshal(&params)
-	data, err := v.client.Post(fmt.Sprintf("/vdc/%s/vapp", vdc.UUID), output)
+	data, err := v.client.PostContext(ctx, fmt.Sprintf("/vdc/%s/vapp", vdc.UUID), output)
 	if err != nil {
 		return task, err
 	}
@@ -84,7 +117,13 @@ func (v VAppTemplate) Deploy(vdcUUID, NewVAppName string) (Task, error) {
 }
 
 func (v VAppTemplate) Rename(newVAppTemplateName string) (Task, error) {
-	v.client.waitUntilObjectIsReady(v.LocationID, v.UUID)
+	return v.RenameContext(context.Background(), newVAppTemplateName)
+}
+
+func (v VAppTemplate) RenameContext(ctx context.Context, newVAppTemplateName string) (Task, error) {
+	if err := v.client.waitUntilObjectIsReady(ctx, v.LocationID, v.UUID); err != nil {
+		return Task{}, err
+	}
 	task := Task{}
 	params := struct {
 		Name string `json:"name"`
@@ -92,7 +131,7 @@ func (v VAppTemplate) Rename(newVAppTemplateName string) (Task, error) {
 		Name: newVAppTemplateName,
 	}
 	output, _ := json.Marshal(&params)
-	data, err := v.client.Put(fmt.Sprintf("/vapp/%s", v.UUID), output)
+	data, err := v.client.PutContext(ctx, fmt.Sprintf("/vapp/%s", v.UUID), output)
 	if err != nil {
 		return task, err
 	}
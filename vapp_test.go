@@ -0,0 +1,264 @@
+package iland
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const fakeVAppNetworks = `[
+	{"uuid":"n1","name":"web","gateway":"10.1.1.254","netmask":"255.255.255.0"},
+	{"uuid":"n2","name":"db","gateway":"192.168.0.1","netmask":"255.255.0.0"}
+]`
+
+// fakeVApp serves the networks of vApps that have no active tasks, and
+// records the body of the other POST requests.
+type fakeVApp struct {
+	mu       sync.Mutex
+	networks string
+	posts    []string
+}
+
+func (f *fakeVApp) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.Method == "POST":
+		body, _ := io.ReadAll(r.Body)
+		f.posts = append(f.posts, r.URL.Path+" "+string(body))
+		fmt.Fprint(w, `{"uuid":"task","location_id":"l"}`)
+	case strings.HasSuffix(r.URL.Path, "/networks"):
+		fmt.Fprint(w, f.networks)
+	default:
+		fmt.Fprint(w, `[]`)
+	}
+}
+
+func TestAddVirtualMachinesFromVAppTemplates(t *testing.T) {
+	tests := []struct {
+		name     string
+		networks string
+		params   AddVirtualMachineFromVAppTemplateParams
+		// want is the VM sent to the API, or nil if the params are invalid,
+		// in which case the error contains wantErr.
+		want    *addVirtualMachinesFromVAppTemplateParams
+		wantErr string
+	}{
+		{
+			name:     "no network",
+			networks: `[]`,
+			want:     &addVirtualMachinesFromVAppTemplateParams{IPAddressMode: IPAddressModeNone},
+		},
+		{
+			name:     "no network with dhcp",
+			networks: `[]`,
+			params:   AddVirtualMachineFromVAppTemplateParams{IPAddressMode: IPAddressModeDHCP},
+			wantErr:  "requires a network",
+		},
+		{
+			name:     "default network",
+			networks: fakeVAppNetworks,
+			want:     &addVirtualMachinesFromVAppTemplateParams{IPAddressMode: IPAddressModeDHCP, NetworkUUID: "n1"},
+		},
+		{
+			name:     "pool",
+			networks: fakeVAppNetworks,
+			params:   AddVirtualMachineFromVAppTemplateParams{NetworkUUID: "n2", IPAddressMode: IPAddressModePool},
+			want:     &addVirtualMachinesFromVAppTemplateParams{IPAddressMode: IPAddressModePool, NetworkUUID: "n2"},
+		},
+		{
+			name:     "manual",
+			networks: fakeVAppNetworks,
+			params:   AddVirtualMachineFromVAppTemplateParams{NetworkUUID: "n2", IPAddressMode: IPAddressModeManual, IPAddress: "192.168.7.10"},
+			want:     &addVirtualMachinesFromVAppTemplateParams{IPAddressMode: IPAddressModeManual, NetworkUUID: "n2", IPAddress: "192.168.7.10"},
+		},
+		{
+			name:     "manual without ip",
+			networks: fakeVAppNetworks,
+			params:   AddVirtualMachineFromVAppTemplateParams{IPAddressMode: IPAddressModeManual},
+			wantErr:  "invalid ip address",
+		},
+		{
+			name:     "manual outside subnet",
+			networks: fakeVAppNetworks,
+			params:   AddVirtualMachineFromVAppTemplateParams{IPAddressMode: IPAddressModeManual, IPAddress: "10.1.2.10"},
+			wantErr:  "not in the subnet",
+		},
+		{
+			name:     "manual gateway",
+			networks: fakeVAppNetworks,
+			params:   AddVirtualMachineFromVAppTemplateParams{IPAddressMode: IPAddressModeManual, IPAddress: "10.1.1.254"},
+			wantErr:  "is the gateway",
+		},
+		{
+			name:     "pool with ip",
+			networks: fakeVAppNetworks,
+			params:   AddVirtualMachineFromVAppTemplateParams{IPAddressMode: IPAddressModePool, IPAddress: "10.1.1.10"},
+			wantErr:  "requires ip address mode MANUAL",
+		},
+		{
+			name:     "dhcp by default with ip",
+			networks: fakeVAppNetworks,
+			params:   AddVirtualMachineFromVAppTemplateParams{IPAddress: "10.1.1.10"},
+			wantErr:  "requires ip address mode MANUAL",
+		},
+		{
+			name:     "unknown mode",
+			networks: fakeVAppNetworks,
+			params:   AddVirtualMachineFromVAppTemplateParams{IPAddressMode: "STATIC"},
+			wantErr:  "invalid ip address mode",
+		},
+		{
+			name:     "unknown network",
+			networks: fakeVAppNetworks,
+			params:   AddVirtualMachineFromVAppTemplateParams{NetworkUUID: "n3"},
+			wantErr:  "does not belong to vApp",
+		},
+		{
+			name:     "sizing",
+			networks: `[]`,
+			params:   AddVirtualMachineFromVAppTemplateParams{VCPU: 4, CoresPerSocket: 2, MemoryMB: 8192, ComputerName: "web-01"},
+			want:     &addVirtualMachinesFromVAppTemplateParams{IPAddressMode: IPAddressModeNone, VCPU: 4, CoresPerSocket: 2, MemoryMB: 8192, ComputerName: "web-01"},
+		},
+		{
+			name:     "negative cpu",
+			networks: `[]`,
+			params:   AddVirtualMachineFromVAppTemplateParams{VCPU: -1},
+			wantErr:  "negative sizing",
+		},
+		{
+			name:     "negative memory",
+			networks: `[]`,
+			params:   AddVirtualMachineFromVAppTemplateParams{MemoryMB: -512},
+			wantErr:  "negative sizing",
+		},
+		{
+			name:     "negative cores per socket",
+			networks: `[]`,
+			params:   AddVirtualMachineFromVAppTemplateParams{CoresPerSocket: -2},
+			wantErr:  "negative sizing",
+		},
+		{
+			name:     "cores per socket",
+			networks: `[]`,
+			params:   AddVirtualMachineFromVAppTemplateParams{VCPU: 3, CoresPerSocket: 2},
+			wantErr:  "can't be split",
+		},
+		{
+			name:     "computer name",
+			networks: `[]`,
+			params:   AddVirtualMachineFromVAppTemplateParams{ComputerName: "web_01"},
+			wantErr:  "invalid computer name",
+		},
+	}
+	for _, test := range tests {
+		fake := &fakeVApp{networks: test.networks}
+		client := newTestClient(t, fake)
+		params := test.params
+		params.NewVirtualMachineName = "vm"
+		params.SourceVAppTemplateUUID = "template"
+		params.SourceVirtualMachineUUID = "source"
+		_, err := VApp{client: client, UUID: "app", LocationID: "l"}.AddVirtualMachinesFromVAppTemplates([]AddVirtualMachineFromVAppTemplateParams{params})
+		if test.want == nil {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%s: err = %v, want one containing %q", test.name, err, test.wantErr)
+			}
+			if len(fake.posts) != 0 {
+				t.Errorf("%s: sent %v, want nothing sent", test.name, fake.posts)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: err = %v", test.name, err)
+			continue
+		}
+		want := *test.want
+		want.NewVirtualMachineName = "vm"
+		want.SourceVAppTemplateUUID = "template"
+		want.SourceVirtualMachineUUID = "source"
+		sent := []addVirtualMachinesFromVAppTemplateParams{}
+		if len(fake.posts) != 1 {
+			t.Fatalf("%s: sent %v, want one request", test.name, fake.posts)
+		}
+		path, body, _ := strings.Cut(fake.posts[0], " ")
+		if path != "/vapp/app/vms" {
+			t.Errorf("%s: sent to %s, want /vapp/app/vms", test.name, path)
+		}
+		if err := json.Unmarshal([]byte(body), &sent); err != nil {
+			t.Fatal(err)
+		}
+		if len(sent) != 1 || !reflect.DeepEqual(sent[0], want) {
+			t.Errorf("%s: sent %+v, want %+v", test.name, sent, want)
+		}
+		if test.params.VCPU == 0 && strings.Contains(body, "cpus_number") {
+			t.Errorf("%s: sent %s, want the template's sizing kept", test.name, body)
+		}
+	}
+}
+
+func TestAddVirtualMachinesFromVAppTemplatesChecksEveryParam(t *testing.T) {
+	fake := &fakeVApp{networks: fakeVAppNetworks}
+	client := newTestClient(t, fake)
+	vApp := VApp{client: client, UUID: "app", LocationID: "l"}
+	if _, err := vApp.AddVirtualMachinesFromVAppTemplates(nil); err == nil {
+		t.Error("no error for an empty list of VMs")
+	}
+	valid := AddVirtualMachineFromVAppTemplateParams{NewVirtualMachineName: "a", SourceVAppTemplateUUID: "template", SourceVirtualMachineUUID: "source"}
+	invalid := valid
+	invalid.NewVirtualMachineName = "b"
+	invalid.NetworkUUID = "n3"
+	if _, err := vApp.AddVirtualMachinesFromVAppTemplates([]AddVirtualMachineFromVAppTemplateParams{valid, invalid}); err == nil {
+		t.Error("no error for the invalid second VM")
+	}
+	missingSource := valid
+	missingSource.SourceVirtualMachineUUID = ""
+	if _, err := vApp.AddVirtualMachinesFromVAppTemplates([]AddVirtualMachineFromVAppTemplateParams{valid, missingSource}); err == nil {
+		t.Error("no error for a VM without source")
+	}
+	if len(fake.posts) != 0 {
+		t.Errorf("sent %v, want nothing sent", fake.posts)
+	}
+}
+
+func TestAddVirtualMachine(t *testing.T) {
+	tests := []struct {
+		name     string
+		networks string
+		change   func(spec *VirtualMachineSpec)
+		wantErr  string
+	}{
+		{"valid", fakeVAppNetworks, func(spec *VirtualMachineSpec) {}, ""},
+		{"without nics", `[]`, func(spec *VirtualMachineSpec) { spec.Nics = nil }, ""},
+		{"invalid spec", fakeVAppNetworks, func(spec *VirtualMachineSpec) { spec.MemoryMB = 0 }, "requires memory"},
+		{"nic on unknown network", fakeVAppNetworks, func(spec *VirtualMachineSpec) { spec.Nics[0].NetworkName = "backup" }, "which is not a network of the vApp"},
+		{"vApp without networks", `[]`, func(spec *VirtualMachineSpec) {}, "which is not a network of the vApp"},
+		{"manual outside subnet", fakeVAppNetworks, func(spec *VirtualMachineSpec) { spec.Nics[1].IPAddress = "10.1.1.20" }, "not in the subnet"},
+	}
+	for _, test := range tests {
+		fake := &fakeVApp{networks: test.networks}
+		client := newTestClient(t, fake)
+		spec := validVAppParams().VMs[0]
+		test.change(&spec)
+		_, err := VApp{client: client, UUID: "app", LocationID: "l"}.AddVirtualMachine(spec)
+		if test.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: err = %v", test.name, err)
+			}
+			if len(fake.posts) != 1 || !strings.HasPrefix(fake.posts[0], "/vapp/app/build-vm ") {
+				t.Errorf("%s: sent %v, want one request to /vapp/app/build-vm", test.name, fake.posts)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("%s: err = %v, want one containing %q", test.name, err, test.wantErr)
+		}
+		if len(fake.posts) != 0 {
+			t.Errorf("%s: sent %v, want nothing sent", test.name, fake.posts)
+		}
+	}
+}
@@ -1,7 +1,9 @@
 package iland
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 )
@@ -27,61 +29,197 @@ type Vdc struct {
 	UpdatedDate        int    `json:"updated_date"`
 }
 
-func (v Vdc) GetEdges() []Edge {
+// CreateVAppParams describes an empty vApp, or one composed of blank VMs,
+// built without a vApp template.
+type CreateVAppParams struct {
+	Name        string
+	Description string
+	// Networks are the vApp networks the nics of the VMs connect to by name.
+	Networks []AddVAppNetworkParams
+	VMs      []VirtualMachineSpec
+}
+
+type buildVAppParams struct {
+	Name        string                      `json:"name"`
+	Description string                      `json:"description"`
+	Networks    []AddVAppNetworkParams      `json:"networks"`
+	VMs         []buildVirtualMachineParams `json:"vms"`
+}
+
+func (p CreateVAppParams) validate() error {
+	if p.Name == "" {
+		return errors.New("vApp name is required")
+	}
+	subnets := map[string]subnet{}
+	for _, network := range p.Networks {
+		if network.Name == "" {
+			return errors.New("vApp network name is required")
+		}
+		if _, ok := subnets[network.Name]; ok {
+			return fmt.Errorf("vApp network name, %s, is used more than once", network.Name)
+		}
+		subnets[network.Name] = subnet{gateway: network.Gateway, netmask: network.Netmask}
+		if err := network.validate(); err != nil {
+			return fmt.Errorf("vApp network, %s: %w", network.Name, err)
+		}
+	}
+	vmNames := map[string]bool{}
+	for _, vm := range p.VMs {
+		if err := vm.validate(); err != nil {
+			return err
+		}
+		if vmNames[vm.Name] {
+			return fmt.Errorf("virtual machine name, %s, is used more than once", vm.Name)
+		}
+		vmNames[vm.Name] = true
+		if err := vm.checkNetworks(subnets); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateVApp builds a new vApp in the vdc. The params are validated,
+// including that the nics of the VMs connect to the vApp's networks and that
+// their manual addresses are in the networks' subnets, before creating it.
+func (v Vdc) CreateVApp(params CreateVAppParams) (Task, error) {
+	return v.CreateVAppContext(context.Background(), params)
+}
+
+func (v Vdc) CreateVAppContext(ctx context.Context, params CreateVAppParams) (Task, error) {
+	task := Task{}
+	if err := params.validate(); err != nil {
+		return task, err
+	}
+	buildParams := buildVAppParams{
+		Name:        params.Name,
+		Description: params.Description,
+		Networks:    []AddVAppNetworkParams{},
+		VMs:         []buildVirtualMachineParams{},
+	}
+	buildParams.Networks = append(buildParams.Networks, params.Networks...)
+	for _, vm := range params.VMs {
+		buildParams.VMs = append(buildParams.VMs, vm.params())
+	}
+	output, _ := json.Marshal(&buildParams)
+	data, err := v.client.PostContext(ctx, fmt.Sprintf("/vdc/%s/build-vapp", v.UUID), output)
+	if err != nil {
+		return task, err
+	}
+	err = json.Unmarshal(data, &task)
+	task.client = v.client
+	return task, err
+}
+
+func (v Vdc) GetEdges() ([]Edge, error) {
+	return v.GetEdgesContext(context.Background())
+}
+
+func (v Vdc) GetEdgesContext(ctx context.Context) ([]Edge, error) {
 	edges := []Edge{}
-	data, _ := v.client.Get(fmt.Sprintf("/vdc/%s/edges", v.UUID))
-	json.Unmarshal(data, &edges)
+	data, err := v.client.GetContext(ctx, fmt.Sprintf("/vdc/%s/edges", v.UUID))
+	if err != nil {
+		return edges, err
+	}
+	err = json.Unmarshal(data, &edges)
+	if err != nil {
+		return edges, err
+	}
 	for i, edge := range edges {
 		edge.client = v.client
 		edges[i] = edge
 	}
-	return edges
+	return edges, nil
 }
 
-func (v Vdc) GetStorageProfiles() []StorageProfile {
+func (v Vdc) GetStorageProfiles() ([]StorageProfile, error) {
+	return v.GetStorageProfilesContext(context.Background())
+}
+
+func (v Vdc) GetStorageProfilesContext(ctx context.Context) ([]StorageProfile, error) {
 	storageProfiles := []StorageProfile{}
-	data, _ := v.client.Get(fmt.Sprintf("/vdc/%s/storage-profiles", v.UUID))
-	json.Unmarshal([]byte(data), &storageProfiles)
-	return storageProfiles
+	data, err := v.client.GetContext(ctx, fmt.Sprintf("/vdc/%s/storage-profiles", v.UUID))
+	if err != nil {
+		return storageProfiles, err
+	}
+	err = json.Unmarshal(data, &storageProfiles)
+	if err != nil {
+		return storageProfiles, err
+	}
+	return storageProfiles, nil
 }
 
-func (v Vdc) GetVdcNetworks() []VdcNetwork {
+func (v Vdc) GetVdcNetworks() ([]VdcNetwork, error) {
+	return v.GetVdcNetworksContext(context.Background())
+}
+
+func (v Vdc) GetVdcNetworksContext(ctx context.Context) ([]VdcNetwork, error) {
 	vdcNetworks := []VdcNetwork{}
-	data, _ := v.client.Get(fmt.Sprintf("/vdc/%s/networks", v.UUID))
-	json.Unmarshal([]byte(data), &vdcNetworks)
+	data, err := v.client.GetContext(ctx, fmt.Sprintf("/vdc/%s/networks", v.UUID))
+	if err != nil {
+		return vdcNetworks, err
+	}
+	err = json.Unmarshal(data, &vdcNetworks)
+	if err != nil {
+		return vdcNetworks, err
+	}
 	for i, vdcNetwork := range vdcNetworks {
 		vdcNetwork.client = v.client
 		vdcNetworks[i] = vdcNetwork
 	}
-	return vdcNetworks
+	return vdcNetworks, nil
+}
+
+func (v Vdc) GetVApps() ([]VApp, error) {
+	return v.GetVAppsContext(context.Background())
 }
 
-func (v Vdc) GetVApps() []VApp {
+func (v Vdc) GetVAppsContext(ctx context.Context) ([]VApp, error) {
 	vApps := []VApp{}
-	data, _ := v.client.Get(fmt.Sprintf("/vdc/%s/vapps", v.UUID))
-	json.Unmarshal([]byte(data), &vApps)
+	data, err := v.client.GetContext(ctx, fmt.Sprintf("/vdc/%s/vapps", v.UUID))
+	if err != nil {
+		return vApps, err
+	}
+	err = json.Unmarshal(data, &vApps)
+	if err != nil {
+		return vApps, err
+	}
 	for i, vApp := range vApps {
 		vApp.client = v.client
 		vApps[i] = vApp
 	}
-	return vApps
+	return vApps, nil
+}
+
+func (v Vdc) GetVirtualMachines() ([]VirtualMachine, error) {
+	return v.GetVirtualMachinesContext(context.Background())
 }
 
-func (v Vdc) GetVirtualMachines() []VirtualMachine {
+func (v Vdc) GetVirtualMachinesContext(ctx context.Context) ([]VirtualMachine, error) {
 	virtualMachines := []VirtualMachine{}
-	data, _ := v.client.Get(fmt.Sprintf("/vdc/%s/vms", v.UUID))
-	json.Unmarshal([]byte(data), &virtualMachines)
+	data, err := v.client.GetContext(ctx, fmt.Sprintf("/vdc/%s/vms", v.UUID))
+	if err != nil {
+		return virtualMachines, err
+	}
+	err = json.Unmarshal(data, &virtualMachines)
+	if err != nil {
+		return virtualMachines, err
+	}
 	for i, virtualMachine := range virtualMachines {
 		virtualMachine.client = v.client
 		virtualMachines[i] = virtualMachine
 	}
-	return virtualMachines
+	return virtualMachines, nil
 }
 
 func (v Vdc) GetPerformance(start, end time.Time, perfInterval string, metric PerfMetric) (PerfResults, error) {
+	return v.GetPerformanceContext(context.Background(), start, end, perfInterval, metric)
+}
+
+func (v Vdc) GetPerformanceContext(ctx context.Context, start, end time.Time, perfInterval string, metric PerfMetric) (PerfResults, error) {
 	results := PerfResults{}
 	limit := getPerfLimit(perfInterval)
-	data, err := v.client.Get(fmt.Sprintf("/vdc/%s/p?group=%s&name=%s&type=%s&start=%d&end=%d&interval=%s&limit=%s", v.UUID, metric.Group, metric.Name, metric.Type, getUnixMilliseconds(start), getUnixMilliseconds(end), perfInterval, limit))
+	data, err := v.client.GetContext(ctx, fmt.Sprintf("/vdc/%s/p?group=%s&name=%s&type=%s&start=%d&end=%d&interval=%s&limit=%s", v.UUID, metric.Group, metric.Name, metric.Type, getUnixMilliseconds(start), getUnixMilliseconds(end), perfInterval, limit))
 	if err != nil {
 		return results, err
 	}
@@ -90,8 +228,12 @@ func (v Vdc) GetPerformance(start, end time.Time, perfInterval string, metric Pe
 }
 
 func (v Vdc) GetCurrentBill() (BillingSummary, error) {
+	return v.GetCurrentBillContext(context.Background())
+}
+
+func (v Vdc) GetCurrentBillContext(ctx context.Context) (BillingSummary, error) {
 	billing := BillingSummary{}
-	data, err := v.client.Get(fmt.Sprintf("/vdc/%s/bill", v.UUID))
+	data, err := v.client.GetContext(ctx, fmt.Sprintf("/vdc/%s/bill", v.UUID))
 	if err != nil {
 		return billing, err
 	}
@@ -100,8 +242,12 @@ func (v Vdc) GetCurrentBill() (BillingSummary, error) {
 }
 
 func (v Vdc) GetPrevBill(month, year int) (BillingSummary, error) {
+	return v.GetPrevBillContext(context.Background(), month, year)
+}
+
+func (v Vdc) GetPrevBillContext(ctx context.Context, month, year int) (BillingSummary, error) {
 	billing := BillingSummary{}
-	data, err := v.client.Get(fmt.Sprintf("/vdc/%s/bill?month=%d&year=%d", v.UUID, month, year))
+	data, err := v.client.GetContext(ctx, fmt.Sprintf("/vdc/%s/bill?month=%d&year=%d", v.UUID, month, year))
 	if err != nil {
 		return billing, err
 	}
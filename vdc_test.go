@@ -0,0 +1,133 @@
+package iland
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// validVAppParams returns a vApp with a web and a db network, and a VM on
+// each.
+func validVAppParams() CreateVAppParams {
+	return CreateVAppParams{
+		Name: "app",
+		Networks: []AddVAppNetworkParams{
+			{Name: "web", Gateway: "10.1.1.254", Netmask: "255.255.255.0"},
+			{Name: "db", Gateway: "192.168.0.1", Netmask: "255.255.0.0"},
+		},
+		VMs: []VirtualMachineSpec{
+			{
+				Name:            "web",
+				OperatingSystem: "ubuntu64Guest",
+				VCPU:            2,
+				MemoryMB:        2048,
+				Disks:           []VirtualMachineDiskSpec{{SizeMB: 20480}},
+				Nics: []VirtualMachineNicSpec{
+					{NetworkName: "web"},
+					{NetworkName: "db", IPAddressMode: IPAddressModeManual, IPAddress: "192.168.3.4", Disconnected: true},
+				},
+			},
+			{
+				Name:            "db",
+				OperatingSystem: "ubuntu64Guest",
+				VCPU:            4,
+				CoresPerSocket:  2,
+				MemoryMB:        8192,
+				Disks:           []VirtualMachineDiskSpec{{SizeMB: 20480}, {Name: "data", SizeMB: 102400, AdapterType: "paravirtual"}},
+				Nics:            []VirtualMachineNicSpec{{NetworkName: "db", IPAddressMode: IPAddressModePool, Primary: true}},
+			},
+		},
+	}
+}
+
+func TestCreateVAppValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(p *CreateVAppParams)
+		wantErr string
+	}{
+		{"valid", func(p *CreateVAppParams) {}, ""},
+		{"empty vApp", func(p *CreateVAppParams) { p.Networks, p.VMs = nil, nil }, ""},
+		{"no name", func(p *CreateVAppParams) { p.Name = "" }, "vApp name is required"},
+		{"network without name", func(p *CreateVAppParams) { p.Networks[1].Name = "" }, "vApp network name is required"},
+		{"duplicate network", func(p *CreateVAppParams) { p.Networks[1].Name = "web" }, "vApp network name, web, is used more than once"},
+		{"invalid gateway", func(p *CreateVAppParams) { p.Networks[0].Gateway = "10.1.1" }, "invalid gateway address"},
+		{"duplicate VM", func(p *CreateVAppParams) { p.VMs[1].Name = "web" }, "virtual machine name, web, is used more than once"},
+		{"VM without name", func(p *CreateVAppParams) { p.VMs[0].Name = "" }, "virtual machine name is required"},
+		{"no operating system", func(p *CreateVAppParams) { p.VMs[0].OperatingSystem = "" }, "has no operating system type"},
+		{"zero cpu", func(p *CreateVAppParams) { p.VMs[0].VCPU = 0 }, "requires at least one cpu"},
+		{"negative cpu", func(p *CreateVAppParams) { p.VMs[0].VCPU = -2 }, "requires at least one cpu"},
+		{"cores per socket", func(p *CreateVAppParams) { p.VMs[1].CoresPerSocket = 3 }, "can't be split in sockets of 3 cores"},
+		{"zero memory", func(p *CreateVAppParams) { p.VMs[0].MemoryMB = 0 }, "requires memory"},
+		{"zero disk", func(p *CreateVAppParams) { p.VMs[1].Disks[1].SizeMB = 0 }, "disk 1 of virtual machine, db, has no size"},
+		{"negative disk", func(p *CreateVAppParams) { p.VMs[0].Disks[0].SizeMB = -1 }, "disk 0 of virtual machine, web, has no size"},
+		{"nic without network", func(p *CreateVAppParams) { p.VMs[0].Nics[0].NetworkName = "" }, "nic 0 of virtual machine, web, has no network"},
+		{"nic on unknown network", func(p *CreateVAppParams) { p.VMs[0].Nics[1].NetworkName = "backup" }, "connects to network, backup, which is not a network of the vApp"},
+		{"manual without ip", func(p *CreateVAppParams) { p.VMs[0].Nics[1].IPAddress = "" }, "invalid ip address"},
+		{"manual outside subnet", func(p *CreateVAppParams) { p.VMs[0].Nics[1].IPAddress = "192.169.3.4" }, "not in the subnet"},
+		{"manual gateway", func(p *CreateVAppParams) { p.VMs[0].Nics[1].IPAddress = "192.168.0.1" }, "is the gateway"},
+		{"dhcp with ip", func(p *CreateVAppParams) { p.VMs[0].Nics[0].IPAddress = "10.1.1.4" }, "requires ip address mode MANUAL"},
+		{"unknown mode", func(p *CreateVAppParams) { p.VMs[1].Nics[0].IPAddressMode = "STATIC" }, "invalid ip address mode"},
+		{"two primary nics", func(p *CreateVAppParams) { p.VMs[0].Nics[0].Primary, p.VMs[0].Nics[1].Primary = true, true }, "more than one primary nic"},
+	}
+	for _, test := range tests {
+		fake := &fakeVApp{}
+		client := newTestClient(t, fake)
+		params := validVAppParams()
+		test.change(&params)
+		_, err := Vdc{client: client, UUID: "vdc"}.CreateVApp(params)
+		if test.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: err = %v", test.name, err)
+			}
+			if len(fake.posts) != 1 || !strings.HasPrefix(fake.posts[0], "/vdc/vdc/build-vapp ") {
+				t.Errorf("%s: sent %v, want one request to /vdc/vdc/build-vapp", test.name, fake.posts)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("%s: err = %v, want one containing %q", test.name, err, test.wantErr)
+		}
+		if len(fake.posts) != 0 {
+			t.Errorf("%s: sent %v, want nothing sent", test.name, fake.posts)
+		}
+	}
+}
+
+func TestCreateVAppParams(t *testing.T) {
+	fake := &fakeVApp{}
+	client := newTestClient(t, fake)
+	if _, err := (Vdc{client: client, UUID: "vdc"}).CreateVApp(validVAppParams()); err != nil {
+		t.Fatal(err)
+	}
+	_, body, _ := strings.Cut(fake.posts[0], " ")
+	sent := buildVAppParams{}
+	if err := json.Unmarshal([]byte(body), &sent); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent.Networks) != 2 || len(sent.VMs) != 2 {
+		t.Fatalf("sent %+v, want 2 networks and 2 VMs", sent)
+	}
+	wantNics := [][]buildNicParams{
+		{
+			{Index: 0, NetworkName: "web", IPAllocationMode: IPAddressModeDHCP, Primary: true, Connected: true},
+			{Index: 1, NetworkName: "db", IPAllocationMode: IPAddressModeManual, IPAddress: "192.168.3.4"},
+		},
+		{
+			{Index: 0, NetworkName: "db", IPAllocationMode: IPAddressModePool, Primary: true, Connected: true},
+		},
+	}
+	wantDisks := [][]buildDiskParams{
+		{{Size: 20480}},
+		{{Size: 20480}, {Name: "data", Size: 102400, AdapterType: "paravirtual"}},
+	}
+	for i, vm := range sent.VMs {
+		if !reflect.DeepEqual(vm.Nics, wantNics[i]) {
+			t.Errorf("nics of VM %d = %+v, want %+v", i, vm.Nics, wantNics[i])
+		}
+		if !reflect.DeepEqual(vm.Disks, wantDisks[i]) {
+			t.Errorf("disks of VM %d = %+v, want %+v", i, vm.Disks, wantDisks[i])
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package iland
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -47,18 +48,38 @@ type Nic struct {
 	NetworkName      string `json:"net_name"`
 }
 
-func (v VirtualMachine) GetDisks() []Disk {
+func (v VirtualMachine) GetDisks() ([]Disk, error) {
+	return v.GetDisksContext(context.Background())
+}
+
+func (v VirtualMachine) GetDisksContext(ctx context.Context) ([]Disk, error) {
 	disks := []Disk{}
-	data, _ := v.client.Get(fmt.Sprintf("/vm/%s/virtual-disks", v.UUID))
-	json.Unmarshal(data, &disks)
-	return disks
+	data, err := v.client.GetContext(ctx, fmt.Sprintf("/vm/%s/virtual-disks", v.UUID))
+	if err != nil {
+		return disks, err
+	}
+	err = json.Unmarshal(data, &disks)
+	if err != nil {
+		return disks, err
+	}
+	return disks, nil
+}
+
+func (v VirtualMachine) GetNics() ([]Nic, error) {
+	return v.GetNicsContext(context.Background())
 }
 
-func (v VirtualMachine) GetNics() []Nic {
+func (v VirtualMachine) GetNicsContext(ctx context.Context) ([]Nic, error) {
 	nics := []Nic{}
-	data, _ := v.client.Get(fmt.Sprintf("/vm/%s/vnics", v.UUID))
-	json.Unmarshal(data, &nics)
-	return nics
+	data, err := v.client.GetContext(ctx, fmt.Sprintf("/vm/%s/vnics", v.UUID))
+	if err != nil {
+		return nics, err
+	}
+	err = json.Unmarshal(data, &nics)
+	if err != nil {
+		return nics, err
+	}
+	return nics, nil
 }
 
 type VMwareTools struct {
@@ -67,11 +88,21 @@ type VMwareTools struct {
 	Version       string `json:"version"`
 }
 
-func (v VirtualMachine) GetTools() VMwareTools {
+func (v VirtualMachine) GetTools() (VMwareTools, error) {
+	return v.GetToolsContext(context.Background())
+}
+
+func (v VirtualMachine) GetToolsContext(ctx context.Context) (VMwareTools, error) {
 	tools := VMwareTools{}
-	data, _ := v.client.Get(fmt.Sprintf("/vm/%s/tools", v.UUID))
-	json.Unmarshal(data, &tools)
-	return tools
+	data, err := v.client.GetContext(ctx, fmt.Sprintf("/vm/%s/tools", v.UUID))
+	if err != nil {
+		return tools, err
+	}
+	err = json.Unmarshal(data, &tools)
+	if err != nil {
+		return tools, err
+	}
+	return tools, nil
 }
 
 type HotAddConfig struct {
@@ -79,22 +110,38 @@ type HotAddConfig struct {
 	MemoryHotAdd bool `json:"mem_hot_add_enabled"`
 }
 
-func (v VirtualMachine) GetHotAddConfig() HotAddConfig {
+func (v VirtualMachine) GetHotAddConfig() (HotAddConfig, error) {
+	return v.GetHotAddConfigContext(context.Background())
+}
+
+func (v VirtualMachine) GetHotAddConfigContext(ctx context.Context) (HotAddConfig, error) {
 	hotAdd := HotAddConfig{}
-	data, _ := v.client.Get(fmt.Sprintf("/vm/%s/capabilities", v.UUID))
-	json.Unmarshal(data, &hotAdd)
-	return hotAdd
+	data, err := v.client.GetContext(ctx, fmt.Sprintf("/vm/%s/capabilities", v.UUID))
+	if err != nil {
+		return hotAdd, err
+	}
+	err = json.Unmarshal(data, &hotAdd)
+	if err != nil {
+		return hotAdd, err
+	}
+	return hotAdd, nil
 }
 
 func (v VirtualMachine) SetHotAdd(cpuHotAdd, memoryHotAdd bool) (Task, error) {
-	v.client.waitUntilObjectIsReady(v.LocationID, v.UUID)
+	return v.SetHotAddContext(context.Background(), cpuHotAdd, memoryHotAdd)
+}
+
+func (v VirtualMachine) SetHotAddContext(ctx context.Context, cpuHotAdd, memoryHotAdd bool) (Task, error) {
+	if err := v.client.waitUntilObjectIsReady(ctx, v.LocationID, v.UUID); err != nil {
+		return Task{}, err
+	}
 	task := Task{}
 	hotAdd := HotAddConfig{
 		CPUHotAdd:    cpuHotAdd,
 		MemoryHotAdd: memoryHotAdd,
 	}
 	output, _ := json.Marshal(&hotAdd)
-	data, err := v.client.Put(fmt.Sprintf("/vm/%s/capabilities", v.UUID), output)
+	data, err := v.client.PutContext(ctx, fmt.Sprintf("/vm/%s/capabilities", v.UUID), output)
 	if err != nil {
 		return task, err
 	}
@@ -104,9 +151,15 @@ func (v VirtualMachine) SetHotAdd(cpuHotAdd, memoryHotAdd bool) (Task, error) {
 }
 
 func (v VirtualMachine) Delete() (Task, error) {
-	v.client.waitUntilObjectIsReady(v.LocationID, v.UUID)
+	return v.DeleteContext(context.Background())
+}
+
+func (v VirtualMachine) DeleteContext(ctx context.Context) (Task, error) {
+	if err := v.client.waitUntilObjectIsReady(ctx, v.LocationID, v.UUID); err != nil {
+		return Task{}, err
+	}
 	task := Task{}
-	data, err := v.client.Delete(fmt.Sprintf("/vm/%s", v.UUID))
+	data, err := v.client.DeleteContext(ctx, fmt.Sprintf("/vm/%s", v.UUID))
 	if err != nil {
 		return task, err
 	}
@@ -115,18 +168,112 @@ func (v VirtualMachine) Delete() (Task, error) {
 	return task, err
 }
 
-func (v VirtualMachine) IsBusy() bool {
-	tasks := []Task{}
-	data, _ := v.client.Delete(fmt.Sprintf("/task/%s/entity/%s/active", v.LocationID, v.UUID))
-	json.Unmarshal(data, &tasks)
-	if len(tasks) == 0 {
-		return false
+// IsBusy reports whether the virtual machine has active tasks, in which case
+// the methods that modify it wait or fail, see ReadinessPolicy.
+func (v VirtualMachine) IsBusy() (bool, error) {
+	return v.IsBusyContext(context.Background())
+}
+
+func (v VirtualMachine) IsBusyContext(ctx context.Context) (bool, error) {
+	return v.client.isBusy(ctx, v.LocationID, v.UUID)
+}
+
+func (v VirtualMachine) HasSnapshot() (bool, error) {
+	return v.HasSnapshotContext(context.Background())
+}
+
+func (v VirtualMachine) HasSnapshotContext(ctx context.Context) (bool, error) {
+	check := struct {
+		HasSnapshot bool `json:"has_snapshot"`
+	}{}
+	data, err := v.client.GetContext(ctx, fmt.Sprintf("/vm/%s/snapshot/check", v.UUID))
+	if err != nil {
+		return false, err
+	}
+	err = json.Unmarshal(data, &check)
+	return check.HasSnapshot, err
+}
+
+func (v VirtualMachine) GetSnapshot() (Snapshot, error) {
+	return v.GetSnapshotContext(context.Background())
+}
+
+func (v VirtualMachine) GetSnapshotContext(ctx context.Context) (Snapshot, error) {
+	snapshot := Snapshot{}
+	data, err := v.client.GetContext(ctx, fmt.Sprintf("/vm/%s/snapshot", v.UUID))
+	if err != nil {
+		return snapshot, err
+	}
+	err = json.Unmarshal(data, &snapshot)
+	return snapshot, err
+}
+
+// TakeSnapshot replaces the snapshot of the virtual machine, if any, with a
+// new one.
+func (v VirtualMachine) TakeSnapshot(opts SnapshotOptions) (Task, error) {
+	return v.TakeSnapshotContext(context.Background(), opts)
+}
+
+func (v VirtualMachine) TakeSnapshotContext(ctx context.Context, opts SnapshotOptions) (Task, error) {
+	if err := v.client.waitUntilObjectIsReady(ctx, v.LocationID, v.UUID); err != nil {
+		return Task{}, err
+	}
+	task := Task{}
+	params := opts.withDefaults()
+	output, _ := json.Marshal(&params)
+	data, err := v.client.PostContext(ctx, fmt.Sprintf("/vm/%s/snapshot", v.UUID), output)
+	if err != nil {
+		return task, err
 	}
-	return true
+	err = json.Unmarshal(data, &task)
+	task.client = v.client
+	return task, err
+}
+
+func (v VirtualMachine) RevertSnapshot() (Task, error) {
+	return v.RevertSnapshotContext(context.Background())
+}
+
+func (v VirtualMachine) RevertSnapshotContext(ctx context.Context) (Task, error) {
+	if err := v.client.waitUntilObjectIsReady(ctx, v.LocationID, v.UUID); err != nil {
+		return Task{}, err
+	}
+	task := Task{}
+	data, err := v.client.PostContext(ctx, fmt.Sprintf("/vm/%s/snapshot/restore", v.UUID), []byte{})
+	if err != nil {
+		return task, err
+	}
+	err = json.Unmarshal(data, &task)
+	task.client = v.client
+	return task, err
+}
+
+func (v VirtualMachine) RemoveSnapshot() (Task, error) {
+	return v.RemoveSnapshotContext(context.Background())
+}
+
+func (v VirtualMachine) RemoveSnapshotContext(ctx context.Context) (Task, error) {
+	if err := v.client.waitUntilObjectIsReady(ctx, v.LocationID, v.UUID); err != nil {
+		return Task{}, err
+	}
+	task := Task{}
+	data, err := v.client.DeleteContext(ctx, fmt.Sprintf("/vm/%s/snapshot", v.UUID))
+	if err != nil {
+		return task, err
+	}
+	err = json.Unmarshal(data, &task)
+	task.client = v.client
+	return task, err
 }
 
 func (v VirtualMachine) Rename(newName string) (Task, error) {
-	v.client.waitUntilObjectIsReady(v.LocationID, v.UUID)
+	return v.RenameContext(context.Background(), newName)
+}
+
+func (v VirtualMachine) RenameContext(ctx context.Context, newName string) (Task, error) {
+	if err := v.client.waitUntilObjectIsReady(ctx, v.LocationID, v.UUID); err != nil {
+		return Task{}, err
+	}
 	task := Task{}
 	params := struct {
 		Name string `json:"name"`
@@ -134,7 +281,7 @@ func (v VirtualMachine) Rename(newName string) (Task, error) {
 		Name: newName,
 	}
 	output, _ := json.Marshal(&params)
-	data, err := v.client.Put(fmt.Sprintf("/vm/%s/name", v.UUID), output)
+	data, err := v.client.PutContext(ctx, fmt.Sprintf("/vm/%s/name", v.UUID), output)
 	if err != nil {
 		return task, err
 	}
@@ -144,9 +291,15 @@ func (v VirtualMachine) Rename(newName string) (Task, error) {
 }
 
 func (v VirtualMachine) PowerOn() (Task, error) {
-	v.client.waitUntilObjectIsReady(v.LocationID, v.UUID)
+	return v.PowerOnContext(context.Background())
+}
+
+func (v VirtualMachine) PowerOnContext(ctx context.Context) (Task, error) {
+	if err := v.client.waitUntilObjectIsReady(ctx, v.LocationID, v.UUID); err != nil {
+		return Task{}, err
+	}
 	task := Task{}
-	data, err := v.client.Post(fmt.Sprintf("/vm/%s/poweron", v.UUID), []byte{})
+	data, err := v.client.PostContext(ctx, fmt.Sprintf("/vm/%s/poweron", v.UUID), []byte{})
 	if err != nil {
 		return task, err
 	}
@@ -156,9 +309,15 @@ func (v VirtualMachine) PowerOn() (Task, error) {
 }
 
 func (v VirtualMachine) Reboot() (Task, error) {
-	v.client.waitUntilObjectIsReady(v.LocationID, v.UUID)
+	return v.RebootContext(context.Background())
+}
+
+func (v VirtualMachine) RebootContext(ctx context.Context) (Task, error) {
+	if err := v.client.waitUntilObjectIsReady(ctx, v.LocationID, v.UUID); err != nil {
+		return Task{}, err
+	}
 	task := Task{}
-	data, err := v.client.Post(fmt.Sprintf("/vm/%s/reboot", v.UUID), []byte{})
+	data, err := v.client.PostContext(ctx, fmt.Sprintf("/vm/%s/reboot", v.UUID), []byte{})
 	if err != nil {
 		return task, err
 	}
@@ -168,9 +327,15 @@ func (v VirtualMachine) Reboot() (Task, error) {
 }
 
 func (v VirtualMachine) PowerOff() (Task, error) {
-	v.client.waitUntilObjectIsReady(v.LocationID, v.UUID)
+	return v.PowerOffContext(context.Background())
+}
+
+func (v VirtualMachine) PowerOffContext(ctx context.Context) (Task, error) {
+	if err := v.client.waitUntilObjectIsReady(ctx, v.LocationID, v.UUID); err != nil {
+		return Task{}, err
+	}
 	task := Task{}
-	data, err := v.client.Post(fmt.Sprintf("/vm/%s/poweroff", v.UUID), []byte{})
+	data, err := v.client.PostContext(ctx, fmt.Sprintf("/vm/%s/poweroff", v.UUID), []byte{})
 	if err != nil {
 		return task, err
 	}
@@ -180,13 +345,22 @@ func (v VirtualMachine) PowerOff() (Task, error) {
 }
 
 func (v VirtualMachine) Shutdown() (Task, error) {
-	v.client.waitUntilObjectIsReady(v.LocationID, v.UUID)
-	tools := v.GetTools()
+	return v.ShutdownContext(context.Background())
+}
+
+func (v VirtualMachine) ShutdownContext(ctx context.Context) (Task, error) {
+	if err := v.client.waitUntilObjectIsReady(ctx, v.LocationID, v.UUID); err != nil {
+		return Task{}, err
+	}
+	tools, err := v.GetToolsContext(ctx)
+	if err != nil {
+		return Task{}, err
+	}
 	if tools.Version == "0" {
-		return v.PowerOff()
+		return v.PowerOffContext(ctx)
 	}
 	task := Task{}
-	data, err := v.client.Post(fmt.Sprintf("/vm/%s/shutdown", v.UUID), []byte{})
+	data, err := v.client.PostContext(ctx, fmt.Sprintf("/vm/%s/shutdown", v.UUID), []byte{})
 	if err != nil {
 		return task, err
 	}
@@ -196,7 +370,13 @@ func (v VirtualMachine) Shutdown() (Task, error) {
 }
 
 func (v VirtualMachine) ModifyCPU(cpuCount int) (Task, error) {
-	v.client.waitUntilObjectIsReady(v.LocationID, v.UUID)
+	return v.ModifyCPUContext(context.Background(), cpuCount)
+}
+
+func (v VirtualMachine) ModifyCPUContext(ctx context.Context, cpuCount int) (Task, error) {
+	if err := v.client.waitUntilObjectIsReady(ctx, v.LocationID, v.UUID); err != nil {
+		return Task{}, err
+	}
 	task := Task{}
 	params := struct {
 		CPUCount       int `json:"cpus_number"`
@@ -206,7 +386,7 @@ func (v VirtualMachine) ModifyCPU(cpuCount int) (Task, error) {
 		CoresPerSocket: 1,
 	}
 	output, _ := json.Marshal(&params)
-	data, err := v.client.Put(fmt.Sprintf("/vm/%s/cpu", v.UUID), output)
+	data, err := v.client.PutContext(ctx, fmt.Sprintf("/vm/%s/cpu", v.UUID), output)
 	if err != nil {
 		return task, err
 	}
@@ -216,7 +396,13 @@ func (v VirtualMachine) ModifyCPU(cpuCount int) (Task, error) {
 }
 
 func (v VirtualMachine) ModifyMemory(memoryMB int) (Task, error) {
-	v.client.waitUntilObjectIsReady(v.LocationID, v.UUID)
+	return v.ModifyMemoryContext(context.Background(), memoryMB)
+}
+
+func (v VirtualMachine) ModifyMemoryContext(ctx context.Context, memoryMB int) (Task, error) {
+	if err := v.client.waitUntilObjectIsReady(ctx, v.LocationID, v.UUID); err != nil {
+		return Task{}, err
+	}
 	task := Task{}
 	params := struct {
 		MemoryMB int `json:"memory_size"`
@@ -224,7 +410,7 @@ func (v VirtualMachine) ModifyMemory(memoryMB int) (Task, error) {
 		MemoryMB: memoryMB,
 	}
 	output, _ := json.Marshal(&params)
-	data, err := v.client.Put(fmt.Sprintf("/vm/%s/mem", v.UUID), output)
+	data, err := v.client.PutContext(ctx, fmt.Sprintf("/vm/%s/mem", v.UUID), output)
 	if err != nil {
 		return task, err
 	}
@@ -234,10 +420,16 @@ func (v VirtualMachine) ModifyMemory(memoryMB int) (Task, error) {
 }
 
 func (v VirtualMachine) ModifyNics(nics []Nic) (Task, error) {
-	v.client.waitUntilObjectIsReady(v.LocationID, v.UUID)
+	return v.ModifyNicsContext(context.Background(), nics)
+}
+
+func (v VirtualMachine) ModifyNicsContext(ctx context.Context, nics []Nic) (Task, error) {
+	if err := v.client.waitUntilObjectIsReady(ctx, v.LocationID, v.UUID); err != nil {
+		return Task{}, err
+	}
 	task := Task{}
 	output, _ := json.Marshal(&nics)
-	data, err := v.client.Put(fmt.Sprintf("/vm/%s/vnics", v.UUID), output)
+	data, err := v.client.PutContext(ctx, fmt.Sprintf("/vm/%s/vnics", v.UUID), output)
 	if err != nil {
 		return task, err
 	}
@@ -247,10 +439,16 @@ func (v VirtualMachine) ModifyNics(nics []Nic) (Task, error) {
 }
 
 func (v VirtualMachine) ModifyDisks(disks []Disk) (Task, error) {
-	v.client.waitUntilObjectIsReady(v.LocationID, v.UUID)
+	return v.ModifyDisksContext(context.Background(), disks)
+}
+
+func (v VirtualMachine) ModifyDisksContext(ctx context.Context, disks []Disk) (Task, error) {
+	if err := v.client.waitUntilObjectIsReady(ctx, v.LocationID, v.UUID); err != nil {
+		return Task{}, err
+	}
 	task := Task{}
 	output, _ := json.Marshal(&disks)
-	data, err := v.client.Put(fmt.Sprintf("/vm/%s/virtual-disks", v.UUID), output)
+	data, err := v.client.PutContext(ctx, fmt.Sprintf("/vm/%s/virtual-disks", v.UUID), output)
 	if err != nil {
 		return task, err
 	}
@@ -267,8 +465,12 @@ type ConsoleSession struct {
 }
 
 func (v VirtualMachine) GetConsoleSession() (ConsoleSession, error) {
+	return v.GetConsoleSessionContext(context.Background())
+}
+
+func (v VirtualMachine) GetConsoleSessionContext(ctx context.Context) (ConsoleSession, error) {
 	session := ConsoleSession{}
-	data, err := v.client.Get(fmt.Sprintf("/vm/%s/mks-screen-ticket", v.UUID))
+	data, err := v.client.GetContext(ctx, fmt.Sprintf("/vm/%s/mks-screen-ticket", v.UUID))
 	if err != nil {
 		return session, err
 	}
@@ -277,7 +479,11 @@ func (v VirtualMachine) GetConsoleSession() (ConsoleSession, error) {
 }
 
 func (v VirtualMachine) GetScreenThumbnail() ([]byte, error) {
-	reader, err := v.client.getBinaryStream(fmt.Sprintf("/vm/%s/screen", v.UUID))
+	return v.GetScreenThumbnailContext(context.Background())
+}
+
+func (v VirtualMachine) GetScreenThumbnailContext(ctx context.Context) ([]byte, error) {
+	reader, err := v.client.getBinaryStream(ctx, fmt.Sprintf("/vm/%s/screen", v.UUID))
 	if err != nil {
 		return nil, err
 	}